@@ -10,14 +10,30 @@ import (
 	_ "github.com/keshon/bvc/internal/command/add"
 	_ "github.com/keshon/bvc/internal/command/block"
 	_ "github.com/keshon/bvc/internal/command/branch"
+	_ "github.com/keshon/bvc/internal/command/check-attr"
+	_ "github.com/keshon/bvc/internal/command/check-ignore"
+	_ "github.com/keshon/bvc/internal/command/check-paths"
 	_ "github.com/keshon/bvc/internal/command/checkout"
 	_ "github.com/keshon/bvc/internal/command/cherry-pick"
+	_ "github.com/keshon/bvc/internal/command/clean"
 	_ "github.com/keshon/bvc/internal/command/commit"
+	_ "github.com/keshon/bvc/internal/command/config"
+	_ "github.com/keshon/bvc/internal/command/convert"
+	_ "github.com/keshon/bvc/internal/command/diff"
 	_ "github.com/keshon/bvc/internal/command/help"
 	_ "github.com/keshon/bvc/internal/command/init"
 	_ "github.com/keshon/bvc/internal/command/log"
 	_ "github.com/keshon/bvc/internal/command/merge"
+	_ "github.com/keshon/bvc/internal/command/merge-base"
+	_ "github.com/keshon/bvc/internal/command/mv"
 	_ "github.com/keshon/bvc/internal/command/reset"
+	_ "github.com/keshon/bvc/internal/command/restore"
+	_ "github.com/keshon/bvc/internal/command/rev-list"
+	_ "github.com/keshon/bvc/internal/command/revert"
+	_ "github.com/keshon/bvc/internal/command/rm"
+	_ "github.com/keshon/bvc/internal/command/show"
+	_ "github.com/keshon/bvc/internal/command/sparse"
+	_ "github.com/keshon/bvc/internal/command/stash"
 	_ "github.com/keshon/bvc/internal/command/status"
 )
 
@@ -2,9 +2,10 @@ module github.com/keshon/bvc
 
 go 1.24.0
 
-require golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546
-
 require (
-	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
-	github.com/zeebo/xxh3 v1.0.2 // indirect
+	github.com/zeebo/xxh3 v1.0.2
+	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546
+	golang.org/x/text v0.34.0
 )
+
+require github.com/klauspost/cpuid/v2 v2.0.9 // indirect
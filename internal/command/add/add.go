@@ -11,6 +11,7 @@ import (
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
 	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
 )
 
 type Command struct {
@@ -27,7 +28,24 @@ func (c *Command) Help() string {
 
 Options:
   -a, --all             Stage all changes, including deletions (-A)
-	  --update          Stage modifications and deletions only (-u)
+      --update          Stage modifications and deletions of tracked files only (-u)
+
+Paths that are tracked but no longer exist in the working tree are staged
+for deletion.
+
+The content of staged files is stored right away, so editing a file after
+adding it does not change what the next commit records.
+
+New paths are checked for portability: paths that differ from another only
+by case or Unicode normalization, and names Windows does not allow (CON,
+names with ':' or a trailing dot, ...). Set the portable-paths setting
+(bvc config portable-paths <mode>) or BVC_PORTABLE_PATHS to "warn" (default),
+"refuse" or "off" to choose what happens; see check-paths.
+
+Symbolic links are stored as links. With BVC_SYMLINKS=follow, the files
+they point to are stored instead, and linked directories are walked into;
+links that loop back to a directory above them are skipped. FIFOs, sockets
+and devices are never stored and are skipped with a warning.
 
 Usage:
   bvc add <file|dir|.> [options]
@@ -64,44 +82,130 @@ func (c *Command) Run(ctx *command.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to scan repository files: %w", err)
 	}
+	workFiles := append(trackedFS.Files, stagedFS.Files...)
 
-	var entries []file.Entry
+	// the index models the next tree: HEAD with staged changes overlaid
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	index, err := r.Store.FileCtx.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+	nextFS := snapshot.ApplyIndex(headFS, index)
+
+	next := make(map[string]file.Entry, len(nextFS.Files))
+	for _, e := range nextFS.Files {
+		next[e.Path] = e
+	}
+	work := make(map[string]bool, len(workFiles))
+	for _, e := range workFiles {
+		work[e.Path] = true
+	}
+
+	sparse, err := r.Store.FileCtx.LoadSparse()
+	if err != nil {
+		return err
+	}
+
+	// files known to the next tree but gone from the working tree; files
+	// left out by sparse checkout are not deletions
+	var missing []file.Entry
+	for _, e := range nextFS.Files {
+		if !work[e.Path] && sparse.Includes(e.Path) {
+			missing = append(missing, e)
+		}
+	}
+
+	var entries, deletions []file.Entry
 
 	switch {
 	case includeAll:
-		// Stage all tracked changes (new, modified, deleted)
-		entries = trackedFS.Files
+		// Stage all changes (new, modified, deleted)
+		entries = workFiles
+		deletions = missing
 
 	case updateOnly:
-		// Stage only modifications and deletions for already-staged files
-		stagedMap := make(map[string]file.Entry, len(stagedFS.Files))
-		for _, e := range stagedFS.Files {
-			stagedMap[e.Path] = e
-		}
-		for _, e := range trackedFS.Files {
-			if _, exists := stagedMap[e.Path]; exists {
+		// Stage only modifications and deletions of tracked files
+		for _, e := range workFiles {
+			if _, tracked := next[e.Path]; tracked {
 				entries = append(entries, e)
 			}
 		}
+		deletions = missing
 
 	default:
-		// Stage specific paths or globs
+		// Stage specific paths or globs, including their deletions
 		for _, arg := range args {
-			matches := filterMatchingEntries(trackedFS.Files, arg)
-			entries = append(entries, matches...)
+			entries = append(entries, filterMatchingEntries(workFiles, arg)...)
+			deletions = append(deletions, filterMatchingEntries(missing, arg)...)
 		}
 	}
 
-	if len(entries) == 0 {
+	if len(entries) == 0 && len(deletions) == 0 {
 		return fmt.Errorf("no changes to stage")
 	}
 
-	// Write staged entries to index
-	if err := r.Store.FileCtx.SaveIndexMerge(entries); err != nil {
+	staged := 0
+	var added []string
+	var changed []file.Entry
+	for _, e := range entries {
+		cur, ok := next[e.Path]
+		if !ok {
+			added = append(added, filepath.ToSlash(e.Path))
+		}
+		if !ok || !cur.Equal(&e) {
+			next[e.Path] = e
+			changed = append(changed, e)
+			staged++
+		}
+	}
+	for _, e := range deletions {
+		if _, ok := next[e.Path]; ok {
+			delete(next, e.Path)
+			staged++
+		}
+	}
+
+	paths := make([]string, 0, len(next))
+	for _, e := range next {
+		paths = append(paths, filepath.ToSlash(e.Path))
+	}
+
+	// new paths must not break checkouts on other systems
+	mode, err := r.Store.FileCtx.PortablePathsMode()
+	if err != nil {
+		return err
+	}
+	if mode != file.PortableOff && len(added) > 0 {
+		problems := file.FilterPathProblems(file.CheckPortablePaths(paths), added)
+		if err := file.ReportPortablePaths(problems, mode); err != nil {
+			return err
+		}
+	}
+
+	// store the content now, so the index never refers to blocks a later
+	// edit of the working file could change
+	stored, err := r.Store.SnapshotCtx.StageFiles(changed)
+	if err != nil {
+		return fmt.Errorf("failed to store staged files: %w", err)
+	}
+	for _, e := range stored {
+		next[e.Path] = e
+	}
+
+	// Rewrite the index as the delta between HEAD and the updated next tree
+	updated := make([]file.Entry, 0, len(next))
+	for _, e := range next {
+		updated = append(updated, e)
+	}
+	updatedFS := snapshot.Fileset{Files: updated}
+	if err := r.Store.FileCtx.SaveIndexReplace(snapshot.IndexDelta(headFS, &updatedFS)); err != nil {
 		return fmt.Errorf("failed to update index: %w", err)
 	}
 
-	fmt.Printf("Staged %d file(s)\n", len(entries))
+	fmt.Printf("Staged %d file(s)\n", staged)
 	return nil
 }
 
@@ -138,6 +242,7 @@ func init() {
 		command.ApplyMiddlewares(
 			&Command{},
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 			middleware.WithDebugArgsPrint(),
 		),
 	)
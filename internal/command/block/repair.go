@@ -7,7 +7,6 @@ import (
 
 	"flag"
 	"fmt"
-	"path/filepath"
 	"sort"
 	"time"
 
@@ -80,7 +79,7 @@ func (c *RepairCommand) Run(ctx *command.Context) error {
 	var fixedList, failedList []block.BlockCheck
 
 	for _, bc := range toFix {
-		targetPath := filepath.Join(r.Config.BlocksDir(), bc.Hash+".bin")
+		targetPath := r.Store.BlockCtx.BlockPath(bc.Hash)
 		_ = fs.Remove(targetPath)
 
 		fixed := false
@@ -94,7 +93,7 @@ func (c *RepairCommand) Run(ctx *command.Context) error {
 				if b.Hash != bc.Hash {
 					continue
 				}
-				if err := r.Store.BlockCtx.Write(entry.Path, []block.BlockRef{b}); err != nil {
+				if err := r.Store.BlockCtx.WriteWith(entry.Path, []block.BlockRef{b}, r.Store.FileCtx.Attributes().For(entry.Path).Policy()); err != nil {
 					continue
 				}
 				status, _ := r.Store.BlockCtx.VerifyBlock(b.Hash)
@@ -133,7 +132,7 @@ func (c *RepairCommand) Run(ctx *command.Context) error {
 	fmt.Printf("Blocks repaired: \033[32m%d\033[0m / %d\n", repaired, len(toFix))
 
 	// Final verification pass
-	failed := verifyRepairedBlocks(toFix)
+	failed := verifyRepairedBlocks(r.Store.BlockCtx, toFix)
 
 	if len(fixedList) > 0 {
 		fmt.Println("\nRepaired blocks:")
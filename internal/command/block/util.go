@@ -1,27 +1,19 @@
 package block
 
 import (
-	"github.com/keshon/bvc/internal/config"
-	"github.com/keshon/bvc/internal/fs"
-
 	"fmt"
-	"path/filepath"
 	"sort"
 
 	"github.com/keshon/bvc/internal/repo/store/block"
-
-	"github.com/zeebo/xxh3"
 )
 
-func verifyRepairedBlocks(toFix []block.BlockCheck) int {
+func verifyRepairedBlocks(blocks *block.BlockContext, toFix []block.BlockCheck) int {
 	fmt.Println("\nVerifying repaired blocks...")
 	failed := 0
-	cfg := config.NewRepoConfig(config.ResolveRepoDir())
 
 	for _, bc := range toFix {
-		path := filepath.Join(cfg.BlocksDir(), bc.Hash+".bin")
-		ok, _ := verifyBlockHash(path, bc.Hash)
-		if !ok {
+		status, _ := blocks.VerifyBlock(bc.Hash)
+		if status != block.OK {
 			failed++
 			files := append([]string{}, bc.Files...)
 			sort.Strings(files)
@@ -30,13 +22,3 @@ func verifyRepairedBlocks(toFix []block.BlockCheck) int {
 	}
 	return failed
 }
-
-func verifyBlockHash(path, expected string) (bool, error) {
-	fs := fs.NewOSFS()
-	data, err := fs.ReadFile(path)
-	if err != nil {
-		return false, err
-	}
-	sum := fmt.Sprintf("%x", xxh3.Hash128(data).Bytes())
-	return sum == expected, nil
-}
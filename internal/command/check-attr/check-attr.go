@@ -0,0 +1,148 @@
+package check_attr
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type Command struct {
+	all     bool
+	verbose bool
+}
+
+func (c *Command) Name() string      { return "check-attr" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Show the attributes of paths" }
+func (c *Command) Usage() string {
+	return "check-attr [options] <attr>... -- <path>..."
+}
+func (c *Command) Help() string {
+	return `Print the attributes of each path as <path>: <attr>: <value>.
+
+Attributes are read from .bvc-attributes in the working tree root. Each line
+is a pattern, in .bvc-ignore syntax, followed by attributes:
+
+  *.mp4       -compress
+  *.json      chunk=64k
+  *.psd       lockable
+  generated/  merge=ours
+
+"attr" sets an attribute, "-attr" unsets it, "!attr" makes it unspecified
+again and "attr=value" gives it a value. Later lines override earlier ones.
+
+Known attributes:
+  compress          Store the file's blocks gzip compressed.
+  chunk=<size>      Minimum block size, e.g. 64k or 4M. Blocks are at most four times larger.
+  lockable          Binary asset edited by one person at a time; marked in status.
+  merge=ours|theirs Resolve conflicting changes by taking that side.
+
+Without --, the first argument is the attribute and the rest are paths.
+
+Options:
+  -a, --all        Print all attributes set for the paths.
+  -v, --verbose    Also print the deciding rule as <source>:<line>:<pattern>.
+
+Usage:
+  bvc check-attr [options] <attr>... -- <path>...
+  bvc check-attr -a [options] <path>...
+
+Examples:
+  bvc check-attr compress video.mp4
+  bvc check-attr chunk compress -- data/a.json data/b.json
+  bvc check-attr -a -v design.psd
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.all, "all", false, "print all attributes")
+	fs.BoolVar(&c.all, "a", false, "alias for --all")
+	fs.BoolVar(&c.verbose, "verbose", false, "print the deciding rule")
+	fs.BoolVar(&c.verbose, "v", false, "alias for --verbose")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	var names, paths []string
+	switch i := slices.Index(ctx.Args, "--"); {
+	case c.all:
+		paths = ctx.Args
+		if i >= 0 {
+			paths = ctx.Args[i+1:]
+		}
+	case i >= 0:
+		names, paths = ctx.Args[:i], ctx.Args[i+1:]
+	case len(ctx.Args) > 0:
+		names, paths = ctx.Args[:1], ctx.Args[1:]
+	}
+	if !c.all && len(names) == 0 {
+		return fmt.Errorf("attribute required")
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("path required")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+	attrs := fc.Attributes()
+
+	for _, arg := range paths {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", arg, err)
+		}
+		rel, err := filepath.Rel(fc.WorkingTreeDir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s is outside the working tree", arg)
+		}
+
+		matches := attrs.Explain(rel)
+		if c.all {
+			for _, m := range matches {
+				if m.Value != file.AttrUnspecified {
+					c.print(fc.WorkingTreeDir, arg, m)
+				}
+			}
+			continue
+		}
+		for _, name := range names {
+			m := file.AttrMatch{AttrAssign: file.AttrAssign{Name: name, Value: file.AttrUnspecified}}
+			if i := slices.IndexFunc(matches, func(m file.AttrMatch) bool { return m.Name == name }); i >= 0 {
+				m = matches[i]
+			}
+			c.print(fc.WorkingTreeDir, arg, m)
+		}
+	}
+	return nil
+}
+
+func (c *Command) print(root, path string, m file.AttrMatch) {
+	if !c.verbose || m.Source == "" {
+		fmt.Printf("%s: %s: %s\n", path, m.Name, m.Value)
+		return
+	}
+	source := m.Source
+	if s, err := filepath.Rel(root, source); err == nil {
+		source = s
+	}
+	fmt.Printf("%s: %s: %s\t%s:%d:%s\n", path, m.Name, m.Value, filepath.ToSlash(source), m.Line, m.Pattern)
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
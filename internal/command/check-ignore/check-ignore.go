@@ -0,0 +1,114 @@
+package check_ignore
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type Command struct {
+	verbose     bool
+	nonMatching bool
+}
+
+func (c *Command) Name() string      { return "check-ignore" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Show which ignore rule applies to paths" }
+func (c *Command) Usage() string     { return "check-ignore [options] <path>..." }
+func (c *Command) Help() string {
+	return `Print each path that is ignored.
+
+Ignore rules follow gitignore semantics and are read from .bvc-ignore files
+in the working tree root and its subdirectories, and from the user-global
+excludes file ($BVC_EXCLUDES_FILE, or bvc/ignore in the user config directory).
+
+Options:
+  -v, --verbose         Print the deciding rule as <source>:<line>:<pattern><TAB><path>.
+                        Paths re-included by a "!" rule are printed too.
+  -n, --non-matching    With --verbose, also print paths no rule matches, as ::<TAB><path>.
+
+Usage:
+  bvc check-ignore [options] <path>...
+
+Examples:
+  bvc check-ignore build/out.o
+  bvc check-ignore -v logs/today.log
+  bvc check-ignore -v -n *
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.verbose, "verbose", false, "print the matching rule")
+	fs.BoolVar(&c.verbose, "v", false, "alias for --verbose")
+	fs.BoolVar(&c.nonMatching, "non-matching", false, "also print paths that match no rule")
+	fs.BoolVar(&c.nonMatching, "n", false, "alias for --non-matching")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("path required")
+	}
+	if c.nonMatching && !c.verbose {
+		return fmt.Errorf("--non-matching is only valid with --verbose")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+	matcher := file.NewIgnore(fc.WorkingTreeDir, fc.FS)
+
+	for _, arg := range ctx.Args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", arg, err)
+		}
+		rel, err := filepath.Rel(fc.WorkingTreeDir, abs)
+		if err != nil || outside(rel) {
+			return fmt.Errorf("%s is outside the working tree", arg)
+		}
+
+		rule := matcher.Check(rel, fc.FS.IsDir(abs))
+		switch {
+		case rule == nil:
+			if c.nonMatching {
+				fmt.Printf("::\t%s\n", arg)
+			}
+		case !c.verbose:
+			if !rule.Negate() {
+				fmt.Println(arg)
+			}
+		default:
+			source := rule.Source
+			if source == "" {
+				source = "<default>"
+			} else if s, err := filepath.Rel(fc.WorkingTreeDir, source); err == nil && !outside(s) {
+				source = s
+			}
+			fmt.Printf("%s:%d:%s\t%s\n", filepath.ToSlash(source), rule.Line, rule.Pattern, arg)
+		}
+	}
+	return nil
+}
+
+// outside reports whether a path relative to the working tree leaves it.
+func outside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
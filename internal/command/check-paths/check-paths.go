@@ -0,0 +1,112 @@
+package check_paths
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	index bool
+}
+
+func (c *Command) Name() string      { return "check-paths" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Find paths that break checkouts on other systems" }
+func (c *Command) Usage() string     { return "check-paths [--index | <revision>]" }
+func (c *Command) Help() string {
+	return `Audit the paths of a commit for portability to Windows and macOS.
+
+Reported problems:
+  case       Differs from another path only by case.
+  unicode    Differs from another path only by Unicode normalization.
+  reserved   A Windows device name: CON, PRN, AUX, NUL, COM1-9, LPT1-9,
+             with or without an extension.
+  char       Contains < > : " \ | ? * or a control character.
+  trailing   Ends in a dot or a space, which Windows strips.
+  utf8       Not valid UTF-8.
+
+A problem with a directory is reported once, for the directory. The command
+fails when any problem is found.
+
+add and commit run the same check on new paths. The portable-paths setting
+(see config) sets what they do: "warn" (default) prints the problems,
+"refuse" stops, "off" skips the check. BVC_PORTABLE_PATHS overrides the
+setting for one run.
+
+Options:
+  --index    Check the tree the next commit would record instead of a commit.
+
+Usage:
+  bvc check-paths [--index | <revision>]
+
+Examples:
+  bvc check-paths
+  bvc check-paths main~3
+  bvc check-paths --index
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.index, "index", false, "check the staged tree")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) > 1 || (c.index && len(ctx.Args) > 0) {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var fs *snapshot.Fileset
+	if c.index {
+		if fs, err = r.GetIndexFileset(); err != nil {
+			return err
+		}
+	} else {
+		rev := "HEAD"
+		if len(ctx.Args) == 1 {
+			rev = ctx.Args[0]
+		}
+		id, err := r.Meta.ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+		if fs, err = r.GetCommittedFileset(id); err != nil {
+			return fmt.Errorf("failed to load fileset of commit %s: %w", id, err)
+		}
+	}
+
+	paths := make([]string, len(fs.Files))
+	for i, e := range fs.Files {
+		paths[i] = filepath.ToSlash(e.Path)
+	}
+	problems := file.CheckPortablePaths(paths)
+	for _, p := range problems {
+		fmt.Printf("%s\t%s\n", p.Kind, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d non-portable path(s)", len(problems))
+	}
+	fmt.Printf("All %d paths are portable\n", len(paths))
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
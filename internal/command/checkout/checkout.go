@@ -8,35 +8,83 @@ import (
 	"github.com/keshon/bvc/internal/config"
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
 )
 
-type Command struct{}
+type Command struct {
+	force       bool
+	continueOpt bool
+	abort       bool
+}
 
 func (c *Command) Name() string  { return "checkout" }
 func (c *Command) Brief() string { return "Switch to another branch" }
-func (c *Command) Usage() string { return "checkout <branch-name>" }
+func (c *Command) Usage() string {
+	return "checkout [-f|--force] <branch-name> | checkout --continue | checkout --abort"
+}
 func (c *Command) Help() string {
 	return `Switch to another branch.
 
+Only files that differ between the current commit and the branch are
+written, and files the branch does not have are deleted. Local changes to
+other files are kept. If uncommitted changes to a file, staged or not, or an
+untracked file would be overwritten, nothing is changed unless --force is
+given; --force also unstages the changes it discards.
+
+Progress is recorded as files are written. If a checkout is interrupted,
+HEAD still points to the old branch and other commands refuse to run until
+it is finished with --continue or undone with --abort.
+
+Options:
+  -f, --force    Discard local changes that are in the way.
+  --continue     Finish an interrupted checkout.
+  --abort        Undo an interrupted checkout and stay on the old branch.
+
 Usage:
-  checkout <branch-name>`
+  checkout [-f|--force] <branch-name>
+  checkout --continue
+  checkout --abort`
 }
 func (c *Command) Aliases() []string              { return []string{"co"} }
 func (c *Command) Subcommands() []command.Command { return nil }
-func (c *Command) Flags(fs *flag.FlagSet)         {}
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.force, "force", false, "discard local changes")
+	fs.BoolVar(&c.force, "f", false, "alias for --force")
+	fs.BoolVar(&c.continueOpt, "continue", false, "finish an interrupted checkout")
+	fs.BoolVar(&c.abort, "abort", false, "undo an interrupted checkout")
+}
 
 func (c *Command) Run(ctx *command.Context) error {
-	if len(ctx.Args) < 1 {
-		return fmt.Errorf("branch name required")
-	}
-	branchName := ctx.Args[0]
-
 	// open the repository context
 	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
 	if err != nil {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
 
+	switch {
+	case c.continueOpt && c.abort:
+		return fmt.Errorf("--continue and --abort cannot be used together")
+	case c.continueOpt:
+		j, err := r.ContinueRestore()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Switched to branch", j.Branch)
+		return nil
+	case c.abort:
+		j, err := r.AbortRestore()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Checkout aborted, staying on branch", j.FromBranch)
+		return nil
+	}
+
+	if len(ctx.Args) < 1 {
+		return fmt.Errorf("branch name required")
+	}
+	branchName := ctx.Args[0]
+
 	// ensure branch exists
 	targetBranch, err := r.Meta.GetBranch(branchName)
 	if err != nil {
@@ -51,43 +99,34 @@ func (c *Command) Run(ctx *command.Context) error {
 
 	fmt.Println(commitID)
 
-	// case 1: handle empty branch
-	if commitID == "" {
-		if err := r.Store.FileCtx.RestoreFilesToWorkingTree(nil, fmt.Sprintf("empty branch '%s'", branchName)); err != nil {
-			return err
-		}
-		if _, err := r.Meta.SetHeadRef(branchName); err != nil {
-			return err
-		}
-		fmt.Println("Branch is empty, switched to", branchName)
-		return nil
-	}
-
-	// case 2: handle non-empty branch
-	// load commit and fileset
-	commit, err := r.Meta.GetCommit(commitID)
+	// the working tree is moved from the current commit
+	current, err := r.Meta.GetCurrentBranch()
 	if err != nil {
-		return fmt.Errorf("failed to load commit %s: %w", commitID, err)
+		return err
 	}
-
-	fs, err := r.Store.SnapshotCtx.Load(commit.FilesetID)
+	currentID, err := r.Meta.GetLastCommitID(current.Name)
 	if err != nil {
-		return fmt.Errorf("failed to load fileset %s: %w", commit.FilesetID, err)
-	}
-
-	// restore files
-	if err := r.Store.FileCtx.RestoreFilesToWorkingTree(fs.Files, fmt.Sprintf("branch '%s'", branchName)); err != nil {
-		return fmt.Errorf("restore failed: %w", err)
+		return err
 	}
 
-	// update HEAD and last commit
-	if _, err := r.Meta.SetHeadRef(branchName); err != nil {
-		return err
+	// update the working tree, then HEAD and last commit
+	j := &meta.RestoreJournal{
+		Command:    c.Name(),
+		Branch:     branchName,
+		FromBranch: current.Name,
+		FromCommit: currentID,
+		ToCommit:   commitID,
+		Force:      c.force,
+		Label:      fmt.Sprintf("branch '%s'", branchName),
 	}
-	if err := r.Meta.SetLastCommitID(branchName, commitID); err != nil {
+	if err := r.Restore(j); err != nil {
 		return err
 	}
 
+	if commitID == "" {
+		fmt.Println("Branch is empty, switched to", branchName)
+		return nil
+	}
 	fmt.Println("Switched to branch", branchName)
 	return nil
 }
@@ -98,6 +137,7 @@ func init() {
 			&Command{},
 			middleware.WithDebugArgsPrint(),
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 		),
 	)
 }
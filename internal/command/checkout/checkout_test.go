@@ -0,0 +1,57 @@
+package checkout_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/branch"
+	"github.com/keshon/bvc/internal/command/checkout"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func TestCheckout_RefusesStagedChanges(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "main", "b.txt": "b"})
+	commandtest.MustRun(t, &branch.Command{}, "feat")
+	commandtest.MustRun(t, &checkout.Command{}, "feat")
+	commandtest.Commit(t, "feat", map[string]string{"a.txt": "feat"})
+	commandtest.MustRun(t, &checkout.Command{}, "main")
+
+	// staged, with the working file set back to HEAD's content
+	commandtest.WriteFile(t, "a.txt", "mainstaged")
+	commandtest.MustRun(t, &add.Command{}, "a.txt")
+	commandtest.WriteFile(t, "a.txt", "main")
+
+	err := commandtest.Run(t, &checkout.Command{}, "feat")
+	var dirty *file.DirtyTreeError
+	if !errors.As(err, &dirty) || !reflect.DeepEqual(dirty.Staged, []string{"a.txt"}) {
+		t.Fatalf("expected a.txt reported as staged, got %v", err)
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "main" {
+		t.Fatalf("refused checkout touched a.txt: %q", got)
+	}
+	if got := commandtest.Staged(t)["a.txt"]; got != "mainstaged" {
+		t.Fatalf("refused checkout changed the index: a.txt = %q", got)
+	}
+
+	// --force discards the staged change
+	commandtest.MustRun(t, &checkout.Command{}, "--force", "feat")
+	want := map[string]string{"a.txt": "feat", "b.txt": "b"}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged after --force %v, want %v", got, want)
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "feat" {
+		t.Fatalf("a.txt = %q, want %q", got, "feat")
+	}
+
+	// a staged change the checkout does not touch is kept
+	commandtest.WriteFile(t, "b.txt", "staged b")
+	commandtest.MustRun(t, &add.Command{}, "b.txt")
+	commandtest.MustRun(t, &checkout.Command{}, "main")
+	if got := commandtest.Staged(t)["b.txt"]; got != "staged b" {
+		t.Fatalf("staged b.txt = %q, want it carried over", got)
+	}
+}
@@ -3,6 +3,8 @@ package cherry_pick
 import (
 	"flag"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/keshon/bvc/internal/command"
@@ -10,28 +12,67 @@ import (
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
 	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
 )
 
-type Command struct{}
+type Command struct {
+	mainline   int
+	noCommit   bool
+	recordOrig bool
+}
 
-func (c *Command) Name() string  { return "cherry-pick" }
-func (c *Command) Brief() string { return "Apply selected commit to the current branch" }
-func (c *Command) Usage() string { return "cherry-pick <commit-id>" }
+func (c *Command) Name() string { return "cherry-pick" }
+func (c *Command) Brief() string {
+	return "Apply the changes of selected commits to the current branch"
+}
+func (c *Command) Usage() string { return "cherry-pick [options] <commit>... | <from>..<to>" }
 func (c *Command) Help() string {
-	return `Apply a specific commit to the current branch.
+	return `Apply the changes introduced by existing commits to the current branch.
+
+Only the delta of each picked commit against its parent is applied: it is
+three-way merged onto HEAD, so other changes on the current branch are kept.
+Every picked commit becomes a new commit with the original message.
+If a picked path was also changed on the current branch, the conflict is
+reported, the current version is kept and the picked version is written next
+to it as <path>.MERGE_THEIRS; picking stops and nothing more is committed.
+
+Files a pick changes must have no uncommitted changes, staged or not, and
+no untracked file may be in the way; otherwise picking stops before it.
+
+A range <from>..<to> picks every commit reachable from <to> but not from
+<from>, oldest first. Merge commits in a range need --mainline.
+
+Options:
+  -m, --mainline=<n>    Parent number (starting at 1) to diff against; required for merge commits.
+  -n, --no-commit       Apply and stage the changes without committing.
+  -x                    Append "(cherry picked from commit <id>)" to the message.
 
 Usage:
-  cherry-pick <commit-id>`
+  bvc cherry-pick [options] <commit>...
+  bvc cherry-pick [options] <from>..<to>
+
+Examples:
+  bvc cherry-pick 18dfbee
+  bvc cherry-pick -x feature~2 feature
+  bvc cherry-pick main..feature
+  bvc cherry-pick -n 18dfbee 2a41c07
+`
 }
 func (c *Command) Aliases() []string              { return []string{"cp"} }
 func (c *Command) Subcommands() []command.Command { return nil }
-func (c *Command) Flags(fs *flag.FlagSet)         {}
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.IntVar(&c.mainline, "mainline", 0, "parent number to diff against")
+	fs.IntVar(&c.mainline, "m", 0, "alias for --mainline")
+	fs.BoolVar(&c.noCommit, "no-commit", false, "do not create commits")
+	fs.BoolVar(&c.noCommit, "n", false, "alias for --no-commit")
+	fs.BoolVar(&c.recordOrig, "x", false, "record the picked commit ID in the message")
+}
 
 func (c *Command) Run(ctx *command.Context) error {
 	if len(ctx.Args) < 1 {
 		return fmt.Errorf("commit ID required")
 	}
-	commitID := ctx.Args[0]
 
 	// open the repository context
 	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
@@ -39,57 +80,194 @@ func (c *Command) Run(ctx *command.Context) error {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
 
-	// get commit and fileset
-	targetCommit, err := r.Meta.GetCommit(commitID)
+	picks, err := resolvePicks(r.Meta, ctx.Args)
 	if err != nil {
 		return err
 	}
+	if len(picks) == 0 {
+		fmt.Println("Nothing to pick: the range is empty")
+		return nil
+	}
 
-	targetFileset, err := r.Store.SnapshotCtx.Load(targetCommit.FilesetID)
+	// get current branch and its head
+	branch, err := r.Meta.GetCurrentBranch()
 	if err != nil {
 		return err
 	}
-
-	// get current branch
-	targetBranch, err := r.Meta.GetCurrentBranch()
+	headID, err := r.Meta.GetLastCommitID(branch.Name)
 	if err != nil {
 		return err
 	}
-
-	// get parent commit
-	parent, err := r.Meta.GetLastCommitID(targetBranch.Name)
+	if headID == "" {
+		return fmt.Errorf("branch '%s' has no commits", branch.Name)
+	}
+	oursFS, err := r.GetCommittedFileset(headID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
 	}
 
-	// create new commit on current branch referencing the picked commit
-	newCommit := meta.Commit{
-		ID:        fmt.Sprintf("%x", time.Now().UnixNano()),
-		Parents:   []string{parent},
-		Branch:    targetBranch.Name,
-		Message:   fmt.Sprintf("Pick commit %s", commitID),
-		Timestamp: time.Now().Format(time.RFC3339),
-		FilesetID: targetCommit.FilesetID,
-	}
+	// headFS tracks the fileset of the branch tip; with --no-commit the picks
+	// accumulate in oursFS and are staged against it at the end
+	headFS := oursFS
+	applied := 0
 
-	// create commit
-	_, err = r.Meta.CreateCommit(&newCommit)
-	if err != nil {
-		return err
+	for _, pickID := range picks {
+		pick, err := r.Meta.GetCommit(pickID)
+		if err != nil {
+			return err
+		}
+		parentID, err := c.parentOf(pick)
+		if err != nil {
+			return err
+		}
+
+		// three-way merge: base = picked commit's parent, theirs = picked commit
+		baseFS := &snapshot.Fileset{}
+		if parentID != "" {
+			if baseFS, err = r.GetCommittedFileset(parentID); err != nil {
+				return fmt.Errorf("failed to load fileset of %s: %w", parentID, err)
+			}
+		}
+		theirsFS, err := r.GetCommittedFileset(pickID)
+		if err != nil {
+			return fmt.Errorf("failed to load fileset of %s: %w", pickID, err)
+		}
+
+		mergedFS, conflicts := snapshot.MergeFilesetsWith(baseFS, oursFS, theirsFS, r.Store.FileCtx.Attributes().MergeStrategy)
+		if changed, removed := snapshot.DiffFilesets(oursFS, &mergedFS); len(changed) == 0 && len(removed) == 0 {
+			fmt.Printf("Skipped %s: its changes are already on branch '%s'\n", pickID, branch.Name)
+			continue
+		}
+
+		// update working tree, keeping uncommitted changes safe
+		if err := r.ApplyTree(oursFS, &mergedFS, fmt.Sprintf("pick commit %s", pickID), "commit or stash your changes before cherry-picking"); err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			// stage cleanly picked files; conflict copies stay unstaged
+			copies := map[string]bool{}
+			for _, p := range conflicts {
+				copies[p+".MERGE_THEIRS"] = true
+			}
+			var staged []file.Entry
+			for _, e := range snapshot.IndexDelta(headFS, &mergedFS) {
+				if !copies[e.Path] {
+					staged = append(staged, e)
+				}
+			}
+			if err := r.Store.FileCtx.SaveIndexMerge(staged); err != nil {
+				return fmt.Errorf("failed to stage picked files: %w", err)
+			}
+			fmt.Printf("\nCherry-pick of %s stopped with conflicts:\n", pickID)
+			for _, path := range conflicts {
+				fmt.Printf("CONFLICT: %s (picked version saved as %s.MERGE_THEIRS)\n", path, path)
+			}
+			fmt.Println("\nResolve conflicts manually, then commit the result.")
+			return nil
+		}
+
+		if c.noCommit {
+			applied++
+			oursFS = &mergedFS
+			fmt.Printf("Applied %s\n", pickID)
+			continue
+		}
+
+		if err := r.Store.SnapshotCtx.Save(mergedFS); err != nil {
+			return fmt.Errorf("failed to save fileset: %w", err)
+		}
+
+		// create new commit on current branch with the picked message
+		message := pick.Message
+		if c.recordOrig {
+			message = strings.TrimRight(message, "\n") + fmt.Sprintf("\n\n(cherry picked from commit %s)", pickID)
+		}
+		newCommit := meta.Commit{
+			ID:        fmt.Sprintf("%x", time.Now().UnixNano()),
+			Parents:   []string{headID},
+			Branch:    branch.Name,
+			Message:   message,
+			Timestamp: time.Now().Format(time.RFC3339),
+			FilesetID: mergedFS.ID,
+		}
+		if _, err := r.Meta.CreateCommit(&newCommit); err != nil {
+			return err
+		}
+		if err := r.Meta.SetLastCommitID(branch.Name, newCommit.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Picked commit %s into branch '%s' as %s\n", pickID, branch.Name, newCommit.ID)
+		headID = newCommit.ID
+		headFS = &mergedFS
+		oursFS = &mergedFS
 	}
 
-	// update last commit for the branch
-	if err := r.Meta.SetLastCommitID(targetBranch.Name, newCommit.ID); err != nil {
-		return err
+	if c.noCommit && applied > 0 {
+		if err := r.Store.FileCtx.SaveIndexMerge(snapshot.IndexDelta(headFS, oursFS)); err != nil {
+			return fmt.Errorf("failed to stage picked files: %w", err)
+		}
+		fmt.Println("Picked changes staged; commit them with 'bvc commit'.")
 	}
+	return nil
+}
 
-	// restore files from picked commit
-	if err := r.Store.FileCtx.RestoreFilesToWorkingTree(targetFileset.Files, fmt.Sprintf("pick commit %s", commitID)); err != nil {
-		return err
+// parentOf returns the parent a picked commit is diffed against ("" for a root commit).
+func (c *Command) parentOf(pick *meta.Commit) (string, error) {
+	switch {
+	case len(pick.Parents) > 1:
+		if c.mainline < 1 || c.mainline > len(pick.Parents) {
+			return "", fmt.Errorf("commit %s is a merge; use -m <1..%d> to choose the parent", pick.ID, len(pick.Parents))
+		}
+		return pick.Parents[c.mainline-1], nil
+	case c.mainline > 1:
+		return "", fmt.Errorf("commit %s has only one parent", pick.ID)
+	case len(pick.Parents) == 1:
+		return pick.Parents[0], nil
 	}
+	return "", nil
+}
 
-	fmt.Printf("Picked commit %s into branch '%s' as %s\n", commitID, targetBranch.Name, newCommit.ID)
-	return nil
+// resolvePicks expands the arguments into commit IDs in the order they are applied.
+func resolvePicks(mc *meta.MetaContext, args []string) ([]string, error) {
+	var picks []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "..") || strings.Contains(arg, "...") {
+			id, err := mc.ResolveRevision(arg)
+			if err != nil {
+				return nil, err
+			}
+			picks = append(picks, id)
+			continue
+		}
+
+		parts := strings.SplitN(arg, "..", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid range %q: start revision required", arg)
+		}
+		if parts[1] == "" {
+			parts[1] = "HEAD"
+		}
+		from, err := mc.ResolveRevision(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := mc.ResolveRevision(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		graph, err := mc.CommitGraphFor(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load commit graph: %w", err)
+		}
+
+		// parents first; among unrelated commits, oldest first
+		ids := graph.Range([]string{to}, []string{from})
+		slices.Reverse(ids)
+		picks = append(picks, graph.TopoOrder(ids)...)
+	}
+	return picks, nil
 }
 
 func init() {
@@ -98,6 +276,7 @@ func init() {
 			&Command{},
 			middleware.WithDebugArgsPrint(),
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 		),
 	)
 }
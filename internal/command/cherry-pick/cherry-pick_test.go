@@ -0,0 +1,121 @@
+package cherry_pick_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/branch"
+	"github.com/keshon/bvc/internal/command/checkout"
+	cherry_pick "github.com/keshon/bvc/internal/command/cherry-pick"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// setup makes a repository whose feature branch has three commits main
+// lacks, each changing one file, while main has one of its own.
+func setup(t *testing.T) []string {
+	t.Helper()
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "base", map[string]string{"a.txt": "a0", "b.txt": "b0"})
+	commandtest.MustRun(t, &branch.Command{}, "feature")
+	commandtest.MustRun(t, &checkout.Command{}, "feature")
+	commandtest.Commit(t, "f1", map[string]string{"a.txt": "a1"})
+	commandtest.Commit(t, "f2", map[string]string{"c.txt": "c1"})
+	commandtest.Commit(t, "f3", map[string]string{"b.txt": "b1"})
+	var feature []string
+	for _, cmt := range commandtest.Commits(t)[:3] {
+		feature = append(feature, cmt.ID)
+	}
+	commandtest.MustRun(t, &checkout.Command{}, "main")
+	commandtest.Commit(t, "m1", map[string]string{"d.txt": "d1"})
+	return feature // newest first
+}
+
+func files(t *testing.T, names ...string) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	for _, n := range names {
+		out[n] = commandtest.ReadFile(t, n)
+	}
+	return out
+}
+
+func messages(t *testing.T) []string {
+	t.Helper()
+	var out []string
+	for _, cmt := range commandtest.Commits(t) {
+		out = append(out, strings.SplitN(cmt.Message, "\n", 2)[0])
+	}
+	return out
+}
+
+func TestCherryPickRange(t *testing.T) {
+	setup(t)
+
+	commandtest.MustRun(t, &cherry_pick.Command{}, "main..feature")
+
+	if got, want := messages(t), []string{"f3", "f2", "f1", "m1", "base"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history %v, want %v", got, want)
+	}
+	want := map[string]string{"a.txt": "a1", "b.txt": "b1", "c.txt": "c1", "d.txt": "d1"}
+	if got := files(t, "a.txt", "b.txt", "c.txt", "d.txt"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+}
+
+func TestCherryPickRecordOrigin(t *testing.T) {
+	feature := setup(t)
+
+	commandtest.MustRun(t, &cherry_pick.Command{}, "-x", feature[1])
+
+	msg := commandtest.Commits(t)[0].Message
+	if want := "f2\n\n(cherry picked from commit " + feature[1] + ")"; msg != want {
+		t.Fatalf("message %q, want %q", msg, want)
+	}
+	// only the delta of f2 is applied, not f1 below it
+	want := map[string]string{"a.txt": "a0", "c.txt": "c1", "d.txt": "d1"}
+	if got := files(t, "a.txt", "c.txt", "d.txt"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+}
+
+func TestCherryPickNoCommit(t *testing.T) {
+	feature := setup(t)
+
+	commandtest.MustRun(t, &cherry_pick.Command{}, "-n", feature[2], feature[0])
+
+	if got, want := messages(t), []string{"m1", "base"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history %v, want %v", got, want)
+	}
+	want := map[string]string{"a.txt": "a1", "b.txt": "b1", "d.txt": "d1"}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged %v, want %v", got, want)
+	}
+	if got := files(t, "a.txt", "b.txt", "c.txt"); !reflect.DeepEqual(got, map[string]string{"a.txt": "a1", "b.txt": "b1", "c.txt": "<missing>"}) {
+		t.Fatalf("files %v", got)
+	}
+}
+
+func TestCherryPickRefusesUncommittedChanges(t *testing.T) {
+	feature := setup(t)
+
+	commandtest.WriteFile(t, "a.txt", "local")
+	var dirty *file.DirtyTreeError
+	if err := commandtest.Run(t, &cherry_pick.Command{}, feature[2]); !errors.As(err, &dirty) {
+		t.Fatalf("pick over a local change: %v", err)
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "local" {
+		t.Fatalf("local change overwritten: %q", got)
+	}
+	if got, want := messages(t), []string{"m1", "base"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history %v, want %v", got, want)
+	}
+
+	// a change to a file the pick leaves alone is kept
+	commandtest.MustRun(t, &cherry_pick.Command{}, feature[0])
+	if got := files(t, "a.txt", "b.txt"); !reflect.DeepEqual(got, map[string]string{"a.txt": "local", "b.txt": "b1"}) {
+		t.Fatalf("files %v", got)
+	}
+}
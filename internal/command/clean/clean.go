@@ -0,0 +1,153 @@
+package clean
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type Command struct {
+	dryRun  bool
+	force   bool
+	dirs    bool
+	ignored bool
+	quiet   bool
+}
+
+func (c *Command) Name() string      { return "clean" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Remove untracked files from the working tree" }
+func (c *Command) Usage() string     { return "clean (-n | -f) [-d] [-x] [-q] [<path>...]" }
+func (c *Command) Help() string {
+	return `Remove untracked files from the working tree.
+
+Files are classified as for status: a file is untracked when neither HEAD
+nor the index has it, and ignored when .bvc-ignore rules match it. Ignored
+files are kept unless -x is given. The repository directory, .bvc-pointer
+and repositories nested in the working tree are never touched.
+
+Nothing is removed without -f; -n lists what would be removed, with the
+total size, instead. Without -d, directories holding no tracked file are
+left alone; with -d, each is removed as a whole. A directory that also
+holds ignored files is then only emptied of its untracked files, unless -x
+is given too.
+
+Paths limit the cleaning to those files, directories or globs.
+
+Options:
+  -n, --dry-run    List what would be removed without removing anything.
+  -f, --force      Remove the files.
+  -d               Remove untracked directories too.
+  -x               Remove ignored files too.
+  -q, --quiet      Do not list removed files, only the total.
+
+Usage:
+  bvc clean (-n | -f) [-d] [-x] [-q] [<path>...]
+
+Examples:
+  bvc clean -n
+  bvc clean -f -d
+  bvc clean -f -d -x build/
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.dryRun, "dry-run", false, "list what would be removed")
+	fs.BoolVar(&c.dryRun, "n", false, "alias for --dry-run")
+	fs.BoolVar(&c.force, "force", false, "remove the files")
+	fs.BoolVar(&c.force, "f", false, "alias for --force")
+	fs.BoolVar(&c.dirs, "d", false, "remove untracked directories too")
+	fs.BoolVar(&c.ignored, "x", false, "remove ignored files too")
+	fs.BoolVar(&c.quiet, "quiet", false, "list only the total")
+	fs.BoolVar(&c.quiet, "q", false, "alias for --quiet")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if !c.dryRun && !c.force {
+		return fmt.Errorf("refusing to clean without -f or -n\nusage: %s", c.Usage())
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+
+	var patterns []string
+	for _, arg := range ctx.Args {
+		rel, err := fc.RelPath(arg)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, rel)
+	}
+
+	nextFS, err := r.GetIndexFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+	tracked := make(map[string]bool, len(nextFS.Files))
+	for _, e := range nextFS.Files {
+		tracked[filepath.ToSlash(e.Path)] = true
+	}
+
+	opts := file.CleanOptions{Dirs: c.dirs, Ignored: c.ignored}
+	if len(patterns) > 0 {
+		opts.Match = func(p string) bool {
+			for _, pat := range patterns {
+				if changes.Matches(p, pat) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	items, err := fc.ListUntracked(tracked, opts)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("Nothing to clean")
+		return nil
+	}
+
+	var total int64
+	for _, u := range items {
+		total += u.Size
+	}
+	verb := "Removing"
+	if c.dryRun {
+		verb = "Would remove"
+	}
+	if !c.quiet {
+		for _, u := range items {
+			fmt.Printf("%s %s (%s)\n", verb, u, changes.FormatSize(u.Size))
+		}
+	}
+	if c.dryRun {
+		fmt.Printf("%d path(s) would be removed, %s in total\n", len(items), changes.FormatSize(total))
+		return nil
+	}
+
+	if err := fc.RemoveUntracked(items, c.dirs); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d path(s), %s in total\n", len(items), changes.FormatSize(total))
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
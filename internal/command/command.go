@@ -20,4 +20,22 @@ type Command interface {
 type Context struct {
 	Args  []string
 	Flags *flag.FlagSet
+	// DashDash is set when a "--" ended the flags, so that every argument
+	// in Args came after it.
+	DashDash bool
+}
+
+// ParseArgs parses args with fs and returns the context to run a command
+// with.
+func ParseArgs(fs *flag.FlagSet, args []string) (*Context, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	rest := fs.Args()
+	n := len(args) - len(rest)
+	return &Context{
+		Args:     rest,
+		Flags:    fs,
+		DashDash: n > 0 && args[n-1] == "--",
+	}, nil
 }
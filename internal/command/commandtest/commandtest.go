@@ -0,0 +1,158 @@
+// Package commandtest runs commands against a repository in a temporary
+// directory, for the tests of command packages.
+package commandtest
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commit"
+	initcmd "github.com/keshon/bvc/internal/command/init"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+// Run parses args with the command's flags, as the CLI does, and runs it
+// in the current directory.
+func Run(t *testing.T, c command.Command, args ...string) error {
+	t.Helper()
+	fs := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	c.Flags(fs)
+	ctx, err := command.ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	return c.Run(ctx)
+}
+
+// Output runs a command like Run and returns what it printed to stdout.
+func Output(t *testing.T, c command.Command, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	stdout := os.Stdout
+	os.Stdout = w
+	err = Run(t, c, args...)
+	os.Stdout = stdout
+	w.Close()
+	return string(<-out), err
+}
+
+// MustRun runs a command and fails the test if it returns an error.
+func MustRun(t *testing.T, c command.Command, args ...string) {
+	t.Helper()
+	if err := Run(t, c, args...); err != nil {
+		t.Fatalf("%s %v: %v", c.Name(), args, err)
+	}
+}
+
+// NewRepo creates a repository in a temporary directory and makes it the
+// current directory for the rest of the test.
+func NewRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	MustRun(t, &initcmd.Command{}, "--quiet")
+	return dir
+}
+
+// Commit writes files, adds everything and commits it.
+func Commit(t *testing.T, message string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		WriteFile(t, name, data)
+	}
+	MustRun(t, &add.Command{}, ".")
+	MustRun(t, &commit.Command{}, "-m", message)
+}
+
+// Open opens the repository of the current directory.
+func Open(t *testing.T) *repo.Repository {
+	t.Helper()
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// Commits returns the commits of HEAD's first-parent history, newest first.
+func Commits(t *testing.T) []*meta.Commit {
+	t.Helper()
+	r := Open(t)
+	id, err := r.Meta.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []*meta.Commit
+	for id != "" {
+		cmt, err := r.Meta.GetCommit(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, cmt)
+		id = ""
+		if len(cmt.Parents) > 0 {
+			id = cmt.Parents[0]
+		}
+	}
+	return out
+}
+
+// WriteFile writes a file below the current directory, creating its parents.
+func WriteFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ReadFile reads a file below the current directory; a missing file reads
+// as "<missing>".
+func ReadFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Staged returns the paths of the index tree mapped to their staged
+// content.
+func Staged(t *testing.T) map[string]string {
+	t.Helper()
+	r := Open(t)
+	fs, err := r.GetIndexFileset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]string, len(fs.Files))
+	for _, e := range fs.Files {
+		data, err := r.StoredContent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[filepath.ToSlash(e.Path)] = string(data)
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package commit
 import (
 	"flag"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -11,9 +12,20 @@ import (
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
 	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
 )
 
-type Command struct{}
+type Command struct {
+	messages   messageList
+	allowEmpty bool
+}
+
+// messageList collects repeated -m values; each one becomes a paragraph.
+type messageList []string
+
+func (m *messageList) String() string     { return strings.Join(*m, "\n\n") }
+func (m *messageList) Set(v string) error { *m = append(*m, v); return nil }
 
 func (c *Command) Name() string  { return "commit" }
 func (c *Command) Brief() string { return "Commit staged changes to the current branch" }
@@ -21,38 +33,35 @@ func (c *Command) Usage() string { return `commit -m "<message>" [--allow-empty]
 func (c *Command) Help() string {
 	return `Create a new commit with the staged changes.
 
+The commit records the complete tree: the parent commit's files with the
+staged additions, modifications and deletions applied.
+
+Paths the parent commit does not have are checked for portability as in
+add, following the portable-paths setting or BVC_PORTABLE_PATHS ("warn",
+"refuse" or "off").
+
 Usage:
   commit -m "<message>"               - commit with a given message
-  commit -m "<message>" --allow-empty - empty commit with a given message (no staged files exist)`
+  commit -m "<message>" --allow-empty - commit even if the tree equals the parent's`
 }
 func (c *Command) Aliases() []string              { return []string{"ci"} }
 func (c *Command) Subcommands() []command.Command { return nil }
-func (c *Command) Flags(fs *flag.FlagSet)         {}
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.Var(&c.messages, "message", "commit message (repeatable)")
+	fs.Var(&c.messages, "m", "alias for --message")
+	fs.BoolVar(&c.allowEmpty, "allow-empty", false, "allow a commit without changes")
+}
 
 func (c *Command) Run(ctx *command.Context) error {
-	var messages []string // commit messages
-	var allowEmpty bool   // --allow-empty
-
-	for i := 0; i < len(ctx.Args); i++ {
-		arg := ctx.Args[i]
+	messages := []string(c.messages)
+	allowEmpty := c.allowEmpty
 
+	for _, arg := range ctx.Args {
 		switch {
-		case arg == "-m" && i+1 < len(ctx.Args):
-			messages = append(messages, ctx.Args[i+1])
-			i++
-		case strings.HasPrefix(arg, "-m="):
-			messages = append(messages, strings.TrimPrefix(arg, "-m="))
-		case arg == "--message" && i+1 < len(ctx.Args):
-			messages = append(messages, ctx.Args[i+1])
-			i++
-		case strings.HasPrefix(arg, "--message="):
-			messages = append(messages, strings.TrimPrefix(arg, "--message="))
 		case arg == "--allow-empty":
 			allowEmpty = true
-		default:
-			if len(messages) == 0 {
-				messages = append(messages, arg)
-			}
+		case len(messages) == 0:
+			messages = append(messages, arg)
 		}
 	}
 
@@ -68,35 +77,63 @@ func (c *Command) Run(ctx *command.Context) error {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
 
-	// get staged files
-	stagedFileentries, err := r.Store.FileCtx.LoadIndex()
+	currentBranch, err := r.Meta.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	parent, err := r.Meta.GetLastCommitID(currentBranch.Name)
+	if err != nil {
+		return err
+	}
+
+	// next tree = parent fileset + staged index
+	parentFS := &snapshot.Fileset{}
+	if parent != "" {
+		if parentFS, err = r.GetCommittedFileset(parent); err != nil {
+			return fmt.Errorf("failed to load parent fileset: %w", err)
+		}
+	}
+	index, err := r.Store.FileCtx.LoadIndex()
 	if err != nil {
 		return err
 	}
+	fileset := snapshot.ApplyIndex(parentFS, index)
 
-	if len(stagedFileentries) == 0 && !allowEmpty {
+	changed, removed := snapshot.DiffFilesets(parentFS, &fileset)
+	if len(changed) == 0 && len(removed) == 0 && !allowEmpty {
 		return fmt.Errorf("no staged changes to commit")
 	}
 
-	// create fileset from staged files (or empty fileset if --allow-empty)
-	fileset, err := r.Store.SnapshotCtx.BuildFilesetFromEntries(stagedFileentries)
+	// new paths must not break checkouts on other systems
+	mode, err := r.Store.FileCtx.PortablePathsMode()
 	if err != nil {
 		return err
 	}
-
-	if len(fileset.Files) > 0 {
-		if err := r.Store.SnapshotCtx.WriteAndSave(&fileset); err != nil {
+	if mode != file.PortableOff {
+		known := make(map[string]bool, len(parentFS.Files))
+		for _, e := range parentFS.Files {
+			known[filepath.ToSlash(e.Path)] = true
+		}
+		var paths, added []string
+		for _, e := range fileset.Files {
+			p := filepath.ToSlash(e.Path)
+			paths = append(paths, p)
+			if !known[p] {
+				added = append(added, p)
+			}
+		}
+		problems := file.FilterPathProblems(file.CheckPortablePaths(paths), added)
+		if err := file.ReportPortablePaths(problems, mode); err != nil {
 			return err
 		}
 	}
 
-	// create commit
-	currentBranch, _ := r.Meta.GetCurrentBranch()
-	parent := ""
-	if last, err := r.Meta.GetLastCommitID(currentBranch.Name); err == nil {
-		parent = last
+	// only new and modified files need their blocks stored
+	if err := r.Store.SnapshotCtx.WriteChangesAndSave(&fileset, changed); err != nil {
+		return err
 	}
 
+	// create commit
 	newCommitID := fmt.Sprintf("%x", time.Now().UnixNano())
 	newCommit := meta.Commit{
 		ID:        newCommitID,
@@ -119,11 +156,9 @@ func (c *Command) Run(ctx *command.Context) error {
 		return err
 	}
 
-	// clear staged changes after commit
-	if len(stagedFileentries) > 0 {
-		if err := r.Store.FileCtx.ClearIndex(); err != nil {
-			return err
-		}
+	// the index now matches the new HEAD
+	if err := r.Store.FileCtx.ClearIndex(); err != nil {
+		return err
 	}
 
 	fmt.Println("Committed:", newCommitID)
@@ -136,6 +171,7 @@ func init() {
 			&Command{},
 			middleware.WithDebugArgsPrint(),
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 		),
 	)
 }
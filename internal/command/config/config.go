@@ -0,0 +1,105 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type Command struct {
+	unset bool
+}
+
+func (c *Command) Name() string      { return "config" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Get and set repository settings" }
+func (c *Command) Usage() string     { return "config [<key> [<value>] | --unset <key>]" }
+func (c *Command) Help() string {
+	return `Get and set repository settings.
+
+Settings are stored in the repository directory and apply to everyone
+working in this working tree. Without arguments every setting made is
+listed; with a key its value is printed, and with a value it is set.
+
+Settings:
+  portable-paths    What add and commit do with new paths that would break
+                    checkouts on Windows or macOS (see check-paths): "warn"
+                    (default) prints the problems, "refuse" stops, "off"
+                    skips the check. BVC_PORTABLE_PATHS overrides it for
+                    one run.
+
+Options:
+      --unset    Remove the setting, restoring its default.
+
+Usage:
+  bvc config [<key> [<value>] | --unset <key>]
+
+Examples:
+  bvc config
+  bvc config portable-paths refuse
+  bvc config --unset portable-paths
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.unset, "unset", false, "remove the setting")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	args := ctx.Args
+	if len(args) > 2 || (c.unset && len(args) != 1) {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+	settings, err := fc.LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0:
+		for _, key := range file.SettingKeys() {
+			if v, ok := settings[key]; ok {
+				fmt.Printf("%s = %s\n", key, v)
+			}
+		}
+		return nil
+	case c.unset:
+		if _, ok := settings[args[0]]; !ok {
+			return fmt.Errorf("setting '%s' is not set", args[0])
+		}
+		delete(settings, args[0])
+	case len(args) == 1:
+		v, ok := settings[args[0]]
+		if !ok {
+			return fmt.Errorf("setting '%s' is not set", args[0])
+		}
+		fmt.Println(v)
+		return nil
+	default:
+		if err := file.CheckSetting(args[0], args[1]); err != nil {
+			return err
+		}
+		settings[args[0]] = args[1]
+	}
+	return fc.SaveSettings(settings)
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
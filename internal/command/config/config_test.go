@@ -0,0 +1,42 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/config"
+	bvcconfig "github.com/keshon/bvc/internal/config"
+)
+
+func TestConfig_PortablePaths(t *testing.T) {
+	commandtest.NewRepo(t)
+	t.Setenv(bvcconfig.PortablePathsEnv, "")
+
+	if err := commandtest.Run(t, &config.Command{}, "portable-paths", "sometimes"); err == nil {
+		t.Fatal("accepted an invalid value")
+	}
+	if err := commandtest.Run(t, &config.Command{}, "no-such-key", "on"); err == nil {
+		t.Fatal("accepted an unknown key")
+	}
+	if err := commandtest.Run(t, &config.Command{}, "portable-paths"); err == nil {
+		t.Fatal("printed a setting that is not set")
+	}
+
+	commandtest.MustRun(t, &config.Command{}, "portable-paths", "refuse")
+	commandtest.MustRun(t, &config.Command{}, "portable-paths")
+	commandtest.WriteFile(t, "README.md", "a")
+	commandtest.WriteFile(t, "readme.md", "b")
+	if err := commandtest.Run(t, &add.Command{}, "."); err == nil {
+		t.Fatal("add accepted colliding paths with portable-paths = refuse")
+	}
+
+	t.Setenv(bvcconfig.PortablePathsEnv, "warn")
+	commandtest.MustRun(t, &add.Command{}, ".")
+	t.Setenv(bvcconfig.PortablePathsEnv, "")
+
+	commandtest.MustRun(t, &config.Command{}, "--unset", "portable-paths")
+	if err := commandtest.Run(t, &config.Command{}, "--unset", "portable-paths"); err == nil {
+		t.Fatal("unset a setting that is not set")
+	}
+}
@@ -0,0 +1,108 @@
+package convert
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+type Command struct {
+	dryRun bool
+}
+
+func (c *Command) Name() string      { return "convert" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string {
+	return "Rewrite legacy JSON index and snapshots in the binary format"
+}
+func (c *Command) Usage() string { return "convert [options]" }
+func (c *Command) Help() string {
+	return `Rewrite the index and snapshot objects that are still stored as JSON
+in the compact binary format.
+
+Legacy JSON files are read transparently, so converting is optional; it
+saves disk space and parsing time on large repositories. Each converted
+JSON file is removed once its binary replacement is written.
+
+Options:
+  -n, --dry-run         List the files that would be converted without changing anything.
+
+Usage:
+  bvc convert [options]
+
+Examples:
+  bvc convert
+  bvc convert --dry-run
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.dryRun, "dry-run", false, "list files without converting")
+	fs.BoolVar(&c.dryRun, "n", false, "alias for --dry-run")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var converted []string
+
+	// index
+	fc := r.Store.FileCtx
+	indexPath, legacyPath := fc.IndexPaths()
+	if fc.FS.Exists(legacyPath) {
+		converted = append(converted, legacyPath)
+		if !c.dryRun {
+			if fc.FS.Exists(indexPath) {
+				// the binary index is newer; the JSON one is stale
+				if err := fc.FS.Remove(legacyPath); err != nil {
+					return fmt.Errorf("failed to remove %s: %w", legacyPath, err)
+				}
+			} else {
+				entries, err := fc.LoadIndex()
+				if err != nil {
+					return fmt.Errorf("failed to load index: %w", err)
+				}
+				if err := fc.SaveIndexReplace(entries); err != nil {
+					return fmt.Errorf("failed to convert index: %w", err)
+				}
+			}
+		}
+	}
+
+	// snapshots
+	snapshots, err := r.Store.SnapshotCtx.ConvertLegacy(c.dryRun)
+	converted = append(converted, snapshots...)
+	if err != nil {
+		return err
+	}
+
+	if len(converted) == 0 {
+		fmt.Println("Nothing to convert: repository already uses the binary format")
+		return nil
+	}
+	if c.dryRun {
+		for _, p := range converted {
+			fmt.Println(p)
+		}
+		fmt.Printf("%d file(s) would be converted\n", len(converted))
+		return nil
+	}
+	fmt.Printf("Converted %d file(s)\n", len(converted))
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
@@ -0,0 +1,231 @@
+package diff
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	staged     bool
+	stat       bool
+	nameStatus bool
+	noRenames  bool
+	findCopies bool
+	context    int
+}
+
+func (c *Command) Name() string      { return "diff" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Show changes between commits, index and working tree" }
+func (c *Command) Usage() string {
+	return "diff [--staged] [--stat | --name-status] [<commit> [<commit>]] [-- <path>...]"
+}
+func (c *Command) Help() string {
+	return `Show changes between commits, the index and the working tree.
+
+Forms:
+  diff                      The working tree against the index: changes not
+                            staged yet.
+  diff --staged [<commit>]  The index against HEAD or <commit>: what the
+                            next commit would record.
+  diff <commit>             The working tree against <commit>.
+  diff <commit> <commit>    The second commit against the first.
+
+Untracked files are not shown. Paths after "--", or arguments that are not
+revisions, limit the output to those files, directories or globs. An argument
+that is both a revision and an existing path is refused as ambiguous; put
+"--" before the paths.
+
+Text files up to 1 MiB are shown as unified diffs. Other files are shown by
+their blocks: how many bytes the new version reuses from the old one and how
+many are new, and how many blocks are unchanged, moved to another offset,
+changed or removed.
+
+Renames are found by comparing block lists, so even large binary files are
+matched without reading them: an added file sharing at least half of its
+bytes with a deleted file is shown as renamed (R), with the share as
+similarity. With -C, an added file sharing as much with a file still present
+is shown as copied (C) from it.
+
+Options:
+      --staged         Compare the index instead of the working tree (--cached).
+      --stat           Show a diffstat instead of the patch.
+      --name-status    Show only the status (A, M, D, R, C) and path of each file.
+      --no-renames     Show renames and copies as added and deleted files.
+  -C, --find-copies    Detect copies of unmodified files too.
+  -U <n>               Lines of context around text changes (default 3).
+
+Usage:
+  bvc diff [--staged] [--stat | --name-status] [<commit> [<commit>]] [-- <path>...]
+
+Examples:
+  bvc diff
+  bvc diff --staged
+  bvc diff --stat main~3 main
+  bvc diff --name-status HEAD -- assets/
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.staged, "staged", false, "compare the index with a commit")
+	fs.BoolVar(&c.staged, "cached", false, "alias for --staged")
+	fs.BoolVar(&c.stat, "stat", false, "show a diffstat")
+	fs.BoolVar(&c.nameStatus, "name-status", false, "show status and path only")
+	fs.BoolVar(&c.noRenames, "no-renames", false, "do not detect renames and copies")
+	fs.BoolVar(&c.findCopies, "find-copies", false, "detect copies of unmodified files too")
+	fs.BoolVar(&c.findCopies, "C", false, "alias for --find-copies")
+	fs.IntVar(&c.context, "U", 3, "lines of context")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if c.stat && c.nameStatus {
+		return fmt.Errorf("--stat and --name-status cannot be used together")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	revs, args, err := splitArgs(r, ctx)
+	if err != nil {
+		return err
+	}
+	if len(revs) > 2 || (c.staged && len(revs) > 1) {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+	paths, err := repoPaths(r.Store.FileCtx, args)
+	if err != nil {
+		return err
+	}
+
+	var oldFS, newFS *snapshot.Fileset
+	opts := changes.Options{
+		OldContent: r.StoredContent,
+		NewContent: r.StoredContent,
+		Context:    max(c.context, 0),
+		Color:      isTerminal(os.Stdout),
+	}
+	switch {
+	case len(revs) == 2:
+		if oldFS, err = commitFileset(r, revs[0]); err != nil {
+			return err
+		}
+		if newFS, err = commitFileset(r, revs[1]); err != nil {
+			return err
+		}
+	case c.staged:
+		if len(revs) == 1 {
+			oldFS, err = commitFileset(r, revs[0])
+		} else {
+			oldFS, err = r.GetHeadFileset()
+		}
+		if err != nil {
+			return err
+		}
+		if newFS, err = r.GetIndexFileset(); err != nil {
+			return err
+		}
+	default:
+		if len(revs) == 1 {
+			oldFS, err = commitFileset(r, revs[0])
+		} else {
+			oldFS, err = r.GetIndexFileset()
+		}
+		if err != nil {
+			return err
+		}
+		if newFS, err = r.GetWorkingFileset(); err != nil {
+			return err
+		}
+		opts.NewContent = r.WorkingContent
+	}
+
+	list := changes.Compare(oldFS, newFS)
+	if !c.noRenames {
+		list = changes.DetectRenames(list, oldFS, changes.RenameThreshold, c.findCopies)
+	}
+	list = changes.Filter(list, paths)
+	switch {
+	case c.nameStatus:
+		return changes.WriteNameStatus(os.Stdout, list)
+	case c.stat:
+		if len(list) == 0 {
+			return nil
+		}
+		return changes.WriteStat(os.Stdout, list, opts)
+	}
+	return changes.WritePatch(os.Stdout, list, opts)
+}
+
+// splitArgs separates revisions from paths. Without "--", leading arguments
+// that resolve as revisions are revisions and the rest are paths; one that is
+// both a revision and an existing path is ambiguous.
+func splitArgs(r *repo.Repository, ctx *command.Context) (revs, paths []string, err error) {
+	args := ctx.Args
+	if ctx.DashDash {
+		return nil, args, nil
+	}
+	if i := slices.Index(args, "--"); i >= 0 {
+		return args[:i], args[i+1:], nil
+	}
+	for i, a := range args {
+		if _, err := r.Meta.ResolveRevision(a); err != nil {
+			return args[:i], args[i:], nil
+		}
+		if _, err := os.Lstat(a); err == nil {
+			return nil, nil, fmt.Errorf("ambiguous argument '%s': both a revision and a path\nuse '--' to separate revisions from paths", a)
+		}
+	}
+	return args, nil, nil
+}
+
+// repoPaths turns path arguments into repository-relative patterns.
+func repoPaths(fc *file.FileContext, args []string) ([]string, error) {
+	var out []string
+	for _, arg := range args {
+		rel, err := fc.RelPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rel)
+	}
+	return out, nil
+}
+
+func commitFileset(r *repo.Repository, rev string) (*snapshot.Fileset, error) {
+	id, err := r.Meta.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := r.GetCommittedFileset(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fileset of commit %s: %w", id, err)
+	}
+	return fs, nil
+}
+
+// isTerminal reports whether f is a terminal, where colors are wanted.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
@@ -0,0 +1,22 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/diff"
+)
+
+func TestDiff_AmbiguousArgument(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"main": "a file named like the branch"})
+
+	err := commandtest.Run(t, &diff.Command{}, "main")
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected an ambiguous argument error, got %v", err)
+	}
+	commandtest.MustRun(t, &diff.Command{}, "main", "--")
+	commandtest.MustRun(t, &diff.Command{}, "--", "main")
+	commandtest.MustRun(t, &diff.Command{}, "--stat", "--", "main")
+}
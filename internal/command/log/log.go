@@ -3,6 +3,7 @@ package log
 import (
 	"flag"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -11,15 +12,19 @@ import (
 	"github.com/keshon/bvc/internal/config"
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
 	"github.com/keshon/bvc/internal/repo/meta"
 )
 
 type Command struct {
-	all     bool
-	oneline bool
-	limit   int
-	since   string
-	until   string
+	all        bool
+	oneline    bool
+	nameStatus bool
+	noRenames  bool
+	findCopies bool
+	limit      int
+	since      string
+	until      string
 }
 
 func (c *Command) Name() string      { return "log" }
@@ -29,9 +34,18 @@ func (c *Command) Brief() string     { return "Show commit history (current bran
 func (c *Command) Help() string {
 	return `Show commit logs.
 
+With --name-status, each commit is followed by the files it changed against
+its first parent. Renames (R) are detected by comparing block lists: a file
+sharing at least half of its bytes with a deleted file was renamed from it.
+With -C, a file sharing as much with a file still present was copied (C)
+from it.
+
 Options:
   -a, --all             Show commits from all branches.
       --oneline         Show each commit as a single line (ID + message).
+      --name-status     List the files each commit changed, with their status.
+      --no-renames      Show renames and copies as added and deleted files.
+  -C, --find-copies     Detect copies of unmodified files too.
   -n <count>            Limit to the last N commits.
       --since <date>    Show commits after the given date (YYYY-MM-DD).
       --until <date>    Show commits before the given date (YYYY-MM-DD).
@@ -43,6 +57,7 @@ Examples:
   bvc log
   bvc log -a
   bvc log --oneline -n 10
+  bvc log --name-status -n 3
   bvc log main
 `
 }
@@ -55,6 +70,10 @@ func (c *Command) Flags(fs *flag.FlagSet) {
 	fs.BoolVar(&c.all, "a", false, "alias for --all")
 
 	fs.BoolVar(&c.oneline, "oneline", false, "show each commit on one line")
+	fs.BoolVar(&c.nameStatus, "name-status", false, "list the files each commit changed")
+	fs.BoolVar(&c.noRenames, "no-renames", false, "do not detect renames and copies")
+	fs.BoolVar(&c.findCopies, "find-copies", false, "detect copies of unmodified files too")
+	fs.BoolVar(&c.findCopies, "C", false, "alias for --find-copies")
 
 	fs.IntVar(&c.limit, "n", 0, "limit number of commits")
 
@@ -100,39 +119,54 @@ func (c *Command) Run(ctx *command.Context) error {
 		branches = []string{cur.Name}
 	}
 
-	var commits []*meta.Commit
-	seen := make(map[string]bool)
-
+	// resolve branch tips
+	var tips []string
 	for _, branch := range branches {
-		branchCommits, err := r.Meta.GetCommitsForBranch(branch)
+		id, err := r.Meta.GetLastCommitID(branch)
 		if err != nil {
 			return fmt.Errorf("failed to get commits for branch %q: %w", branch, err)
 		}
+		if id != "" {
+			tips = append(tips, id)
+		}
+	}
 
-		for _, cmt := range branchCommits {
-			if seen[cmt.ID] {
-				continue
-			}
-			seen[cmt.ID] = true
+	graph, err := r.Meta.CommitGraphFor(tips...)
+	if err != nil {
+		return fmt.Errorf("failed to load commit graph: %w", err)
+	}
 
-			t, err := time.Parse(time.RFC3339, cmt.Timestamp)
-			if err != nil {
-				continue
-			}
-			if since != "" {
-				s, _ := time.Parse("2006-01-02", since)
-				if t.Before(s) {
-					continue
-				}
-			}
-			if until != "" {
-				u, _ := time.Parse("2006-01-02", until)
-				if t.After(u) {
-					continue
-				}
-			}
+	var sinceTime, untilTime time.Time
+	if since != "" {
+		sinceTime, _ = time.Parse("2006-01-02", since)
+	}
+	if until != "" {
+		untilTime, _ = time.Parse("2006-01-02", until)
+	}
+
+	// walk history newest first; only the commits we print are read from disk
+	var commits []*meta.Commit
+	for _, id := range graph.Ancestors(tips...) {
+		node, _ := graph.Node(id)
+		if node.Timestamp == 0 {
+			continue
+		}
+		t := time.Unix(node.Timestamp, 0)
+		if since != "" && t.Before(sinceTime) {
+			continue
+		}
+		if until != "" && t.After(untilTime) {
+			continue
+		}
+
+		cmt, err := r.Meta.GetCommit(id)
+		if err != nil {
+			return fmt.Errorf("failed to read commit %q: %w", id, err)
+		}
+		commits = append(commits, cmt)
 
-			commits = append(commits, cmt)
+		if n > 0 && len(commits) >= n {
+			break
 		}
 	}
 
@@ -141,14 +175,14 @@ func (c *Command) Run(ctx *command.Context) error {
 		return nil
 	}
 
-	sort.Slice(commits, func(i, j int) bool {
-		ti, _ := time.Parse(time.RFC3339, commits[i].Timestamp)
-		tj, _ := time.Parse(time.RFC3339, commits[j].Timestamp)
-		return ti.After(tj)
-	})
-
-	if n > 0 && n < len(commits) {
-		commits = commits[:n]
+	cur, _ := r.Meta.GetCurrentBranch()
+	headBranch := ""
+	if cur != nil {
+		headBranch = cur.Name
+	}
+	refsByCommit, err := collectRefs(r.Meta, headBranch)
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
 	}
 
 	if oneline {
@@ -157,16 +191,16 @@ func (c *Command) Run(ctx *command.Context) error {
 			short := cmt.ID[:7]
 			msg := strings.SplitN(cmt.Message, "\n", 2)[0]
 
-			var refs []string
-
-			cur, _ := r.Meta.GetCurrentBranch()
-			refs, _ = findRefsForCommit(r.Meta, cmt.ID, cur.Name)
+			refs := refsByCommit[cmt.ID]
 
 			if len(refs) > 0 {
 				fmt.Printf("%s (%s) %s\n", short, strings.Join(refs, ", "), msg)
 			} else {
 				fmt.Printf("%s %s\n", short, msg)
 			}
+			if err := c.printChanges(r, cmt); err != nil {
+				return err
+			}
 		}
 
 	} else {
@@ -178,16 +212,12 @@ func (c *Command) Run(ctx *command.Context) error {
 			fmt.Printf("\033[33mcommit\033[0m %s", cmt.ID)
 
 			// build list of refs just like Git
-			var refs []string
-
-			// refs
-			cur, _ := r.Meta.GetCurrentBranch()
-			refs, _ = findRefsForCommit(r.Meta, cmt.ID, cur.Name)
+			refs := append([]string(nil), refsByCommit[cmt.ID]...)
 
 			// branch ref itself
 			if cmt.Branch != "" {
 				// Don't duplicate if already in HEAD -> main
-				if cur == nil || cur.Name != cmt.Branch {
+				if headBranch != cmt.Branch {
 					refs = append(refs, cmt.Branch)
 				}
 			}
@@ -220,6 +250,12 @@ func (c *Command) Run(ctx *command.Context) error {
 			}
 
 			fmt.Println()
+			if c.nameStatus {
+				if err := c.printChanges(r, cmt); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
 		}
 
 	}
@@ -227,35 +263,51 @@ func (c *Command) Run(ctx *command.Context) error {
 	return nil
 }
 
-func findRefsForCommit(mc *meta.MetaContext, commitID string, headBranch string) ([]string, error) {
+// printChanges lists the files a commit changed when --name-status is set.
+func (c *Command) printChanges(r *repo.Repository, cmt *meta.Commit) error {
+	if !c.nameStatus {
+		return nil
+	}
+	list, err := r.CommitChanges(cmt, !c.noRenames, c.findCopies)
+	if err != nil {
+		return err
+	}
+	return changes.WriteNameStatus(os.Stdout, list)
+}
+
+// collectRefs maps each branch tip commit to its ref labels, listing branches only once.
+func collectRefs(mc *meta.MetaContext, headBranch string) (map[string][]string, error) {
 	branches, err := mc.ListBranches()
 	if err != nil {
 		return nil, err
 	}
 
-	var refs []string
-
+	refs := make(map[string][]string)
 	for _, b := range branches {
 		id, err := mc.GetLastCommitID(b.Name)
-		if err != nil {
+		if err != nil || id == "" {
 			continue
 		}
-		if id == commitID {
-			if b.Name == headBranch {
-				refs = append(refs, fmt.Sprintf("HEAD -> %s", b.Name))
-			} else {
-				refs = append(refs, b.Name)
-			}
+		if b.Name == headBranch {
+			refs[id] = append(refs[id], fmt.Sprintf("HEAD -> %s", b.Name))
+		} else {
+			refs[id] = append(refs[id], b.Name)
 		}
 	}
 
 	// Sort for consistency: HEAD first
-	sort.Slice(refs, func(i, j int) bool {
-		if strings.HasPrefix(refs[i], "HEAD ->") {
-			return true
-		}
-		return refs[i] < refs[j]
-	})
+	for id := range refs {
+		list := refs[id]
+		sort.Slice(list, func(i, j int) bool {
+			if strings.HasPrefix(list[i], "HEAD ->") {
+				return true
+			}
+			if strings.HasPrefix(list[j], "HEAD ->") {
+				return false
+			}
+			return list[i] < list[j]
+		})
+	}
 
 	return refs, nil
 }
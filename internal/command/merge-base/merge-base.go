@@ -0,0 +1,98 @@
+package merge_base
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+type Command struct {
+	all        bool
+	isAncestor bool
+}
+
+func (c *Command) Name() string      { return "merge-base" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Find the best common ancestor of two commits" }
+func (c *Command) Usage() string     { return "merge-base [options] <rev> <rev>" }
+func (c *Command) Help() string {
+	return `Print the best common ancestor of two revisions, as used by merge.
+
+Options:
+      --all             Print all best common ancestors, one per line.
+      --is-ancestor     Print "true" if the first revision is an ancestor of the second, "false" otherwise.
+
+Revisions may be branch names, HEAD, full or abbreviated commit IDs,
+optionally followed by ^, ^N or ~N.
+
+Usage:
+  bvc merge-base [options] <rev> <rev>
+
+Examples:
+  bvc merge-base main feature
+  bvc merge-base --all main feature
+  bvc merge-base --is-ancestor main~3 main
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.all, "all", false, "print all best common ancestors")
+	fs.BoolVar(&c.isAncestor, "is-ancestor", false, "check whether the first revision is an ancestor of the second")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) != 2 {
+		return fmt.Errorf("exactly two revisions required")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	a, err := r.Meta.ResolveRevision(ctx.Args[0])
+	if err != nil {
+		return err
+	}
+	b, err := r.Meta.ResolveRevision(ctx.Args[1])
+	if err != nil {
+		return err
+	}
+
+	graph, err := r.Meta.CommitGraphFor(a, b)
+	if err != nil {
+		return fmt.Errorf("failed to load commit graph: %w", err)
+	}
+
+	if c.isAncestor {
+		fmt.Println(graph.IsAncestor(a, b))
+		return nil
+	}
+
+	if c.all {
+		for _, id := range graph.MergeBases(a, b) {
+			fmt.Println(id)
+		}
+		return nil
+	}
+
+	base := graph.MergeBase(a, b)
+	if base == "" {
+		return fmt.Errorf("no common ancestor between %s and %s", ctx.Args[0], ctx.Args[1])
+	}
+	fmt.Println(base)
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
@@ -2,21 +2,18 @@ package merge
 
 import (
 	"fmt"
-	"path/filepath"
-	"sort"
 	"time"
 
 	"github.com/keshon/bvc/internal/config"
 	"github.com/keshon/bvc/internal/repo"
 	"github.com/keshon/bvc/internal/repo/meta"
-	"github.com/keshon/bvc/internal/repo/store/file"
 	"github.com/keshon/bvc/internal/repo/store/snapshot"
 
 	"github.com/zeebo/xxh3"
 )
 
-// findCommonAncestor walks commit history to find merge base.
-// Returns commit ID or error if no common ancestor found.
+// findCommonAncestor finds the merge base of two commits using the commit-graph.
+// Returns "" if the commits share no history.
 func findCommonAncestor(aCommitID, bCommitID string) (string, error) {
 	if aCommitID == "" || bCommitID == "" {
 		return "", nil
@@ -27,166 +24,8 @@ func findCommonAncestor(aCommitID, bCommitID string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to open repository: %w", err)
 	}
-	seen := map[string]bool{}
-	// walk a's ancestors (including a)
-	var stack []string
-	stack = append(stack, aCommitID)
-	for len(stack) > 0 {
-		commitID := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		if commitID == "" || seen[commitID] {
-			continue
-		}
-		seen[commitID] = true
-
-		var c *meta.Commit
-		c, err := r.Meta.GetCommit(commitID)
-		if err != nil {
-			continue
-		}
-
-		for _, p := range c.Parents {
-			if p != "" {
-				stack = append(stack, p)
-			}
-		}
-	}
-
-	// walk b's ancestors breadth-first and find first that exists in seen
-	queue := []string{bCommitID}
-	visited := map[string]bool{}
-	for len(queue) > 0 {
-		commitID := queue[0]
-		queue = queue[1:]
-		if commitID == "" || visited[commitID] {
-			continue
-		}
-		visited[commitID] = true
-		if seen[commitID] {
-			return commitID, nil
-		}
-
-		var c *meta.Commit
-		c, err := r.Meta.GetCommit(commitID)
-		if err != nil {
-			continue
-		}
-
-		for _, p := range c.Parents {
-			if p != "" {
-				queue = append(queue, p)
-			}
-		}
-	}
-
-	return "", nil // no common ancestor
-
-}
-
-// mergeFilesets performs three-way merge of filesets.
-// Returns merged fileset and list of conflicting paths.
-func mergeFilesets(base, ours, theirs *snapshot.Fileset) (snapshot.Fileset, []string) {
-	// returns merged fileset and list of conflict paths
-	conflicts := []string{}
-	mergedMap := map[string]file.Entry{}
-
-	// Create maps for quick lookup
-	baseMap := map[string]file.Entry{}
-	for _, f := range base.Files {
-		baseMap[filepath.Clean(f.Path)] = f
-	}
-	oursMap := map[string]file.Entry{}
-	for _, f := range ours.Files {
-		oursMap[filepath.Clean(f.Path)] = f
-	}
-	theirsMap := map[string]file.Entry{}
-	for _, f := range theirs.Files {
-		theirsMap[filepath.Clean(f.Path)] = f
-	}
-
-	// union of all paths
-	allPaths := map[string]bool{}
-	for p := range baseMap {
-		allPaths[p] = true
-	}
-	for p := range oursMap {
-		allPaths[p] = true
-	}
-	for p := range theirsMap {
-		allPaths[p] = true
-	}
-
-	for path := range allPaths {
-		var b *file.Entry
-		var o *file.Entry
-		var t *file.Entry
-
-		if v, ok := baseMap[path]; ok {
-			tmp := v
-			b = &tmp
-		}
-		if v, ok := oursMap[path]; ok {
-			o = &v
-		}
-		if v, ok := theirsMap[path]; ok {
-			t = &v
-		}
-
-		// Cases
-		switch {
-		// identical theirs and ours -> take either (or nothing if both nil)
-		case o.Equal(t):
-			if o != nil {
-				mergedMap[path] = *o
-			}
-			// if both nil -> deleted in both -> skip
-
-		// unchanged in ours (base == ours) -> take theirs (could be add, modify, or delete)
-		case b.Equal(o):
-			if t != nil {
-				mergedMap[path] = *t
-			} else {
-				// theirs deleted -> delete in merged
-				// i.e. do nothing (omit from mergedMap)
-			}
-
-		// unchanged in theirs (base == theirs) -> take ours
-		case b.Equal(t):
-			if o != nil {
-				mergedMap[path] = *o
-			} else {
-				// ours deleted -> deleted in merged
-			}
-
-		// conflict: both changed differently since base (or base nil and both changed differently)
-		default:
-			// Conflict resolution policy: keep ours, write theirs to .MERGE_THEIRS
-			if o != nil {
-				mergedMap[path] = *o
-			}
-			if t != nil {
-				// create duplicate path for their version
-				conflictPath := path + ".MERGE_THEIRS"
-				theirsCopy := *t
-				theirsCopy.Path = conflictPath
-				mergedMap[conflictPath] = theirsCopy
-				conflicts = append(conflicts, path)
-			}
-		}
-	}
-
-	// build Fileset struct
-	mergedFiles := make([]file.Entry, 0, len(mergedMap))
-	for _, f := range mergedMap {
-		mergedFiles = append(mergedFiles, f)
-	}
-	// deterministic order
-	sort.SliceStable(mergedFiles, func(i, j int) bool {
-		return filepath.Clean(mergedFiles[i].Path) < filepath.Clean(mergedFiles[j].Path)
-	})
 
-	filesetID := snapshot.HashFileset(mergedFiles)
-	return snapshot.Fileset{ID: filesetID, Files: mergedFiles}, conflicts
+	return r.Meta.MergeBase(aCommitID, bCommitID)
 }
 
 // merge executes a full merge operation between branches.
@@ -236,7 +75,7 @@ func merge(currentBranch, targetBranch string) error {
 	}
 
 	// perform three-way merge
-	mergedFS, conflicts := mergeFilesets(baseFS, oursFS, theirsFS)
+	mergedFS, conflicts := snapshot.MergeFilesetsWith(baseFS, oursFS, theirsFS, r.Store.FileCtx.Attributes().MergeStrategy)
 
 	// save merged fileset
 	r.Store.SnapshotCtx.Save(mergedFS)
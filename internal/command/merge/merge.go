@@ -56,6 +56,7 @@ func init() {
 			&Command{},
 			middleware.WithDebugArgsPrint(),
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 		),
 	)
 }
@@ -0,0 +1,216 @@
+package mv
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	force bool
+}
+
+func (c *Command) Name() string      { return "mv" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Move or rename a file or directory" }
+func (c *Command) Usage() string     { return "mv [-f] <source>... <destination>" }
+func (c *Command) Help() string {
+	return `Move or rename tracked files and directories.
+
+The move is made in the working tree and staged in the index at once: the
+index keeps the staged content under the new path, so nothing is read or
+stored again however large the files are, and status, diff and log show
+the move as a rename. Changes not staged yet move along with the file.
+
+With several sources, or when the destination is an existing directory,
+each source is moved into it. A destination that exists is not overwritten
+unless -f is given.
+
+Options:
+  -f, --force    Overwrite an existing destination file.
+
+Usage:
+  bvc mv [-f] <source>... <destination>
+
+Examples:
+  bvc mv hero.psd hero_v2.psd
+  bvc mv textures/ assets/textures
+  bvc mv a.png b.png icons/
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.force, "force", false, "overwrite an existing destination")
+	fs.BoolVar(&c.force, "f", false, "alias for --force")
+}
+
+// move is one source and where it goes, both repository-relative.
+type move struct {
+	from, to string
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) < 2 {
+		return fmt.Errorf("source and destination required\nusage: %s", c.Usage())
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	nextFS, err := r.GetIndexFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+	next := make(map[string]file.Entry, len(nextFS.Files))
+	for _, e := range nextFS.Files {
+		next[filepath.ToSlash(e.Path)] = e
+	}
+
+	args := ctx.Args
+	dst, err := fc.RelPath(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	intoDir := fc.FS.IsDir(filepath.Join(fc.WorkingTreeDir, dst))
+	if len(args) > 2 && !intoDir {
+		return fmt.Errorf("destination '%s' is not a directory", args[len(args)-1])
+	}
+
+	var moves []move
+	targets := map[string]string{} // target -> source
+	for _, arg := range args[:len(args)-1] {
+		src, err := fc.RelPath(arg)
+		if err != nil {
+			return err
+		}
+		if src == "." {
+			return fmt.Errorf("can not move the working tree root")
+		}
+		to := dst
+		if intoDir {
+			to = path.Join(dst, path.Base(src))
+		}
+		if prev, ok := targets[to]; ok {
+			return fmt.Errorf("can not move both '%s' and '%s' to '%s'", prev, src, to)
+		}
+		targets[to] = src
+		if err := c.check(fc, next, src, to); err != nil {
+			return err
+		}
+		moves = append(moves, move{from: src, to: to})
+	}
+
+	// the index follows every move made, even when a later one fails
+	var done []move
+	var moveErr error
+	for _, m := range moves {
+		if moveErr = c.apply(fc, next, m); moveErr != nil {
+			break
+		}
+		done = append(done, m)
+	}
+
+	updated := make([]file.Entry, 0, len(next))
+	for _, e := range next {
+		updated = append(updated, e)
+	}
+	updatedFS := snapshot.Fileset{Files: updated}
+	if err := fc.SaveIndexReplace(snapshot.IndexDelta(headFS, &updatedFS)); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+
+	for _, m := range done {
+		fmt.Printf("Renamed %s -> %s\n", m.from, m.to)
+	}
+	return moveErr
+}
+
+// apply makes one move in the working tree, then in next.
+func (c *Command) apply(fc *file.FileContext, next map[string]file.Entry, m move) error {
+	if c.force {
+		if err := fc.RemoveFromWorkingTree([]string{m.to}); err != nil {
+			return err
+		}
+		delete(next, m.to)
+	}
+	if err := fc.MoveInWorkingTree(m.from, m.to); err != nil {
+		return err
+	}
+	for p, e := range next {
+		if rest, ok := below(p, m.from); ok {
+			delete(next, p)
+			e.Path = path.Join(m.to, rest)
+			next[e.Path] = e
+		}
+	}
+	return nil
+}
+
+// check validates one move against the index and the working tree.
+func (c *Command) check(fc *file.FileContext, next map[string]file.Entry, src, to string) error {
+	tracked := false
+	for p := range next {
+		if _, ok := below(p, src); ok {
+			tracked = true
+			break
+		}
+	}
+	if !tracked {
+		return fmt.Errorf("'%s' is not under version control", src)
+	}
+	if _, err := fc.FS.Lstat(filepath.Join(fc.WorkingTreeDir, src)); err != nil {
+		return fmt.Errorf("bad source '%s': %w", src, err)
+	}
+	if _, ok := below(to, src); ok {
+		return fmt.Errorf("can not move '%s' into itself", src)
+	}
+
+	abs := filepath.Join(fc.WorkingTreeDir, to)
+	if _, err := fc.FS.Lstat(abs); err == nil {
+		if fc.FS.IsDir(abs) {
+			return fmt.Errorf("destination '%s' already exists", to)
+		}
+		if !c.force {
+			return fmt.Errorf("destination '%s' already exists (use -f to overwrite)", to)
+		}
+	}
+	return nil
+}
+
+// below reports whether p is dir or lies below it, and returns the rest of
+// the path.
+func below(p, dir string) (string, bool) {
+	if p == dir {
+		return "", true
+	}
+	if rest, ok := strings.CutPrefix(p, dir+"/"); ok {
+		return rest, true
+	}
+	return "", false
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+			middleware.WithInterruptedRestoreCheck(),
+		),
+	)
+}
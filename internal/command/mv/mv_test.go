@@ -0,0 +1,56 @@
+package mv_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/mv"
+)
+
+func TestMv(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{
+		"a.bin":       "a",
+		"art/b.bin":   "b",
+		"art/c/d.bin": "d",
+		"dst/keep":    "k",
+	})
+
+	commandtest.MustRun(t, &mv.Command{}, "a.bin", "renamed.bin")
+	commandtest.MustRun(t, &mv.Command{}, "art", "assets")
+
+	want := map[string]string{"renamed.bin": "a", "assets/b.bin": "b", "assets/c/d.bin": "d", "dst/keep": "k"}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged %v, want %v", got, want)
+	}
+	for p, data := range want {
+		if got := commandtest.ReadFile(t, p); got != data {
+			t.Fatalf("%s = %q, want %q", p, got, data)
+		}
+	}
+	if got := commandtest.ReadFile(t, "art/b.bin"); got != "<missing>" {
+		t.Fatalf("art/b.bin still on disk: %q", got)
+	}
+
+	if err := commandtest.Run(t, &mv.Command{}, "renamed.bin", "dst/keep"); err == nil {
+		t.Fatal("overwrote an existing file without -f")
+	}
+	if err := commandtest.Run(t, &mv.Command{}, "untracked", "x"); err == nil {
+		t.Fatal("moved a path that is not tracked")
+	}
+}
+
+func TestMvRejectsDuplicateTargets(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"p/x.bin": "p", "q/x.bin": "q", "d/keep": "k"})
+
+	if err := commandtest.Run(t, &mv.Command{}, "p/x.bin", "q/x.bin", "d"); err == nil {
+		t.Fatal("moved two sources to the same target")
+	}
+	for p, data := range map[string]string{"p/x.bin": "p", "q/x.bin": "q", "d/x.bin": "<missing>"} {
+		if got := commandtest.ReadFile(t, p); got != data {
+			t.Fatalf("%s = %q, want %q", p, got, data)
+		}
+	}
+}
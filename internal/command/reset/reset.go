@@ -8,30 +8,51 @@ import (
 	"github.com/keshon/bvc/internal/config"
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
 )
 
 type Command struct {
 	soft  bool
 	mixed bool
 	hard  bool
+	force bool
+
+	continueOpt bool
+	abort       bool
 }
 
 func (c *Command) Name() string      { return "reset" }
 func (c *Command) Aliases() []string { return []string{"drop"} }
-func (c *Command) Usage() string     { return "reset [<commit-id>] [--soft|--mixed|--hard]" }
-func (c *Command) Brief() string     { return "Reset current branch to a commit or HEAD" }
+func (c *Command) Usage() string {
+	return "reset [--soft|--mixed|--hard [--force]] [<commit-id>] | reset --continue | reset --abort"
+}
+func (c *Command) Brief() string { return "Reset current branch to a commit or HEAD" }
 func (c *Command) Help() string {
 	return `Reset current branch.
 
 Options:
-  --soft  : move HEAD only
+  --soft  : move HEAD only; the previously staged tree stays staged
   --mixed : move HEAD and reset index (default)
   --hard  : move HEAD, reset index and working directory
+  --force : with --hard, discard uncommitted changes
+  --continue : finish an interrupted reset --hard
+  --abort    : undo an interrupted reset --hard
+
+--hard writes only the files that differ from the target commit and deletes
+tracked files the commit does not have. It refuses to overwrite uncommitted
+changes, or untracked files in the way, unless --force is given.
+
+Progress of --hard is recorded as files are written. If it is interrupted,
+HEAD is not moved and other commands refuse to run until the reset is
+finished with --continue or undone with --abort.
 
 If <commit-id> is omitted, the last commit is used.
 
 Usage:
-  bvc reset [<commit-id>] [--soft|--mixed|--hard]
+  bvc reset [--soft|--mixed|--hard [--force]] [<commit-id>]
+  bvc reset --continue
+  bvc reset --abort
 
 Examples:
   bvc reset
@@ -42,6 +63,7 @@ Examples:
   bvc reset --soft <commit-id>
   bvc reset --mixed <commit-id>
   bvc reset --hard <commit-id>
+  bvc reset --hard --force
 `
 }
 
@@ -51,6 +73,10 @@ func (c *Command) Flags(fs *flag.FlagSet) {
 	fs.BoolVar(&c.soft, "soft", false, "move HEAD only")
 	fs.BoolVar(&c.mixed, "mixed", false, "move HEAD and reset index (default)")
 	fs.BoolVar(&c.hard, "hard", false, "move HEAD, reset index and working directory")
+	fs.BoolVar(&c.force, "force", false, "with --hard, discard uncommitted changes")
+	fs.BoolVar(&c.force, "f", false, "alias for --force")
+	fs.BoolVar(&c.continueOpt, "continue", false, "finish an interrupted reset --hard")
+	fs.BoolVar(&c.abort, "abort", false, "undo an interrupted reset --hard")
 }
 
 func (c *Command) Run(ctx *command.Context) error {
@@ -74,9 +100,19 @@ func (c *Command) Run(ctx *command.Context) error {
 		count++
 	}
 
+	if c.continueOpt || c.abort {
+		if count > 0 || c.force || len(ctx.Args) > 0 || (c.continueOpt && c.abort) {
+			return fmt.Errorf("--continue and --abort take no other options")
+		}
+		return c.resume()
+	}
+
 	if count > 1 {
 		return fmt.Errorf("conflicting reset modes specified")
 	}
+	if c.force && !hard {
+		return fmt.Errorf("--force is only valid with --hard")
+	}
 
 	// repo open once
 	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
@@ -119,23 +155,38 @@ func (c *Command) Run(ctx *command.Context) error {
 func (c *Command) reset(r *repo.Repository, branchName, targetID, filesetID, mode string) error {
 	fmt.Printf("Resetting branch '%s' to commit %s (%s)...\n", branchName, targetID, mode)
 
-	// move HEAD for all modes
+	// the staged tree is captured before HEAD moves so --soft can keep it
+	var staged *snapshot.Fileset
+	if mode == "soft" {
+		next, err := r.GetIndexFileset()
+		if err != nil {
+			return err
+		}
+		staged = next
+	}
+
+	// the working tree is updated first: it may refuse to discard changes,
+	// and HEAD and the index only move once it is done
+	if mode == "hard" {
+		if err := c.resetWorkingDirectory(r, branchName, targetID); err != nil {
+			return err
+		}
+		fmt.Println("Reset complete.")
+		return nil
+	}
+
+	// move HEAD
 	if err := r.Meta.SetLastCommitID(branchName, targetID); err != nil {
 		return err
 	}
 
 	switch mode {
 	case "soft":
-		// nothing else
-	case "mixed":
-		if err := c.resetIndex(r, filesetID); err != nil {
-			return err
-		}
-	case "hard":
-		if err := c.resetIndex(r, filesetID); err != nil {
+		if err := c.keepIndex(r, filesetID, staged); err != nil {
 			return err
 		}
-		if err := c.resetWorkingDirectory(r, filesetID); err != nil {
+	case "mixed":
+		if err := c.resetIndex(r); err != nil {
 			return err
 		}
 	default:
@@ -146,36 +197,77 @@ func (c *Command) reset(r *repo.Repository, branchName, targetID, filesetID, mod
 	return nil
 }
 
-func (c *Command) resetIndex(r *repo.Repository, filesetID string) error {
-	fs, err := r.Store.SnapshotCtx.Load(filesetID)
-	if err != nil {
-		return err
-	}
-
+// resetIndex makes the index match the new HEAD (no staged changes).
+func (c *Command) resetIndex(r *repo.Repository) error {
 	if err := r.Store.FileCtx.ClearIndex(); err != nil {
 		return err
 	}
-	if err := r.Store.FileCtx.SaveIndexReplace(fs.Files); err != nil {
-		return err
-	}
 
 	fmt.Println("Index reset.")
 	return nil
 }
 
-func (c *Command) resetWorkingDirectory(r *repo.Repository, filesetID string) error {
+// keepIndex re-expresses the previously staged tree against the new HEAD,
+// so the changes between the two commits show up as staged.
+func (c *Command) keepIndex(r *repo.Repository, filesetID string, staged *snapshot.Fileset) error {
 	fs, err := r.Store.SnapshotCtx.Load(filesetID)
 	if err != nil {
 		return err
 	}
+	delta := snapshot.IndexDelta(&fs, staged)
+	if len(delta) == 0 {
+		return r.Store.FileCtx.ClearIndex()
+	}
+	return r.Store.FileCtx.SaveIndexReplace(delta)
+}
 
-	msg := fmt.Sprintf("reset --hard to fileset %s", filesetID)
+// resetWorkingDirectory makes the tracked files match the target commit,
+// then moves HEAD and resets the index.
+func (c *Command) resetWorkingDirectory(r *repo.Repository, branchName, targetID string) error {
+	currentID, err := r.Meta.GetLastCommitID(branchName)
+	if err != nil {
+		return err
+	}
 
-	if err := r.Store.FileCtx.RestoreFilesToWorkingTree(fs.Files, msg); err != nil {
+	j := &meta.RestoreJournal{
+		Command:    c.Name(),
+		Branch:     branchName,
+		FromBranch: branchName,
+		FromCommit: currentID,
+		ToCommit:   targetID,
+		Reset:      true,
+		Force:      c.force,
+		Label:      fmt.Sprintf("reset --hard to commit %s", targetID),
+	}
+	if err := r.Restore(j); err != nil {
 		return err
 	}
 
 	fmt.Println("Working directory reset.")
+	fmt.Println("Index reset.")
+	return nil
+}
+
+// resume finishes or undoes an interrupted reset --hard.
+func (c *Command) resume() error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("open repository: %w", err)
+	}
+	if c.abort {
+		j, err := r.AbortRestore()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reset aborted, branch '%s' stays at commit %s\n", j.FromBranch, j.FromCommit)
+		return nil
+	}
+	j, err := r.ContinueRestore()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Branch '%s' reset to commit %s.\n", j.Branch, j.ToCommit)
+	fmt.Println("Reset complete.")
 	return nil
 }
 
@@ -185,6 +277,7 @@ func init() {
 			&Command{},
 			middleware.WithDebugArgsPrint(),
 			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
 		),
 	)
 }
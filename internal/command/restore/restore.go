@@ -0,0 +1,195 @@
+package restore
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	staged   bool
+	worktree bool
+	source   string
+}
+
+func (c *Command) Name() string      { return "restore" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Restore files in the working tree or the index" }
+func (c *Command) Usage() string {
+	return "restore [--staged] [--worktree] [--source=<revision>] <path>..."
+}
+func (c *Command) Help() string {
+	return `Restore paths in the working tree or the index from a source.
+
+By default the working tree is restored from the index, discarding changes
+not staged yet. With --staged the index is restored from HEAD, unstaging
+changes. Give both to restore both from HEAD. --source restores from any
+revision instead.
+
+Paths may be files, directories or globs. Only the given paths are touched:
+HEAD does not move and other files are left alone. A tracked path the source
+does not have is removed, from the index with --staged and from the working
+tree with --worktree.
+
+Options:
+  -S, --staged              Restore the index.
+  -W, --worktree            Restore the working tree (default without --staged).
+  -s, --source=<revision>   Restore from this revision.
+
+Usage:
+  bvc restore [--staged] [--worktree] [--source=<revision>] <path>...
+
+Examples:
+  bvc restore notes.txt
+  bvc restore --staged assets/
+  bvc restore --source=main~2 --staged --worktree "*.psd"
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.staged, "staged", false, "restore the index")
+	fs.BoolVar(&c.staged, "S", false, "alias for --staged")
+	fs.BoolVar(&c.worktree, "worktree", false, "restore the working tree")
+	fs.BoolVar(&c.worktree, "W", false, "alias for --worktree")
+	fs.StringVar(&c.source, "source", "", "revision to restore from")
+	fs.StringVar(&c.source, "s", "", "alias for --source")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("path required\nusage: %s", c.Usage())
+	}
+	worktree := c.worktree || !c.staged
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+
+	var patterns []string
+	for _, arg := range ctx.Args {
+		rel, err := fc.RelPath(arg)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, rel)
+	}
+
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	nextFS, err := r.GetIndexFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	// the working tree comes from the index unless the index is restored too
+	var sourceFS *snapshot.Fileset
+	switch {
+	case c.source != "":
+		id, err := r.Meta.ResolveRevision(c.source)
+		if err != nil {
+			return err
+		}
+		if sourceFS, err = r.GetCommittedFileset(id); err != nil {
+			return fmt.Errorf("failed to load fileset of commit %s: %w", id, err)
+		}
+	case c.staged:
+		sourceFS = headFS
+	default:
+		sourceFS = nextFS
+	}
+
+	restore := matching(sourceFS.Files, patterns)
+	inSource := make(map[string]bool, len(restore))
+	for _, e := range restore {
+		inSource[filepath.ToSlash(e.Path)] = true
+	}
+	tracked := matching(nextFS.Files, patterns)
+	var remove []string
+	for _, e := range tracked {
+		if p := filepath.ToSlash(e.Path); !inSource[p] {
+			remove = append(remove, p)
+		}
+	}
+	for _, pat := range patterns {
+		if len(matching(restore, []string{pat})) == 0 && len(matching(tracked, []string{pat})) == 0 {
+			return fmt.Errorf("pathspec '%s' did not match any file known to bvc", pat)
+		}
+	}
+
+	if c.staged {
+		next := make(map[string]file.Entry, len(nextFS.Files))
+		for _, e := range nextFS.Files {
+			next[filepath.ToSlash(e.Path)] = e
+		}
+		for _, p := range remove {
+			delete(next, p)
+		}
+		for _, e := range restore {
+			next[filepath.ToSlash(e.Path)] = e
+		}
+		updated := make([]file.Entry, 0, len(next))
+		for _, e := range next {
+			updated = append(updated, e)
+		}
+		updatedFS := snapshot.Fileset{Files: updated}
+		if err := fc.SaveIndexReplace(snapshot.IndexDelta(headFS, &updatedFS)); err != nil {
+			return fmt.Errorf("failed to update index: %w", err)
+		}
+	}
+
+	if worktree {
+		if err := fc.RestoreFilesToWorkingTree(restore, "files"); err != nil {
+			return err
+		}
+		if err := fc.RemoveFromWorkingTree(remove); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Restored %d path(s)", len(restore)+len(remove))
+	switch {
+	case c.staged && worktree:
+		fmt.Println(" in the index and working tree")
+	case c.staged:
+		fmt.Println(" in the index")
+	default:
+		fmt.Println(" in the working tree")
+	}
+	return nil
+}
+
+// matching returns the entries matching any of the patterns.
+func matching(entries []file.Entry, patterns []string) []file.Entry {
+	var out []file.Entry
+	for _, e := range entries {
+		for _, pat := range patterns {
+			if changes.Matches(filepath.ToSlash(e.Path), pat) {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+			middleware.WithInterruptedRestoreCheck(),
+		),
+	)
+}
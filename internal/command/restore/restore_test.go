@@ -0,0 +1,83 @@
+package restore_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/restore"
+)
+
+func TestRestore_WorktreeFromIndex(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "one", "b.txt": "b"})
+
+	commandtest.WriteFile(t, "a.txt", "two")
+	commandtest.MustRun(t, &add.Command{}, "a.txt")
+	commandtest.WriteFile(t, "a.txt", "three")
+	commandtest.WriteFile(t, "b.txt", "changed")
+
+	commandtest.MustRun(t, &restore.Command{}, "a.txt")
+	if got := commandtest.ReadFile(t, "a.txt"); got != "two" {
+		t.Fatalf("a.txt = %q, want the staged %q", got, "two")
+	}
+	if got := commandtest.ReadFile(t, "b.txt"); got != "changed" {
+		t.Fatalf("b.txt was touched: %q", got)
+	}
+	if got := commandtest.Staged(t)["a.txt"]; got != "two" {
+		t.Fatalf("staged a.txt = %q, want %q", got, "two")
+	}
+}
+
+func TestRestore_Staged(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "one", "dir/b.txt": "b"})
+
+	commandtest.WriteFile(t, "a.txt", "two")
+	commandtest.WriteFile(t, "dir/new.txt", "new")
+	commandtest.MustRun(t, &add.Command{}, ".")
+
+	commandtest.MustRun(t, &restore.Command{}, "--staged", "a.txt", "dir")
+	want := map[string]string{"a.txt": "one", "dir/b.txt": "b"}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged %v, want %v", got, want)
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "two" {
+		t.Fatalf("--staged changed the working tree: a.txt = %q", got)
+	}
+	if got := commandtest.ReadFile(t, "dir/new.txt"); got != "new" {
+		t.Fatalf("--staged changed the working tree: dir/new.txt = %q", got)
+	}
+}
+
+func TestRestore_Source(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "first", map[string]string{"a.txt": "one", "b.txt": "b1"})
+	commandtest.Commit(t, "second", map[string]string{"a.txt": "two", "b.txt": "b2", "c.txt": "c"})
+
+	commandtest.MustRun(t, &restore.Command{}, "--source=HEAD~1", "a.txt")
+	if got := commandtest.ReadFile(t, "a.txt"); got != "one" {
+		t.Fatalf("a.txt = %q, want %q", got, "one")
+	}
+	if got := commandtest.Staged(t)["a.txt"]; got != "two" {
+		t.Fatalf("restoring the working tree changed the index: a.txt = %q", got)
+	}
+
+	// a path the source does not have is removed
+	commandtest.MustRun(t, &restore.Command{}, "-s", "HEAD~1", "--staged", "--worktree", "b.txt", "c.txt")
+	want := map[string]string{"a.txt": "two", "b.txt": "b1"}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged %v, want %v", got, want)
+	}
+	if got := commandtest.ReadFile(t, "b.txt"); got != "b1" {
+		t.Fatalf("b.txt = %q, want %q", got, "b1")
+	}
+	if got := commandtest.ReadFile(t, "c.txt"); got != "<missing>" {
+		t.Fatalf("c.txt still on disk: %q", got)
+	}
+
+	if err := commandtest.Run(t, &restore.Command{}, "--source=no-such-rev", "a.txt"); err == nil {
+		t.Fatal("restored from an unknown revision")
+	}
+}
@@ -0,0 +1,207 @@
+package rev_list
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+type Command struct {
+	count        bool
+	ancestryPath bool
+	leftRight    bool
+	maxCount     int
+}
+
+func (c *Command) Name() string      { return "rev-list" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "List commits reachable from revisions (machine-readable)" }
+func (c *Command) Usage() string     { return "rev-list [options] <rev>..." }
+func (c *Command) Help() string {
+	return `List commit IDs reachable from the given revisions, newest first, one per line.
+
+Revisions:
+  <rev>                 Include commits reachable from <rev>.
+  ^<rev>                Exclude commits reachable from <rev>.
+  A..B                  Commits reachable from B but not from A.
+  A...B                 Commits reachable from either A or B but not from both.
+
+Options:
+      --count           Print only the number of selected commits.
+      --ancestry-path   With A..B, only show commits that are descendants of A.
+      --left-right      With A...B, mark commits with '<' (from A) or '>' (from B).
+                        Combined with --count prints "<left>\t<right>" (ahead/behind),
+                        counted over the whole range regardless of --max-count.
+      --max-count=<n>   Limit output to the first n commits.
+
+Usage:
+  bvc rev-list [options] <rev>...
+
+Examples:
+  bvc rev-list HEAD
+  bvc rev-list --count main..feature
+  bvc rev-list --ancestry-path 1a2b3c..main
+  bvc rev-list --left-right --count main...feature
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.count, "count", false, "print the number of commits")
+	fs.BoolVar(&c.ancestryPath, "ancestry-path", false, "only commits on the ancestry path")
+	fs.BoolVar(&c.leftRight, "left-right", false, "mark which side of a symmetric range a commit is on")
+	fs.IntVar(&c.maxCount, "max-count", 0, "limit the number of commits")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("at least one revision required")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var include, exclude []string
+	var left string
+
+	for _, arg := range ctx.Args {
+		switch {
+		case strings.Contains(arg, "..."):
+			parts := strings.SplitN(arg, "...", 2)
+			a, b, err := resolvePair(r.Meta, parts[0], parts[1])
+			if err != nil {
+				return err
+			}
+			graph, err := r.Meta.CommitGraphFor(a, b)
+			if err != nil {
+				return fmt.Errorf("failed to load commit graph: %w", err)
+			}
+			left = a
+			include = append(include, a, b)
+			exclude = append(exclude, graph.MergeBases(a, b)...)
+
+		case strings.Contains(arg, ".."):
+			parts := strings.SplitN(arg, "..", 2)
+			a, b, err := resolvePair(r.Meta, parts[0], parts[1])
+			if err != nil {
+				return err
+			}
+			include = append(include, b)
+			exclude = append(exclude, a)
+
+		case strings.HasPrefix(arg, "^"):
+			id, err := r.Meta.ResolveRevision(arg[1:])
+			if err != nil {
+				return err
+			}
+			exclude = append(exclude, id)
+
+		default:
+			id, err := r.Meta.ResolveRevision(arg)
+			if err != nil {
+				return err
+			}
+			include = append(include, id)
+		}
+	}
+
+	if c.leftRight && left == "" {
+		return fmt.Errorf("--left-right requires a symmetric range A...B")
+	}
+
+	graph, err := r.Meta.CommitGraphFor(append(include, exclude...)...)
+	if err != nil {
+		return fmt.Errorf("failed to load commit graph: %w", err)
+	}
+
+	ids := graph.Range(include, exclude)
+
+	if c.ancestryPath && len(exclude) > 0 {
+		var onPath []string
+		for _, id := range ids {
+			for _, bottom := range exclude {
+				if graph.IsAncestor(bottom, id) {
+					onPath = append(onPath, id)
+					break
+				}
+			}
+		}
+		ids = onPath
+	}
+
+	// ahead/behind for symmetric ranges, counted before --max-count
+	if c.leftRight {
+		var leftCount, rightCount int
+		marks := make([]string, len(ids))
+		for i, id := range ids {
+			if graph.IsAncestor(id, left) {
+				marks[i] = "<"
+				leftCount++
+			} else {
+				marks[i] = ">"
+				rightCount++
+			}
+		}
+		if c.count {
+			fmt.Printf("%d\t%d\n", leftCount, rightCount)
+			return nil
+		}
+		for i, id := range limit(ids, c.maxCount) {
+			fmt.Printf("%s%s\n", marks[i], id)
+		}
+		return nil
+	}
+
+	ids = limit(ids, c.maxCount)
+	if c.count {
+		fmt.Println(len(ids))
+		return nil
+	}
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+	return nil
+}
+
+// limit returns at most the first n ids; n <= 0 means no limit.
+func limit(ids []string, n int) []string {
+	if n > 0 && len(ids) > n {
+		return ids[:n]
+	}
+	return ids
+}
+
+// resolvePair resolves both ends of a range; an empty end means HEAD.
+func resolvePair(mc *meta.MetaContext, a, b string) (string, string, error) {
+	if a == "" {
+		a = "HEAD"
+	}
+	if b == "" {
+		b = "HEAD"
+	}
+	aID, err := mc.ResolveRevision(a)
+	if err != nil {
+		return "", "", err
+	}
+	bID, err := mc.ResolveRevision(b)
+	if err != nil {
+		return "", "", err
+	}
+	return aID, bID, nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
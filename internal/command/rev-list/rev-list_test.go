@@ -0,0 +1,107 @@
+package rev_list_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/branch"
+	"github.com/keshon/bvc/internal/command/checkout"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/merge"
+	rev_list "github.com/keshon/bvc/internal/command/rev-list"
+)
+
+func revList(t *testing.T, args ...string) []string {
+	t.Helper()
+	out, err := commandtest.Output(t, &rev_list.Command{}, args...)
+	if err != nil {
+		t.Fatalf("rev-list %v: %v", args, err)
+	}
+	return strings.Fields(out)
+}
+
+// history:
+//
+//	c1 - c2 - m1 (main)
+//	       \
+//	        s1 - s2 (side)
+func buildHistory(t *testing.T) {
+	t.Helper()
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "c1", map[string]string{"a.txt": "a"})
+	commandtest.Commit(t, "c2", map[string]string{"b.txt": "b"})
+	commandtest.MustRun(t, &branch.Command{}, "side")
+	commandtest.MustRun(t, &checkout.Command{}, "side")
+	commandtest.Commit(t, "s1", map[string]string{"s1.txt": "s1"})
+	commandtest.Commit(t, "s2", map[string]string{"s2.txt": "s2"})
+	commandtest.MustRun(t, &checkout.Command{}, "main")
+	commandtest.Commit(t, "m1", map[string]string{"m1.txt": "m1"})
+}
+
+func TestRevList_Ranges(t *testing.T) {
+	buildHistory(t)
+
+	if got := revList(t, "main..side"); len(got) != 2 {
+		t.Errorf("main..side = %v, want 2 commits", got)
+	}
+	if got := revList(t, "--count", "main..side"); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("--count main..side = %v, want [2]", got)
+	}
+	if got := revList(t, "--max-count=1", "main..side"); len(got) != 1 {
+		t.Errorf("--max-count=1 main..side = %v, want 1 commit", got)
+	}
+	if got := revList(t, "main...side"); len(got) != 3 {
+		t.Errorf("main...side = %v, want 3 commits", got)
+	}
+}
+
+func TestRevList_LeftRight(t *testing.T) {
+	buildHistory(t)
+
+	if got := revList(t, "--left-right", "--count", "main...side"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("--left-right --count main...side = %v, want [1 2]", got)
+	}
+	// ahead/behind counts the whole range
+	if got := revList(t, "--left-right", "--count", "--max-count=1", "main...side"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("--left-right --count --max-count=1 main...side = %v, want [1 2]", got)
+	}
+
+	got := revList(t, "--left-right", "main...side")
+	var left, right int
+	for _, line := range got {
+		switch line[0] {
+		case '<':
+			left++
+		case '>':
+			right++
+		}
+	}
+	if left != 1 || right != 2 {
+		t.Errorf("--left-right main...side = %v, want 1 '<' and 2 '>'", got)
+	}
+	if got := revList(t, "--left-right", "--max-count=2", "main...side"); len(got) != 2 {
+		t.Errorf("--left-right --max-count=2 main...side = %v, want 2 commits", got)
+	}
+
+	if _, err := commandtest.Output(t, &rev_list.Command{}, "--left-right", "main..side"); err == nil {
+		t.Error("--left-right accepted a range that is not symmetric")
+	}
+	if _, err := commandtest.Output(t, &rev_list.Command{}, "--left-right", "main"); err == nil {
+		t.Error("--left-right accepted a single revision")
+	}
+}
+
+func TestRevList_AncestryPath(t *testing.T) {
+	buildHistory(t)
+	m1 := commandtest.Commits(t)[0].ID
+	commandtest.MustRun(t, &merge.Command{}, "side")
+	merged := commandtest.Commits(t)[0].ID
+
+	if got := revList(t, m1+"..main"); len(got) != 3 {
+		t.Errorf("m1..main = %v, want the merge and both side commits", got)
+	}
+	if got := revList(t, "--ancestry-path", m1+"..main"); !reflect.DeepEqual(got, []string{merged}) {
+		t.Errorf("--ancestry-path m1..main = %v, want [%s]", got, merged)
+	}
+}
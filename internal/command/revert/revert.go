@@ -0,0 +1,193 @@
+package revert
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	mainline int
+	noCommit bool
+}
+
+func (c *Command) Name() string      { return "revert" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Undo a commit by creating a new commit" }
+func (c *Command) Usage() string     { return "revert [options] <commit>" }
+func (c *Command) Help() string {
+	return `Undo the changes introduced by a commit.
+
+The inverse of the commit's changes against its parent is three-way merged
+onto HEAD and recorded as a new commit; the branch history is not rewritten.
+If a reverted path was changed again later, the conflict is reported, the
+current version is kept and the reverted version is written next to it as
+<path>.MERGE_THEIRS; nothing is committed until you resolve and commit.
+
+Files the revert changes must have no uncommitted changes, staged or not,
+and no untracked file may be in the way; otherwise nothing is done.
+
+Options:
+  -m, --mainline=<n>    Parent number (starting at 1) to revert against; required for merge commits.
+  -n, --no-commit       Apply and stage the inverse changes without committing.
+
+Usage:
+  bvc revert [options] <commit>
+
+Examples:
+  bvc revert 18dfbee
+  bvc revert HEAD~2
+  bvc revert -m 1 <merge-commit>
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.IntVar(&c.mainline, "mainline", 0, "parent number to revert against")
+	fs.IntVar(&c.mainline, "m", 0, "alias for --mainline")
+	fs.BoolVar(&c.noCommit, "no-commit", false, "do not create a commit")
+	fs.BoolVar(&c.noCommit, "n", false, "alias for --no-commit")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) < 1 {
+		return fmt.Errorf("commit required")
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	targetID, err := r.Meta.ResolveRevision(ctx.Args[0])
+	if err != nil {
+		return err
+	}
+	target, err := r.Meta.GetCommit(targetID)
+	if err != nil {
+		return err
+	}
+
+	// pick the parent the commit is reverted against
+	parentID := ""
+	switch {
+	case len(target.Parents) > 1:
+		if c.mainline < 1 || c.mainline > len(target.Parents) {
+			return fmt.Errorf("commit %s is a merge; use -m <1..%d> to choose the parent", targetID, len(target.Parents))
+		}
+		parentID = target.Parents[c.mainline-1]
+	case c.mainline > 1:
+		return fmt.Errorf("commit %s has only one parent", targetID)
+	case len(target.Parents) == 1:
+		parentID = target.Parents[0]
+	}
+
+	branch, err := r.Meta.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	headID, err := r.Meta.GetLastCommitID(branch.Name)
+	if err != nil {
+		return err
+	}
+	if headID == "" {
+		return fmt.Errorf("branch '%s' has no commits", branch.Name)
+	}
+
+	// three-way merge: base = reverted commit, theirs = its parent
+	baseFS, err := r.GetCommittedFileset(targetID)
+	if err != nil {
+		return fmt.Errorf("failed to load fileset of %s: %w", targetID, err)
+	}
+	theirsFS := &snapshot.Fileset{}
+	if parentID != "" {
+		if theirsFS, err = r.GetCommittedFileset(parentID); err != nil {
+			return fmt.Errorf("failed to load fileset of %s: %w", parentID, err)
+		}
+	}
+	oursFS, err := r.GetCommittedFileset(headID)
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+
+	mergedFS, conflicts := snapshot.MergeFilesetsWith(baseFS, oursFS, theirsFS, r.Store.FileCtx.Attributes().MergeStrategy)
+	if changed, removed := snapshot.DiffFilesets(oursFS, &mergedFS); len(changed) == 0 && len(removed) == 0 {
+		fmt.Println("Nothing to revert: changes are already undone on this branch")
+		return nil
+	}
+
+	// update working tree, keeping uncommitted changes safe
+	if err := r.ApplyTree(oursFS, &mergedFS, fmt.Sprintf("revert of %s", targetID), "commit or stash your changes before reverting"); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", strings.SplitN(target.Message, "\n", 2)[0], targetID)
+
+	if len(conflicts) > 0 || c.noCommit {
+		// stage cleanly reverted files; conflict copies stay unstaged
+		copies := map[string]bool{}
+		for _, p := range conflicts {
+			copies[p+".MERGE_THEIRS"] = true
+		}
+		var staged []file.Entry
+		for _, e := range snapshot.IndexDelta(oursFS, &mergedFS) {
+			if !copies[e.Path] {
+				staged = append(staged, e)
+			}
+		}
+		if err := r.Store.FileCtx.SaveIndexMerge(staged); err != nil {
+			return fmt.Errorf("failed to stage reverted files: %w", err)
+		}
+		if len(conflicts) > 0 {
+			fmt.Println("\nRevert stopped with conflicts:")
+			for _, path := range conflicts {
+				fmt.Printf("CONFLICT: %s (reverted version saved as %s.MERGE_THEIRS)\n", path, path)
+			}
+			fmt.Println("\nResolve conflicts manually, then commit the result.")
+		} else {
+			fmt.Printf("Reverted changes of %s staged; commit them with 'bvc commit'.\n", targetID)
+		}
+		return nil
+	}
+
+	if err := r.Store.SnapshotCtx.Save(mergedFS); err != nil {
+		return fmt.Errorf("failed to save fileset: %w", err)
+	}
+
+	newCommit := meta.Commit{
+		ID:        fmt.Sprintf("%x", time.Now().UnixNano()),
+		Parents:   []string{headID},
+		Branch:    branch.Name,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+		FilesetID: mergedFS.ID,
+	}
+	if _, err := r.Meta.CreateCommit(&newCommit); err != nil {
+		return err
+	}
+	if err := r.Meta.SetLastCommitID(branch.Name, newCommit.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reverted commit %s on branch '%s' as %s\n", targetID, branch.Name, newCommit.ID)
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+			middleware.WithBlockIntegrityCheck(),
+			middleware.WithInterruptedRestoreCheck(),
+		),
+	)
+}
@@ -0,0 +1,53 @@
+package revert_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/revert"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func TestRevertRefusesUncommittedChanges(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "one", map[string]string{"a.txt": "one", "b.txt": "b"})
+	commandtest.Commit(t, "two", map[string]string{"a.txt": "two"})
+
+	var dirty *file.DirtyTreeError
+
+	commandtest.WriteFile(t, "a.txt", "local")
+	if err := commandtest.Run(t, &revert.Command{}, "HEAD"); !errors.As(err, &dirty) || len(dirty.Modified) != 1 {
+		t.Fatalf("revert over a local change: %v", err)
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "local" {
+		t.Fatalf("local change overwritten: %q", got)
+	}
+
+	commandtest.MustRun(t, &add.Command{}, "a.txt")
+	commandtest.WriteFile(t, "a.txt", "two")
+	if err := commandtest.Run(t, &revert.Command{}, "HEAD"); !errors.As(err, &dirty) || len(dirty.Staged) != 1 {
+		t.Fatalf("revert over a staged change: %v", err)
+	}
+	if got := commandtest.Staged(t)["a.txt"]; got != "local" {
+		t.Fatalf("staged change overwritten: %q", got)
+	}
+	if n := len(commandtest.Commits(t)); n != 2 {
+		t.Fatalf("%d commits after refused reverts, want 2", n)
+	}
+
+	// changes to other files do not stand in the way and are kept
+	commandtest.Commit(t, "three", map[string]string{"a.txt": "three"})
+	commandtest.WriteFile(t, "b.txt", "local b")
+	commandtest.MustRun(t, &revert.Command{}, "HEAD")
+	got := map[string]string{"a.txt": commandtest.ReadFile(t, "a.txt"), "b.txt": commandtest.ReadFile(t, "b.txt")}
+	if want := map[string]string{"a.txt": "two", "b.txt": "local b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after revert %v, want %v", got, want)
+	}
+	if msg := commandtest.Commits(t)[0].Message; !strings.HasPrefix(msg, `Revert "three"`) {
+		t.Fatalf("message %q", msg)
+	}
+}
@@ -0,0 +1,221 @@
+package rm
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type Command struct {
+	cached    bool
+	recursive bool
+	force     bool
+	quiet     bool
+}
+
+func (c *Command) Name() string      { return "rm" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Remove files from the working tree and the index" }
+func (c *Command) Usage() string     { return "rm [--cached] [-r] [-f] [-q] <path>..." }
+func (c *Command) Help() string {
+	return `Remove files from the working tree and the index.
+
+The removal is staged: the next commit no longer has the files. With
+--cached they are only removed from the index and stay on disk, untracked.
+
+Paths may be files or globs; a directory needs -r to remove the files below
+it. To keep work from being lost, a file is not removed when it differs from
+the index or its staged content differs from HEAD; with --cached, only when
+its staged content matches neither. -f removes it anyway.
+
+Options:
+      --cached    Remove from the index only, keeping the working tree files.
+  -r              Remove directories recursively.
+  -f, --force     Remove files with changes.
+  -q, --quiet     Do not list removed files.
+
+Usage:
+  bvc rm [--cached] [-r] [-f] [-q] <path>...
+
+Examples:
+  bvc rm old-logo.png
+  bvc rm -r assets/unused
+  bvc rm --cached "*.blend1"
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.cached, "cached", false, "remove from the index only")
+	fs.BoolVar(&c.recursive, "r", false, "remove directories recursively")
+	fs.BoolVar(&c.force, "force", false, "remove files with changes")
+	fs.BoolVar(&c.force, "f", false, "alias for --force")
+	fs.BoolVar(&c.quiet, "quiet", false, "do not list removed files")
+	fs.BoolVar(&c.quiet, "q", false, "alias for --quiet")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("path required\nusage: %s", c.Usage())
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	nextFS, err := r.GetIndexFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	// match every path against the index tree, refusing directories without -r
+	remove := map[string]file.Entry{}
+	for _, arg := range ctx.Args {
+		pat, err := fc.RelPath(arg)
+		if err != nil {
+			return err
+		}
+		matched := false
+		for _, e := range nextFS.Files {
+			p := filepath.ToSlash(e.Path)
+			if e.IsDir() || !changes.Matches(p, pat) {
+				continue
+			}
+			below := pat == "." || strings.HasPrefix(p, strings.TrimSuffix(pat, "/")+"/")
+			if below && !c.recursive {
+				return fmt.Errorf("not removing '%s' recursively without -r", arg)
+			}
+			remove[p] = e
+			matched = true
+		}
+		if !matched {
+			return fmt.Errorf("pathspec '%s' did not match any file known to bvc", arg)
+		}
+	}
+
+	paths := make([]string, 0, len(remove))
+	for p := range remove {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if !c.force {
+		if err := c.checkChanges(r, headFS, paths, remove); err != nil {
+			return err
+		}
+	}
+
+	updated := make([]file.Entry, 0, len(nextFS.Files))
+	for _, e := range nextFS.Files {
+		if _, ok := remove[filepath.ToSlash(e.Path)]; !ok {
+			updated = append(updated, e)
+		}
+	}
+	updatedFS := snapshot.Fileset{Files: updated}
+	if err := fc.SaveIndexReplace(snapshot.IndexDelta(headFS, &updatedFS)); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+
+	if !c.cached {
+		if err := fc.RemoveFromWorkingTree(paths); err != nil {
+			return err
+		}
+	}
+
+	if !c.quiet {
+		for _, p := range paths {
+			fmt.Printf("rm '%s'\n", p)
+		}
+	}
+	return nil
+}
+
+// checkChanges refuses to remove files whose changes would be lost: changes
+// not staged, or staged ones unless --cached keeps them on disk.
+func (c *Command) checkChanges(r *repo.Repository, headFS *snapshot.Fileset, paths []string, remove map[string]file.Entry) error {
+	fc := r.Store.FileCtx
+	head := make(map[string]file.Entry, len(headFS.Files))
+	for _, e := range headFS.Files {
+		head[filepath.ToSlash(e.Path)] = e
+	}
+
+	var present []string
+	for _, p := range paths {
+		abs := filepath.Join(fc.WorkingTreeDir, p)
+		if _, err := fc.FS.Lstat(abs); err == nil {
+			present = append(present, abs)
+		}
+	}
+	entries, err := fc.BuildEntries(present, true)
+	if err != nil {
+		return fmt.Errorf("failed to scan working tree: %w", err)
+	}
+	work := make(map[string]file.Entry, len(entries))
+	for _, e := range entries {
+		work[filepath.ToSlash(e.Path)] = e
+	}
+
+	var staged, local, both []string
+	for _, p := range paths {
+		idx := remove[p]
+		h, inHead := head[p]
+		w, inWork := work[p]
+		stagedChange := !inHead || !h.Equal(&idx)
+		localChange := inWork && !w.Equal(&idx)
+		switch {
+		case c.cached:
+			if stagedChange && localChange {
+				both = append(both, p)
+			}
+		case stagedChange && localChange:
+			both = append(both, p)
+		case stagedChange:
+			staged = append(staged, p)
+		case localChange:
+			local = append(local, p)
+		}
+	}
+
+	var msg []string
+	if len(both) > 0 {
+		msg = append(msg, "the following files have staged content different from both the file and HEAD:\n    "+
+			strings.Join(both, "\n    ")+"\n(use -f to force removal)")
+	}
+	if len(staged) > 0 {
+		msg = append(msg, "the following files have changes staged in the index:\n    "+
+			strings.Join(staged, "\n    ")+"\n(use --cached to keep the files, or -f to force removal)")
+	}
+	if len(local) > 0 {
+		msg = append(msg, "the following files have local modifications:\n    "+
+			strings.Join(local, "\n    ")+"\n(use --cached to keep the files, or -f to force removal)")
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("%s", strings.Join(msg, "\n"))
+	}
+	return nil
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+			middleware.WithInterruptedRestoreCheck(),
+		),
+	)
+}
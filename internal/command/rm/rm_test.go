@@ -0,0 +1,76 @@
+package rm_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/rm"
+)
+
+func TestRm(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{
+		"a.txt":     "a",
+		"b.txt":     "b",
+		"dir/c.txt": "c",
+	})
+
+	commandtest.MustRun(t, &rm.Command{}, "a.txt")
+	if got := commandtest.ReadFile(t, "a.txt"); got != "<missing>" {
+		t.Fatalf("a.txt still on disk: %q", got)
+	}
+	if _, ok := commandtest.Staged(t)["a.txt"]; ok {
+		t.Fatal("a.txt still staged")
+	}
+
+	commandtest.MustRun(t, &rm.Command{}, "--cached", "b.txt")
+	if got := commandtest.ReadFile(t, "b.txt"); got != "b" {
+		t.Fatalf("--cached removed b.txt from disk: %q", got)
+	}
+	if _, ok := commandtest.Staged(t)["b.txt"]; ok {
+		t.Fatal("b.txt still staged")
+	}
+
+	if err := commandtest.Run(t, &rm.Command{}, "dir"); err == nil {
+		t.Fatal("removed a directory without -r")
+	}
+	commandtest.MustRun(t, &rm.Command{}, "-r", "dir")
+	if got := commandtest.ReadFile(t, "dir/c.txt"); got != "<missing>" {
+		t.Fatalf("dir/c.txt still on disk: %q", got)
+	}
+
+	if err := commandtest.Run(t, &rm.Command{}, "missing.txt"); err == nil {
+		t.Fatal("expected an error for an unknown path")
+	}
+}
+
+func TestRmRefusesChanges(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "a", "b.txt": "b"})
+
+	// a local modification is kept unless forced
+	commandtest.WriteFile(t, "a.txt", "edited")
+	if err := commandtest.Run(t, &rm.Command{}, "a.txt"); err == nil {
+		t.Fatal("removed a modified file")
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "edited" {
+		t.Fatalf("a.txt = %q after refused rm", got)
+	}
+	commandtest.MustRun(t, &rm.Command{}, "--cached", "a.txt")
+	if got := commandtest.ReadFile(t, "a.txt"); got != "edited" {
+		t.Fatalf("--cached touched the modified file: %q", got)
+	}
+
+	// staged content that matches neither HEAD nor the file
+	commandtest.WriteFile(t, "b.txt", "staged")
+	commandtest.MustRun(t, &add.Command{}, "b.txt")
+	commandtest.WriteFile(t, "b.txt", "edited again")
+	if err := commandtest.Run(t, &rm.Command{}, "--cached", "b.txt"); err == nil {
+		t.Fatal("--cached dropped staged content found nowhere else")
+	}
+	commandtest.MustRun(t, &rm.Command{}, "-f", "b.txt")
+	if got := commandtest.ReadFile(t, "b.txt"); got != "<missing>" {
+		t.Fatalf("-f left b.txt: %q", got)
+	}
+}
@@ -24,16 +24,12 @@ func RunCLI(args []string) {
 
 	fs := flag.NewFlagSet(cmd.Name(), flag.ExitOnError)
 	cmd.Flags(fs)
-	if err := fs.Parse(remaining); err != nil {
+	ctx, err := ParseArgs(fs, remaining)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error parsing flags:", err)
 		os.Exit(1)
 	}
 
-	ctx := &Context{
-		Args:  fs.Args(),
-		Flags: fs,
-	}
-
 	if err := cmd.Run(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
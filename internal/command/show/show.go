@@ -0,0 +1,191 @@
+package show
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/middleware"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+type Command struct {
+	nameOnly   bool
+	findCopies bool
+}
+
+func (c *Command) Name() string      { return "show" }
+func (c *Command) Aliases() []string { return nil }
+func (c *Command) Brief() string     { return "Show a commit, or a file as of a revision" }
+func (c *Command) Usage() string     { return "show [--name-only] [-C] [<revision> | <revision>:<path>]" }
+func (c *Command) Help() string {
+	return `Show a commit, or a file as of a revision.
+
+With a revision (HEAD by default), print the commit: its ID, parents,
+branch, date and message, then the files it changed against its first
+parent. Each file is listed with its status (A, M, D, or R and C for
+renames and copies) and size; modified files also show how their blocks
+changed: bytes reused from the parent's version and bytes new, and blocks
+unchanged, moved, changed or removed. A file sharing at least half of its
+blocks with a deleted file is a rename of it; with -C, one sharing as much
+with a kept file is a copy of it.
+
+With <revision>:<path>, write the content of the file as it was in that
+revision to standard output, block by block, so large files are never held
+in memory. The path is relative to the working tree root. A directory lists
+the files below it.
+
+Options:
+      --name-only      List only the paths of the changed files.
+  -C, --find-copies    Detect copies of unmodified files too.
+
+Usage:
+  bvc show [--name-only] [-C] [<revision> | <revision>:<path>]
+
+Examples:
+  bvc show
+  bvc show main~2
+  bvc show --name-only 3f2a9c1
+  bvc show HEAD:assets/logo.png > logo.png
+`
+}
+func (c *Command) Subcommands() []command.Command { return nil }
+func (c *Command) Flags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.nameOnly, "name-only", false, "list only the paths of changed files")
+	fs.BoolVar(&c.findCopies, "find-copies", false, "detect copies of unmodified files too")
+	fs.BoolVar(&c.findCopies, "C", false, "alias for --find-copies")
+}
+
+func (c *Command) Run(ctx *command.Context) error {
+	if len(ctx.Args) > 1 {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+	arg := "HEAD"
+	if len(ctx.Args) == 1 {
+		arg = ctx.Args[0]
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	if rev, path, ok := strings.Cut(arg, ":"); ok {
+		if rev == "" {
+			rev = "HEAD"
+		}
+		return showFile(r, rev, path)
+	}
+	return c.showCommit(r, arg)
+}
+
+func (c *Command) showCommit(r *repo.Repository, rev string) error {
+	id, err := r.Meta.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	cmt, err := r.Meta.GetCommit(id)
+	if err != nil {
+		return err
+	}
+	list, err := r.CommitChanges(cmt, true, c.findCopies)
+	if err != nil {
+		return err
+	}
+
+	printHeader(cmt)
+	if c.nameOnly {
+		for _, ch := range list {
+			fmt.Println(ch.Label())
+		}
+		return nil
+	}
+	return changes.WriteSummary(os.Stdout, list)
+}
+
+// printHeader prints a commit the way log does, plus its fileset.
+func printHeader(cmt *meta.Commit) {
+	t, _ := time.Parse(time.RFC3339, cmt.Timestamp)
+
+	if isTerminal(os.Stdout) {
+		fmt.Printf("\033[33mcommit\033[0m %s\n", cmt.ID)
+	} else {
+		fmt.Printf("commit %s\n", cmt.ID)
+	}
+	if len(cmt.Parents) > 1 {
+		fmt.Printf("Merge:  %s\n", strings.Join(cmt.Parents, " "))
+	} else if len(cmt.Parents) == 1 {
+		fmt.Printf("Parent: %s\n", cmt.Parents[0])
+	}
+	if cmt.Branch != "" {
+		fmt.Printf("Branch: %s\n", cmt.Branch)
+	}
+	fmt.Printf("Date:   %s\n\n", t.Format("Mon Jan 2 15:04:05 2006 -0700"))
+
+	for _, line := range strings.Split(cmt.Message, "\n") {
+		if strings.TrimSpace(line) == "" {
+			fmt.Println()
+		} else {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	fmt.Println()
+}
+
+// showFile writes a file of a revision to stdout, or lists a directory.
+func showFile(r *repo.Repository, rev, path string) error {
+	id, err := r.Meta.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	fs, err := r.GetCommittedFileset(id)
+	if err != nil {
+		return fmt.Errorf("failed to load fileset of commit %s: %w", id, err)
+	}
+
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	var below []string
+	for _, e := range fs.Files {
+		p := filepath.ToSlash(e.Path)
+		if p == path && !e.IsDir() {
+			out := bufio.NewWriterSize(os.Stdout, 1024*1024)
+			if err := r.WriteStoredContent(out, e); err != nil {
+				return err
+			}
+			return out.Flush()
+		}
+		if path == "." || p == path || strings.HasPrefix(p, path+"/") {
+			below = append(below, p)
+		}
+	}
+	if len(below) == 0 {
+		return fmt.Errorf("path %q does not exist in %s", path, rev)
+	}
+	for _, p := range below {
+		fmt.Println(p)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a terminal, where colors are wanted.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&Command{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
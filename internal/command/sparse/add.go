@@ -0,0 +1,34 @@
+package sparse
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+)
+
+type AddCommand struct{}
+
+func (c *AddCommand) Name() string      { return "add" }
+func (c *AddCommand) Aliases() []string { return nil }
+func (c *AddCommand) Brief() string     { return "Add sparse checkout patterns" }
+func (c *AddCommand) Usage() string     { return "sparse add <pattern>..." }
+func (c *AddCommand) Help() string {
+	return `Append patterns to the sparse checkout patterns and update the working tree.
+
+Usage:
+  bvc sparse add <pattern>...
+
+Examples:
+  bvc sparse add /audio/previews/
+`
+}
+func (c *AddCommand) Subcommands() []command.Command { return nil }
+func (c *AddCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *AddCommand) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("at least one pattern required")
+	}
+	return update(func(patterns []string) []string { return append(patterns, ctx.Args...) })
+}
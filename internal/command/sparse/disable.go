@@ -0,0 +1,27 @@
+package sparse
+
+import (
+	"flag"
+
+	"github.com/keshon/bvc/internal/command"
+)
+
+type DisableCommand struct{}
+
+func (c *DisableCommand) Name() string      { return "disable" }
+func (c *DisableCommand) Aliases() []string { return nil }
+func (c *DisableCommand) Brief() string     { return "Check out all files again" }
+func (c *DisableCommand) Usage() string     { return "sparse disable" }
+func (c *DisableCommand) Help() string {
+	return `Remove the sparse checkout patterns and restore every tracked file.
+
+Usage:
+  bvc sparse disable
+`
+}
+func (c *DisableCommand) Subcommands() []command.Command { return nil }
+func (c *DisableCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *DisableCommand) Run(ctx *command.Context) error {
+	return update(func([]string) []string { return nil })
+}
@@ -0,0 +1,49 @@
+package sparse
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+type ListCommand struct{}
+
+func (c *ListCommand) Name() string      { return "list" }
+func (c *ListCommand) Aliases() []string { return []string{"ls"} }
+func (c *ListCommand) Brief() string     { return "List the sparse checkout patterns" }
+func (c *ListCommand) Usage() string     { return "sparse list" }
+func (c *ListCommand) Help() string {
+	return `List the sparse checkout patterns in order.
+
+Usage:
+  bvc sparse list
+`
+}
+func (c *ListCommand) Subcommands() []command.Command { return nil }
+func (c *ListCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *ListCommand) Run(ctx *command.Context) error {
+	return list()
+}
+
+func list() error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	sparse, err := r.Store.FileCtx.LoadSparse()
+	if err != nil {
+		return err
+	}
+	if !sparse.Enabled() {
+		fmt.Println("Sparse checkout is disabled")
+		return nil
+	}
+	for _, p := range sparse.Patterns() {
+		fmt.Println(p)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package sparse
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+)
+
+type SetCommand struct{}
+
+func (c *SetCommand) Name() string      { return "set" }
+func (c *SetCommand) Aliases() []string { return nil }
+func (c *SetCommand) Brief() string     { return "Replace the sparse checkout patterns" }
+func (c *SetCommand) Usage() string     { return "sparse set <pattern>..." }
+func (c *SetCommand) Help() string {
+	return `Replace the sparse checkout patterns and update the working tree.
+
+Files that are no longer selected are removed unless they have local
+changes; newly selected files are restored.
+
+Usage:
+  bvc sparse set <pattern>...
+
+Examples:
+  bvc sparse set /src/ /docs/
+  bvc sparse set '/*' '!/audio/'
+`
+}
+func (c *SetCommand) Subcommands() []command.Command { return nil }
+func (c *SetCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *SetCommand) Run(ctx *command.Context) error {
+	if len(ctx.Args) == 0 {
+		return fmt.Errorf("at least one pattern required (use 'bvc sparse disable' to check out everything)")
+	}
+	return update(func([]string) []string { return ctx.Args })
+}
@@ -0,0 +1,68 @@
+package sparse
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/middleware"
+)
+
+// Base command for "sparse"
+type SparseCommand struct{}
+
+func (c *SparseCommand) Name() string      { return "sparse" }
+func (c *SparseCommand) Aliases() []string { return []string{"sparse-checkout"} }
+func (c *SparseCommand) Brief() string     { return "Check out only part of the working tree" }
+func (c *SparseCommand) Usage() string     { return "sparse <subcommand> [options]" }
+func (c *SparseCommand) Help() string {
+	return `Limit which tracked files are written to the working tree.
+
+Patterns use .bvc-ignore syntax and are stored in the repository. A path is
+checked out when the last pattern matching it or one of its parent
+directories is not a "!" negation. Files left out stay tracked: status and
+add treat them as unchanged, and commits keep them as they are.
+
+Usage:
+  bvc sparse <subcommand> [options]
+
+Available subcommands:
+  bvc sparse set <pattern>...
+  bvc sparse add <pattern>...
+  bvc sparse list
+  bvc sparse disable
+
+Examples:
+  bvc sparse set '/*' '!/audio/' '/audio/previews/'
+  bvc sparse add /docs/
+  bvc sparse disable
+`
+}
+
+func (c *SparseCommand) Subcommands() []command.Command {
+	return []command.Command{
+		command.ApplyMiddlewares(&SetCommand{}, middleware.WithInterruptedRestoreCheck()),
+		command.ApplyMiddlewares(&AddCommand{}, middleware.WithInterruptedRestoreCheck()),
+		&ListCommand{},
+		command.ApplyMiddlewares(&DisableCommand{}, middleware.WithInterruptedRestoreCheck()),
+	}
+}
+
+func (c *SparseCommand) Flags(fs *flag.FlagSet) {}
+
+// Run lists the patterns when no subcommand is given
+func (c *SparseCommand) Run(ctx *command.Context) error {
+	if len(ctx.Args) > 0 {
+		return fmt.Errorf("unknown sparse subcommand %q", ctx.Args[0])
+	}
+	return list()
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&SparseCommand{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
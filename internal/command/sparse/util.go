@@ -0,0 +1,47 @@
+package sparse
+
+import (
+	"fmt"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+// update rewrites the patterns and applies them to the files of the next
+// commit (HEAD with staged changes).
+func update(change func(patterns []string) []string) error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	fc := r.Store.FileCtx
+
+	sparse, err := fc.LoadSparse()
+	if err != nil {
+		return err
+	}
+	if err := fc.SaveSparse(change(sparse.Patterns())); err != nil {
+		return err
+	}
+
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	index, err := fc.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+	next := snapshot.ApplyIndex(headFS, index)
+
+	res, err := fc.ApplySparse(next.Files)
+	if err != nil {
+		return err
+	}
+	for _, p := range res.Kept {
+		fmt.Printf("Kept %s: it has local changes\n", p)
+	}
+	fmt.Printf("Restored %d file(s), removed %d file(s)\n", res.Restored, res.Removed)
+	return nil
+}
@@ -0,0 +1,169 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type ApplyCommand struct{}
+
+func (c *ApplyCommand) Name() string      { return "apply" }
+func (c *ApplyCommand) Aliases() []string { return nil }
+func (c *ApplyCommand) Brief() string     { return "Apply a stash entry, keeping it on the stack" }
+func (c *ApplyCommand) Usage() string     { return "stash apply [<stash>]" }
+func (c *ApplyCommand) Help() string {
+	return `Restore the index and working-tree changes of a stash entry.
+
+Nothing is written if a stashed path was also changed locally in a
+different way; the conflicting paths are reported instead.
+
+Usage:
+  bvc stash apply [<stash>]
+`
+}
+func (c *ApplyCommand) Subcommands() []command.Command { return nil }
+func (c *ApplyCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *ApplyCommand) Run(ctx *command.Context) error {
+	n, err := parseStashRef(ctx.Args)
+	if err != nil {
+		return err
+	}
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	return apply(r, n)
+}
+
+type PopCommand struct{}
+
+func (c *PopCommand) Name() string      { return "pop" }
+func (c *PopCommand) Aliases() []string { return nil }
+func (c *PopCommand) Brief() string     { return "Apply a stash entry and remove it from the stack" }
+func (c *PopCommand) Usage() string     { return "stash pop [<stash>]" }
+func (c *PopCommand) Help() string {
+	return `Apply a stash entry and drop it if it applied cleanly.
+
+Usage:
+  bvc stash pop [<stash>]
+`
+}
+func (c *PopCommand) Subcommands() []command.Command { return nil }
+func (c *PopCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *PopCommand) Run(ctx *command.Context) error {
+	n, err := parseStashRef(ctx.Args)
+	if err != nil {
+		return err
+	}
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	if err := apply(r, n); err != nil {
+		return err
+	}
+	entry, err := r.Meta.DropStash(n)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, entry.Message)
+	return nil
+}
+
+// apply restores a stash entry on top of the current working tree and index.
+func apply(r *repo.Repository, n int) error {
+	entry, err := r.Meta.GetStash(n)
+	if err != nil {
+		return err
+	}
+
+	baseFiles, err := loadCommitFiles(r, entry.Base)
+	if err != nil {
+		return fmt.Errorf("failed to load stash base: %w", err)
+	}
+	stashIndex, err := loadFilesetFiles(r, entry.IndexFilesetID)
+	if err != nil {
+		return fmt.Errorf("failed to load stashed index: %w", err)
+	}
+	stashWork, err := loadFilesetFiles(r, entry.WorkFilesetID)
+	if err != nil {
+		return fmt.Errorf("failed to load stashed working tree: %w", err)
+	}
+	index, err := r.Store.FileCtx.LoadIndex()
+	if err != nil {
+		return err
+	}
+	currentIndex := entryMap(index)
+	workFiles, err := loadWorkingFiles(r)
+	if err != nil {
+		return fmt.Errorf("failed to scan working tree: %w", err)
+	}
+
+	// a path conflicts when it was changed locally to something other than
+	// both the stash base and the stashed version
+	var conflicts []string
+	check := func(p string, local, stashed *file.Entry) {
+		if local.Equal(lookup(baseFiles, p)) || local.Equal(stashed) {
+			return
+		}
+		conflicts = append(conflicts, p)
+	}
+	for p, e := range stashWork {
+		check(p, lookup(workFiles, p), &e)
+	}
+	for _, p := range entry.Deleted {
+		check(p, lookup(workFiles, p), nil)
+	}
+	for p, e := range stashIndex {
+		if local := lookup(currentIndex, p); local != nil && !local.Equal(&e) {
+			conflicts = append(conflicts, p)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("local changes would be overwritten by stash@{%d}:\n  %s\nCommit or stash them first",
+			n, strings.Join(conflicts, "\n  "))
+	}
+
+	// working tree
+	restore := make([]file.Entry, 0, len(stashWork))
+	for p, e := range stashWork {
+		if !e.Equal(lookup(workFiles, p)) {
+			restore = append(restore, e)
+		}
+	}
+	if len(restore) > 0 {
+		if err := r.Store.FileCtx.RestoreFilesToWorkingTree(restore, fmt.Sprintf("stash@{%d}", n)); err != nil {
+			return err
+		}
+	}
+	if err := r.Store.FileCtx.RemoveFromWorkingTree(entry.Deleted); err != nil {
+		return err
+	}
+
+	// index
+	if len(stashIndex) > 0 || len(entry.IndexDeleted) > 0 {
+		staged := make([]file.Entry, 0, len(stashIndex)+len(entry.IndexDeleted))
+		for _, e := range stashIndex {
+			staged = append(staged, e)
+		}
+		for _, p := range entry.IndexDeleted {
+			staged = append(staged, file.DeletedEntry(p))
+		}
+		if err := r.Store.FileCtx.SaveIndexMerge(staged); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Applied stash@{%d}: %s\n", n, entry.Message)
+	return nil
+}
@@ -0,0 +1,45 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+type DropCommand struct{}
+
+func (c *DropCommand) Name() string      { return "drop" }
+func (c *DropCommand) Aliases() []string { return nil }
+func (c *DropCommand) Brief() string     { return "Remove a stash entry" }
+func (c *DropCommand) Usage() string     { return "stash drop [<stash>]" }
+func (c *DropCommand) Help() string {
+	return `Remove a single stash entry from the stash stack.
+
+Usage:
+  bvc stash drop [<stash>]
+`
+}
+func (c *DropCommand) Subcommands() []command.Command { return nil }
+func (c *DropCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *DropCommand) Run(ctx *command.Context) error {
+	n, err := parseStashRef(ctx.Args)
+	if err != nil {
+		return err
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	entry, err := r.Meta.DropStash(n)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, entry.Message)
+	return nil
+}
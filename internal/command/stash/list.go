@@ -0,0 +1,42 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+type ListCommand struct{}
+
+func (c *ListCommand) Name() string      { return "list" }
+func (c *ListCommand) Aliases() []string { return []string{"ls"} }
+func (c *ListCommand) Brief() string     { return "List stash entries" }
+func (c *ListCommand) Usage() string     { return "stash list" }
+func (c *ListCommand) Help() string {
+	return `List stash entries, most recent first.
+
+Usage:
+  bvc stash list
+`
+}
+func (c *ListCommand) Subcommands() []command.Command { return nil }
+func (c *ListCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *ListCommand) Run(ctx *command.Context) error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	entries, err := r.Meta.ListStash()
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		fmt.Printf("stash@{%d}: On %s: %s\n", i, e.Branch, e.Message)
+	}
+	return nil
+}
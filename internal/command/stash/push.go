@@ -0,0 +1,221 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+type PushCommand struct {
+	message string
+}
+
+func (c *PushCommand) Name() string      { return "push" }
+func (c *PushCommand) Aliases() []string { return []string{"save"} }
+func (c *PushCommand) Brief() string     { return "Save local changes to a new stash entry" }
+func (c *PushCommand) Usage() string     { return "stash push [-m <message>] [<path>...]" }
+func (c *PushCommand) Help() string {
+	return `Save index and working-tree changes to a new stash entry and revert them
+to HEAD. Untracked files are left alone.
+
+Options:
+  -m, --message=<msg>   Description of the stash entry.
+
+Usage:
+  bvc stash push [-m <message>] [<path>...]
+
+Examples:
+  bvc stash push
+  bvc stash push -m "wip lighting"
+  bvc stash push assets/levels/
+`
+}
+func (c *PushCommand) Subcommands() []command.Command { return nil }
+func (c *PushCommand) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.message, "message", "", "stash message")
+	fs.StringVar(&c.message, "m", "", "alias for --message")
+}
+
+func (c *PushCommand) Run(ctx *command.Context) error {
+	return push(ctx.Args, c.message)
+}
+
+func push(paths []string, message string) error {
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	branch, err := r.Meta.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	baseID, err := r.Meta.GetLastCommitID(branch.Name)
+	if err != nil {
+		return err
+	}
+
+	headFiles, err := loadCommitFiles(r, baseID)
+	if err != nil {
+		return fmt.Errorf("failed to load HEAD fileset: %w", err)
+	}
+	index, err := r.Store.FileCtx.LoadIndex()
+	if err != nil {
+		return err
+	}
+	indexFiles := entryMap(index)
+	workFiles, err := loadWorkingFiles(r)
+	if err != nil {
+		return fmt.Errorf("failed to scan working tree: %w", err)
+	}
+
+	// staged changes; staged deletions are recorded by path
+	var stagedEntries, keptIndex []file.Entry
+	var stagedDeleted []string
+	for _, e := range index {
+		switch {
+		case !matchesAny(e.Path, paths):
+			keptIndex = append(keptIndex, e)
+		case e.Deleted:
+			stagedDeleted = append(stagedDeleted, e.Path)
+		default:
+			stagedEntries = append(stagedEntries, e)
+		}
+	}
+	sort.Strings(stagedDeleted)
+
+	// working-tree changes to tracked files
+	var workEntries []file.Entry
+	for p, w := range workFiles {
+		_, inHead := headFiles[p]
+		ie, inIndex := indexFiles[p]
+		inIndex = inIndex && !ie.Deleted
+		if !matchesAny(p, paths) || (!inHead && !inIndex) {
+			continue
+		}
+		if !w.Equal(lookup(headFiles, p)) {
+			workEntries = append(workEntries, w)
+		}
+	}
+	sparse, err := r.Store.FileCtx.LoadSparse()
+	if err != nil {
+		return err
+	}
+	var deleted []string
+	for p := range headFiles {
+		if _, ok := workFiles[p]; !ok && matchesAny(p, paths) && sparse.Includes(p) {
+			deleted = append(deleted, p)
+		}
+	}
+	sort.Strings(deleted)
+
+	if len(stagedEntries) == 0 && len(stagedDeleted) == 0 && len(workEntries) == 0 && len(deleted) == 0 {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+
+	indexFilesetID, err := saveFileset(r, stagedEntries)
+	if err != nil {
+		return fmt.Errorf("failed to store staged changes: %w", err)
+	}
+	workFilesetID, err := saveFileset(r, workEntries)
+	if err != nil {
+		return fmt.Errorf("failed to store working tree changes: %w", err)
+	}
+
+	if message == "" {
+		message = "WIP on " + branch.Name
+		if baseID != "" {
+			if c, err := r.Meta.GetCommit(baseID); err == nil {
+				message = fmt.Sprintf("WIP on %s: %s %s", branch.Name, shortID(baseID), strings.SplitN(c.Message, "\n", 2)[0])
+			}
+		}
+	}
+
+	entry := meta.StashEntry{
+		ID:             fmt.Sprintf("%x", time.Now().UnixNano()),
+		Branch:         branch.Name,
+		Base:           baseID,
+		Message:        message,
+		Timestamp:      time.Now().Format(time.RFC3339),
+		IndexFilesetID: indexFilesetID,
+		WorkFilesetID:  workFilesetID,
+		IndexDeleted:   stagedDeleted,
+		Deleted:        deleted,
+	}
+	if err := r.Meta.PushStash(entry); err != nil {
+		return err
+	}
+
+	// revert stashed paths back to HEAD
+	var restore []file.Entry
+	var remove []string
+	reverted := map[string]bool{}
+	for _, e := range append(stagedEntries, workEntries...) {
+		p := e.Path
+		if reverted[p] {
+			continue
+		}
+		reverted[p] = true
+		if h, ok := headFiles[p]; ok {
+			restore = append(restore, h)
+		} else {
+			remove = append(remove, p)
+		}
+	}
+	if err := r.Store.FileCtx.RemoveFromWorkingTree(remove); err != nil {
+		return err
+	}
+	for _, p := range append(stagedDeleted, deleted...) {
+		if _, ok := headFiles[p]; ok && !reverted[p] {
+			reverted[p] = true
+			restore = append(restore, headFiles[p])
+		}
+	}
+
+	if len(keptIndex) > 0 {
+		if err := r.Store.FileCtx.SaveIndexReplace(keptIndex); err != nil {
+			return err
+		}
+	} else if err := r.Store.FileCtx.ClearIndex(); err != nil {
+		return err
+	}
+
+	if len(restore) > 0 {
+		if err := r.Store.FileCtx.RestoreFilesToWorkingTree(restore, "stashed paths"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Saved working directory and index state %s\n", message)
+	return nil
+}
+
+// saveFileset stores entries as a fileset and returns its ID ("" for no entries).
+func saveFileset(r *repo.Repository, entries []file.Entry) (string, error) {
+	if len(entries) == 0 {
+		return "", nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	fs := snapshot.Fileset{ID: snapshot.HashFileset(entries), Files: entries}
+	if err := r.Store.SnapshotCtx.WriteAndSave(&fs); err != nil {
+		return "", err
+	}
+	return fs.ID, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 7 {
+		return id[:7]
+	}
+	return id
+}
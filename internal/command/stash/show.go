@@ -0,0 +1,125 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+type ShowCommand struct{}
+
+func (c *ShowCommand) Name() string      { return "show" }
+func (c *ShowCommand) Aliases() []string { return nil }
+func (c *ShowCommand) Brief() string     { return "Show the changes recorded in a stash entry" }
+func (c *ShowCommand) Usage() string     { return "stash show [<stash>]" }
+func (c *ShowCommand) Help() string {
+	return `Show the files changed in a stash entry relative to the commit it was
+created on. Each line is "<X><Y> <path>" where X is the staged change and
+Y the working-tree change (A added, M modified, D deleted).
+
+Usage:
+  bvc stash show [<stash>]
+
+Examples:
+  bvc stash show
+  bvc stash show stash@{2}
+`
+}
+func (c *ShowCommand) Subcommands() []command.Command { return nil }
+func (c *ShowCommand) Flags(fs *flag.FlagSet)         {}
+
+func (c *ShowCommand) Run(ctx *command.Context) error {
+	n, err := parseStashRef(ctx.Args)
+	if err != nil {
+		return err
+	}
+
+	r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	entry, err := r.Meta.GetStash(n)
+	if err != nil {
+		return err
+	}
+	baseFiles, err := loadCommitFiles(r, entry.Base)
+	if err != nil {
+		return err
+	}
+	indexFiles, err := loadFilesetFiles(r, entry.IndexFilesetID)
+	if err != nil {
+		return err
+	}
+	workFiles, err := loadFilesetFiles(r, entry.WorkFilesetID)
+	if err != nil {
+		return err
+	}
+
+	changes := map[string][2]string{}
+	for p, e := range indexFiles {
+		ch := changes[p]
+		ch[0] = changeKind(lookup(baseFiles, p), &e)
+		changes[p] = ch
+	}
+	for _, p := range entry.IndexDeleted {
+		ch := changes[p]
+		ch[0] = "D"
+		changes[p] = ch
+	}
+	for p, e := range workFiles {
+		// working-tree changes are relative to the staged version, if any
+		ref := lookup(indexFiles, p)
+		if ref == nil {
+			ref = lookup(baseFiles, p)
+		}
+		if kind := changeKind(ref, &e); kind != "" {
+			ch := changes[p]
+			ch[1] = kind
+			changes[p] = ch
+		}
+	}
+	for _, p := range entry.Deleted {
+		ch := changes[p]
+		ch[1] = "D"
+		changes[p] = ch
+	}
+
+	paths := make([]string, 0, len(changes))
+	for p := range changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	fmt.Printf("stash@{%d}: On %s: %s\n", n, entry.Branch, entry.Message)
+	for _, p := range paths {
+		ch := changes[p]
+		x, y := ch[0], ch[1]
+		if x == "" {
+			x = " "
+		}
+		if y == "" {
+			y = " "
+		}
+		fmt.Printf("%s%s %s\n", x, y, p)
+	}
+	return nil
+}
+
+func changeKind(base, e *file.Entry) string {
+	switch {
+	case e.Deleted:
+		return "D"
+	case base == nil:
+		return "A"
+	case !base.Equal(e):
+		return "M"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,68 @@
+package stash
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/middleware"
+)
+
+// Base command for "stash"
+type StashCommand struct{}
+
+func (c *StashCommand) Name() string      { return "stash" }
+func (c *StashCommand) Aliases() []string { return nil }
+func (c *StashCommand) Brief() string     { return "Shelve working tree and index changes" }
+func (c *StashCommand) Usage() string     { return "stash <subcommand> [options]" }
+func (c *StashCommand) Help() string {
+	return `Shelve uncommitted changes and restore them later.
+
+Stashed changes are stored as filesets in the snapshot store and kept on a
+stack; the current branch is not moved. Running 'bvc stash' without a
+subcommand is the same as 'bvc stash push'.
+
+Usage:
+  bvc stash <subcommand> [options]
+
+Available subcommands:
+  bvc stash push [-m <message>] [<path>...]
+  bvc stash list
+  bvc stash show [<stash>]
+  bvc stash apply [<stash>]
+  bvc stash pop [<stash>]
+  bvc stash drop [<stash>]
+
+A <stash> is either stash@{N} or N (0 is the most recent, the default).
+`
+}
+
+func (c *StashCommand) Subcommands() []command.Command {
+	return []command.Command{
+		command.ApplyMiddlewares(&PushCommand{}, middleware.WithInterruptedRestoreCheck()),
+		&ListCommand{},
+		&ShowCommand{},
+		command.ApplyMiddlewares(&ApplyCommand{}, middleware.WithInterruptedRestoreCheck()),
+		command.ApplyMiddlewares(&PopCommand{}, middleware.WithInterruptedRestoreCheck()),
+		&DropCommand{},
+	}
+}
+
+func (c *StashCommand) Flags(fs *flag.FlagSet) {}
+
+// Run stashes all local changes when no subcommand is given
+func (c *StashCommand) Run(ctx *command.Context) error {
+	if len(ctx.Args) > 0 {
+		return fmt.Errorf("unknown stash subcommand %q", ctx.Args[0])
+	}
+	return push(nil, "")
+}
+
+func init() {
+	command.RegisterCommand(
+		command.ApplyMiddlewares(
+			&StashCommand{},
+			middleware.WithDebugArgsPrint(),
+		),
+	)
+}
@@ -0,0 +1,118 @@
+package stash_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/command/add"
+	"github.com/keshon/bvc/internal/command/commandtest"
+	"github.com/keshon/bvc/internal/command/stash"
+)
+
+func stashCount(t *testing.T) int {
+	t.Helper()
+	entries, err := commandtest.Open(t).Meta.ListStash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestStash_PushPop(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "one", "b.txt": "b", "c.txt": "c"})
+
+	commandtest.WriteFile(t, "b.txt", "staged b")
+	commandtest.MustRun(t, &add.Command{}, "b.txt")
+	commandtest.WriteFile(t, "a.txt", "two")
+	if err := os.Remove("c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	commandtest.MustRun(t, &stash.PushCommand{}, "-m", "wip")
+	head := map[string]string{"a.txt": "one", "b.txt": "b", "c.txt": "c"}
+	for p, data := range head {
+		if got := commandtest.ReadFile(t, p); got != data {
+			t.Fatalf("after push %s = %q, want %q", p, got, data)
+		}
+	}
+	if got := commandtest.Staged(t); !reflect.DeepEqual(got, head) {
+		t.Fatalf("staged after push %v, want %v", got, head)
+	}
+	if n := stashCount(t); n != 1 {
+		t.Fatalf("expected 1 stash entry, got %d", n)
+	}
+	commandtest.MustRun(t, &stash.ShowCommand{})
+
+	commandtest.MustRun(t, &stash.PopCommand{})
+	if got := commandtest.ReadFile(t, "a.txt"); got != "two" {
+		t.Fatalf("a.txt = %q, want %q", got, "two")
+	}
+	if got := commandtest.ReadFile(t, "c.txt"); got != "<missing>" {
+		t.Fatalf("c.txt = %q, want it deleted again", got)
+	}
+	if got := commandtest.Staged(t)["b.txt"]; got != "staged b" {
+		t.Fatalf("staged b.txt = %q, want %q", got, "staged b")
+	}
+	if got := commandtest.Staged(t)["a.txt"]; got != "one" {
+		t.Fatalf("pop staged a working tree change: a.txt = %q", got)
+	}
+	if n := stashCount(t); n != 0 {
+		t.Fatalf("pop left %d stash entries", n)
+	}
+}
+
+func TestStash_ApplyConflict(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "one"})
+
+	commandtest.WriteFile(t, "a.txt", "stashed")
+	commandtest.MustRun(t, &stash.PushCommand{})
+	commandtest.WriteFile(t, "a.txt", "local")
+
+	if err := commandtest.Run(t, &stash.ApplyCommand{}); err == nil {
+		t.Fatal("applied a stash over a local change to the same file")
+	}
+	if got := commandtest.ReadFile(t, "a.txt"); got != "local" {
+		t.Fatalf("refused apply changed a.txt: %q", got)
+	}
+	if err := commandtest.Run(t, &stash.PopCommand{}); err == nil {
+		t.Fatal("popped a stash over a local change to the same file")
+	}
+	if n := stashCount(t); n != 1 {
+		t.Fatalf("refused pop dropped the entry: %d left", n)
+	}
+}
+
+func TestStash_NewAndUntrackedPaths(t *testing.T) {
+	commandtest.NewRepo(t)
+	commandtest.Commit(t, "init", map[string]string{"a.txt": "a", "sub/keep.txt": "k"})
+
+	commandtest.WriteFile(t, "sub/new.txt", "new")
+	commandtest.MustRun(t, &add.Command{}, "sub/new.txt")
+	commandtest.WriteFile(t, "untracked.txt", "u")
+
+	commandtest.MustRun(t, &stash.PushCommand{})
+
+	if got := commandtest.ReadFile(t, "sub/new.txt"); got != "<missing>" {
+		t.Fatalf("staged new file left on disk: %q", got)
+	}
+	if _, ok := commandtest.Staged(t)["sub/new.txt"]; ok {
+		t.Fatal("staged new file left in the index")
+	}
+	if got := commandtest.ReadFile(t, "untracked.txt"); got != "u" {
+		t.Fatalf("untracked file touched: %q", got)
+	}
+
+	commandtest.MustRun(t, &stash.ApplyCommand{})
+	if got := commandtest.ReadFile(t, "sub/new.txt"); got != "new" {
+		t.Fatalf("sub/new.txt = %q, want %q", got, "new")
+	}
+	if got := commandtest.Staged(t)["sub/new.txt"]; got != "new" {
+		t.Fatalf("staged sub/new.txt = %q, want %q", got, "new")
+	}
+	if n := stashCount(t); n != 1 {
+		t.Fatalf("apply dropped the entry: %d left", n)
+	}
+}
@@ -0,0 +1,99 @@
+package stash
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// parseStashRef accepts "stash@{N}" or "N"; no argument means the newest stash.
+func parseStashRef(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	ref := args[0]
+	if strings.HasPrefix(ref, "stash@{") && strings.HasSuffix(ref, "}") {
+		ref = strings.TrimSuffix(strings.TrimPrefix(ref, "stash@{"), "}")
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid stash reference %q", args[0])
+	}
+	return n, nil
+}
+
+// entryMap indexes entries by cleaned relative path.
+func entryMap(entries []file.Entry) map[string]file.Entry {
+	m := make(map[string]file.Entry, len(entries))
+	for _, e := range entries {
+		m[filepath.Clean(e.Path)] = e
+	}
+	return m
+}
+
+// lookup returns a pointer to the entry for path, or nil if absent.
+func lookup(m map[string]file.Entry, path string) *file.Entry {
+	if e, ok := m[path]; ok {
+		return &e
+	}
+	return nil
+}
+
+// loadCommitFiles returns the files of a commit, or an empty map for "".
+func loadCommitFiles(r *repo.Repository, commitID string) (map[string]file.Entry, error) {
+	if commitID == "" {
+		return map[string]file.Entry{}, nil
+	}
+	fs, err := r.GetCommittedFileset(commitID)
+	if err != nil {
+		return nil, err
+	}
+	return entryMap(fs.Files), nil
+}
+
+// loadFilesetFiles returns the files of a stored fileset, or an empty map for "".
+func loadFilesetFiles(r *repo.Repository, filesetID string) (map[string]file.Entry, error) {
+	if filesetID == "" {
+		return map[string]file.Entry{}, nil
+	}
+	fs, err := r.Store.SnapshotCtx.Load(filesetID)
+	if err != nil {
+		return nil, err
+	}
+	return entryMap(fs.Files), nil
+}
+
+// loadWorkingFiles builds entries for every non-ignored file in the working tree.
+func loadWorkingFiles(r *repo.Repository) (map[string]file.Entry, error) {
+	tracked, err := r.Store.SnapshotCtx.BuildWorkingTreeFileset()
+	if err != nil {
+		return nil, err
+	}
+	staged, err := r.Store.SnapshotCtx.BuildStagedFileset()
+	if err != nil {
+		return nil, err
+	}
+	return entryMap(append(tracked.Files, staged.Files...)), nil
+}
+
+// matchesAny reports whether path is selected by any of the pathspecs
+// (directory prefix or glob on the base name). No pathspecs select everything.
+func matchesAny(path string, specs []string) bool {
+	if len(specs) == 0 {
+		return true
+	}
+	for _, s := range specs {
+		s = filepath.ToSlash(filepath.Clean(s))
+		if s == "." || path == s || strings.HasPrefix(path, s+"/") {
+			return true
+		}
+		if ok, _ := filepath.Match(s, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
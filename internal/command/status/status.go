@@ -3,6 +3,7 @@ package status
 import (
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 
@@ -10,7 +11,9 @@ import (
 	"github.com/keshon/bvc/internal/config"
 	"github.com/keshon/bvc/internal/middleware"
 	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/changes"
 	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
 )
 
 type Command struct {
@@ -70,8 +73,10 @@ func (c *Command) Flags(fs *flag.FlagSet) {
 
 type statusItem struct {
 	Path     string
-	Staged   string // "A", "M", "D"
+	From     string // source of a staged rename or copy
+	Staged   string // "A", "M", "D", "R", "C"
 	Unstaged string // "M", "D"
+	Lockable bool   // marked lockable in .bvc-attributes
 }
 
 func (c *Command) Run(ctx *command.Context) error {
@@ -91,6 +96,11 @@ func (c *Command) Run(ctx *command.Context) error {
 		return fmt.Errorf("open repo: %w", err)
 	}
 
+	// an interrupted checkout leaves the working tree between two commits
+	if j, err := r.Meta.GetRestoreJournal(); err == nil && j != nil && !porcelain && !quiet {
+		fmt.Fprintln(os.Stderr, "warning:", repo.InterruptedRestoreHint(j))
+	}
+
 	branch, err := r.Meta.GetCurrentBranch()
 	if err != nil {
 		if !quiet {
@@ -100,36 +110,46 @@ func (c *Command) Run(ctx *command.Context) error {
 	}
 
 	// head files
+	headFS, err := r.GetHeadFileset()
+	if err != nil {
+		return err
+	}
 	headFiles := map[string]file.Entry{}
-	if commitID, _ := r.Meta.GetLastCommitID(branch.Name); commitID != "" {
-		fs, err := r.GetCommittedFileset(commitID)
-		if err != nil {
-			return err
-		}
-		for _, e := range fs.Files {
-			headFiles[filepath.Clean(e.Path)] = e
-		}
+	for _, e := range headFS.Files {
+		headFiles[filepath.Clean(e.Path)] = e
+	}
+
+	// index files: the complete next tree (HEAD with staged changes overlaid)
+	index, err := r.Store.FileCtx.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("load index: %w", err)
+	}
+	nextFS := snapshot.ApplyIndex(headFS, index)
+	indexFiles := map[string]file.Entry{}
+	for _, e := range nextFS.Files {
+		indexFiles[filepath.Clean(e.Path)] = e
 	}
 
 	// work, staged, ignored filesets
-	workFS, stagedFS, ignoredFS, err := r.Store.SnapshotCtx.BuildAllRepositoryFilesets()
+	trackedFS, stagedFS, ignoredFS, err := r.Store.SnapshotCtx.BuildAllRepositoryFilesets()
 	if err != nil {
 		return fmt.Errorf("scan working tree: %w", err)
 	}
 
 	workFiles := map[string]file.Entry{}
-	for _, e := range workFS.Files {
+	for _, e := range append(trackedFS.Files, stagedFS.Files...) {
 		workFiles[filepath.Clean(e.Path)] = e
 	}
 
-	stagedFiles := map[string]file.Entry{}
-	for _, e := range stagedFS.Files {
-		stagedFiles[filepath.Clean(e.Path)] = e
+	// files left out by sparse checkout are unchanged, not deleted
+	sparse, err := r.Store.FileCtx.LoadSparse()
+	if err != nil {
+		return err
 	}
-
-	ignoredFiles := map[string]file.Entry{}
-	for _, e := range ignoredFS.Files {
-		ignoredFiles[filepath.Clean(e.Path)] = e
+	for p, e := range indexFiles {
+		if _, ok := workFiles[p]; !ok && !sparse.Includes(p) {
+			workFiles[p] = e
+		}
 	}
 
 	// collect all unique paths
@@ -137,7 +157,7 @@ func (c *Command) Run(ctx *command.Context) error {
 	for k := range headFiles {
 		allPaths[k] = struct{}{}
 	}
-	for k := range stagedFiles {
+	for k := range indexFiles {
 		allPaths[k] = struct{}{}
 	}
 	for k := range workFiles {
@@ -152,36 +172,33 @@ func (c *Command) Run(ctx *command.Context) error {
 
 	var statusList []statusItem
 	var untracked []string
-
-	fmt.Println("HEAD:", len(headFiles))
-	fmt.Println("STAGED:", len(stagedFiles))
-	fmt.Println("WORK:", len(workFiles))
+	attrs := r.Store.FileCtx.Attributes()
 
 	for _, p := range paths {
 		h, inHead := headFiles[p]
-		s, inStaged := stagedFiles[p]
+		s, inIndex := indexFiles[p]
 		w, inWork := workFiles[p]
 
 		var staged, unstaged string
 
-		// determine staged status
+		// determine staged status (HEAD vs index)
 		switch {
-		case inStaged && !inHead:
+		case inIndex && !inHead:
 			staged = "A"
 
-		case inStaged && inHead && !h.Equal(&s):
+		case inIndex && inHead && !h.Equal(&s):
 			staged = "M"
 
-		case inHead && !inWork && inStaged:
+		case inHead && !inIndex:
 			staged = "D"
 		}
 
-		// determine unstaged status
+		// determine unstaged status (index vs working tree)
 		switch {
-		case inWork && inHead && !h.Equal(&w):
+		case inIndex && inWork && !s.Equal(&w):
 			unstaged = "M"
 
-		case inHead && !inWork && !inStaged:
+		case inIndex && !inWork:
 			unstaged = "D"
 		}
 
@@ -190,16 +207,18 @@ func (c *Command) Run(ctx *command.Context) error {
 				Path:     p,
 				Staged:   staged,
 				Unstaged: unstaged,
+				Lockable: attrs.For(p).Lockable(),
 			})
-			continue
 		}
 
 		// determine untracked
-		if !inStaged && !inHead && inWork && untrackedMode != "no" {
+		if !inIndex && inWork && untrackedMode != "no" {
 			untracked = append(untracked, p)
 		}
 	}
 
+	statusList = stagedRenames(statusList, headFS, &nextFS)
+
 	// collect ignored list
 	var ignoredList []string
 	if showIgnored {
@@ -232,9 +251,52 @@ func (c *Command) Run(ctx *command.Context) error {
 	return nil
 }
 
+// stagedRenames turns staged additions that share most of their blocks with
+// a staged deletion into renames.
+func stagedRenames(items []statusItem, headFS, nextFS *snapshot.Fileset) []statusItem {
+	found := map[string]changes.Change{}
+	for _, ch := range changes.DetectRenames(changes.Compare(headFS, nextFS), headFS, changes.RenameThreshold, false) {
+		if ch.From != "" {
+			found[filepath.FromSlash(ch.Path)] = ch
+		}
+	}
+	if len(found) == 0 {
+		return items
+	}
+	renamedFrom := map[string]bool{}
+	for i, it := range items {
+		if ch, ok := found[it.Path]; ok {
+			items[i].Staged = ch.Status
+			items[i].From = filepath.FromSlash(ch.From)
+			if ch.Status == changes.Renamed {
+				renamedFrom[items[i].From] = true
+			}
+		}
+	}
+	out := items[:0]
+	for _, it := range items {
+		if renamedFrom[it.Path] && it.Staged == "D" {
+			if it.Unstaged == "" {
+				continue
+			}
+			it.Staged = ""
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// label is the path of an item, written "from -> to" for renames and copies.
+func label(it statusItem) string {
+	if it.From != "" {
+		return rel(it.From) + " -> " + rel(it.Path)
+	}
+	return rel(it.Path)
+}
+
 func printShortStatus(items []statusItem, untracked, ignored []string, color bool) {
 	for _, it := range items {
-		line := fmt.Sprintf("%s%s %s", it.Staged, it.Unstaged, rel(it.Path))
+		line := fmt.Sprintf("%s%s %s", it.Staged, it.Unstaged, label(it))
 		if color {
 			line = colorLine(it.Staged, it.Unstaged, line)
 		}
@@ -274,7 +336,7 @@ func printFullStatus(items []statusItem, untracked, ignored []string, color bool
 		fmt.Println("  (use \"bvc restore --staged <file>...\" to unstage)")
 		for _, it := range staged {
 			kindStr := kind(it.Staged)
-			line := fmt.Sprintf("\t%-10s %s", kindStr+":", rel(it.Path))
+			line := fmt.Sprintf("\t%-10s %s%s", kindStr+":", label(it), lockable(it))
 			if color {
 				line = colorLine(it.Staged, "", line)
 			}
@@ -286,9 +348,10 @@ func printFullStatus(items []statusItem, untracked, ignored []string, color bool
 	if len(unstaged) > 0 {
 		fmt.Println("Changes not staged for commit:")
 		fmt.Println("  (use \"bvc add <file>...\" to update what will be committed)")
+		fmt.Println("  (use \"bvc restore <file>...\" to discard changes in working directory)")
 		for _, it := range unstaged {
 			kindStr := kind(it.Unstaged)
-			line := fmt.Sprintf("\t%-10s %s", kindStr+":", rel(it.Path))
+			line := fmt.Sprintf("\t%-10s %s%s", kindStr+":", rel(it.Path), lockable(it))
 			if color {
 				line = colorLine(it.Unstaged, "", line)
 			}
@@ -324,9 +387,17 @@ func printFullStatus(items []statusItem, untracked, ignored []string, color bool
 	}
 }
 
+// lockable marks assets meant to be edited by one person at a time.
+func lockable(it statusItem) string {
+	if it.Lockable {
+		return " (lockable)"
+	}
+	return ""
+}
+
 func colorLine(staged, unstaged, line string) string {
 	switch {
-	case staged == "A" || unstaged == "A":
+	case staged == "A" || unstaged == "A" || staged == "R" || staged == "C":
 		return "\033[32m" + line + "\033[0m" // green
 	case staged == "M" || unstaged == "M":
 		return "\033[33m" + line + "\033[0m" // yellow
@@ -345,6 +416,10 @@ func kind(x string) string {
 		return "modified"
 	case "D":
 		return "deleted"
+	case "R":
+		return "renamed"
+	case "C":
+		return "copied"
 	default:
 		return x
 	}
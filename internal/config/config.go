@@ -11,6 +11,10 @@ var (
 	RepoDir             = ".bvc"
 	RepoPointerFile     = ".bvc-pointer"
 	IgnoredFilesFile    = ".bvc-ignore"
+	AttributesFile      = ".bvc-attributes"
+	GlobalIgnoreEnv     = "BVC_EXCLUDES_FILE"  // overrides the user-global excludes file
+	PortablePathsEnv    = "BVC_PORTABLE_PATHS" // overrides the portable-paths repository setting
+	SymlinksEnv         = "BVC_SYMLINKS"       // record (default) or follow symbolic links
 	DefaultBranch       = "main"
 	DefaultIgnoredFiles = []string{RepoPointerFile, RepoDir}
 )
@@ -57,3 +61,15 @@ func (c *RepoConfig) HeadFile() string {
 	return c.RepoPath("HEAD")
 
 }
+
+func (c *RepoConfig) StashFile() string {
+	return c.RepoPath("stash.json")
+}
+
+func (c *RepoConfig) RestoreJournalFile() string {
+	return c.RepoPath("restore-journal.json")
+}
+
+func (c *RepoConfig) CommitGraphFile() string {
+	return c.RepoPath("commit-graph")
+}
@@ -49,3 +49,17 @@ func ResolveWorkingTreeRoot() string {
 	}
 	return "" // not found
 }
+
+// ResolveGlobalIgnoreFile returns the user-global excludes file: $BVC_EXCLUDES_FILE
+// if set, otherwise bvc/ignore in the user's config directory
+// (e.g. ~/.config/bvc/ignore). Returns "" if neither can be determined.
+func ResolveGlobalIgnoreFile() string {
+	if p, ok := os.LookupEnv(GlobalIgnoreEnv); ok {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bvc", "ignore")
+}
@@ -3,8 +3,10 @@ package fs
 import (
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // CompressedFS wraps another FS and compresses all file writes.
@@ -71,11 +73,23 @@ func (c *CompressedFS) Remove(path string) error { return c.underlying.Remove(pa
 func (c *CompressedFS) Rename(oldPath, newPath string) error {
 	return c.underlying.Rename(oldPath, newPath)
 }
-func (c *CompressedFS) Stat(path string) (os.FileInfo, error)      { return c.underlying.Stat(path) }
+func (c *CompressedFS) Stat(path string) (os.FileInfo, error)  { return c.underlying.Stat(path) }
+func (c *CompressedFS) Lstat(path string) (os.FileInfo, error) { return c.underlying.Lstat(path) }
+func (c *CompressedFS) Readlink(path string) (string, error)   { return c.underlying.Readlink(path) }
+func (c *CompressedFS) Symlink(target, path string) error      { return c.underlying.Symlink(target, path) }
+func (c *CompressedFS) Chmod(path string, mode os.FileMode) error {
+	return c.underlying.Chmod(path, mode)
+}
+func (c *CompressedFS) Chtimes(path string, atime, mtime time.Time) error {
+	return c.underlying.Chtimes(path, atime, mtime)
+}
 func (c *CompressedFS) ReadDir(path string) ([]os.DirEntry, error) { return c.underlying.ReadDir(path) }
 func (c *CompressedFS) CreateTempFile(dir, pattern string) (io.WriteCloser, string, error) {
 	return c.underlying.CreateTempFile(dir, pattern)
 }
+func (c *CompressedFS) OpenInPlace(path string) (File, error) {
+	return nil, fmt.Errorf("in-place writes are not supported on compressed files")
+}
 func (c *CompressedFS) IsNotExist(err error) bool { return c.underlying.IsNotExist(err) }
 func (c *CompressedFS) IsDir(path string) bool    { return c.underlying.IsDir(path) }
 func (c *CompressedFS) Exists(path string) bool   { return c.underlying.Exists(path) }
@@ -3,6 +3,7 @@ package fs
 import (
 	"io"
 	"os"
+	"time"
 )
 
 // FS abstracts filesystem operations.
@@ -14,9 +15,24 @@ type FS interface {
 	Remove(path string) error
 	Rename(oldPath, newPath string) error
 	Stat(path string) (os.FileInfo, error)
+	Lstat(path string) (os.FileInfo, error)
+	Readlink(path string) (string, error)
+	Symlink(target, path string) error
+	Chmod(path string, mode os.FileMode) error
+	Chtimes(path string, atime, mtime time.Time) error
 	ReadDir(path string) ([]os.DirEntry, error)
 	CreateTempFile(dir, pattern string) (io.WriteCloser, string, error)
+	OpenInPlace(path string) (File, error)
 	IsNotExist(err error) bool
 	Exists(path string) bool
 	IsDir(path string) bool
 }
+
+// File is an existing file opened for reading and writing in place.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Truncate(size int64) error
+	Sync() error
+}
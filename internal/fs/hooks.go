@@ -3,6 +3,7 @@ package fs
 import (
 	"io"
 	"os"
+	"time"
 )
 
 // Overridable hooks for testing.
@@ -16,6 +17,19 @@ var (
 	rename     = os.Rename
 	mkdirAll   = os.MkdirAll
 	isNotExist = os.IsNotExist
+	lstat      = os.Lstat
+	readlink   = os.Readlink
+	symlink    = os.Symlink
+	chmod      = os.Chmod
+	chtimes    = os.Chtimes
+
+	openInPlace = func(path string) (File, error) {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
 
 	createTemp = func(dir, pattern string) (io.WriteCloser, string, error) {
 		f, err := os.CreateTemp(dir, pattern)
@@ -66,3 +80,21 @@ func SetIsNotExist(f func(error) bool) { isNotExist = f }
 
 func GetCreateTemp() func(string, string) (io.WriteCloser, string, error)  { return createTemp }
 func SetCreateTemp(f func(string, string) (io.WriteCloser, string, error)) { createTemp = f }
+
+func GetOpenInPlace() func(string) (File, error)  { return openInPlace }
+func SetOpenInPlace(f func(string) (File, error)) { openInPlace = f }
+
+func GetLstat() func(string) (os.FileInfo, error)  { return lstat }
+func SetLstat(f func(string) (os.FileInfo, error)) { lstat = f }
+
+func GetReadlink() func(string) (string, error)  { return readlink }
+func SetReadlink(f func(string) (string, error)) { readlink = f }
+
+func GetSymlink() func(string, string) error  { return symlink }
+func SetSymlink(f func(string, string) error) { symlink = f }
+
+func GetChmod() func(string, os.FileMode) error  { return chmod }
+func SetChmod(f func(string, os.FileMode) error) { chmod = f }
+
+func GetChtimes() func(string, time.Time, time.Time) error  { return chtimes }
+func SetChtimes(f func(string, time.Time, time.Time) error) { chtimes = f }
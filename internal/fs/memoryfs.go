@@ -15,14 +15,20 @@ import (
 
 // MemoryFS is a pure in-memory filesystem for tests or lightweight storage.
 type MemoryFS struct {
-	files map[string][]byte
-	dirs  map[string]struct{}
+	files  map[string][]byte
+	dirs   map[string]struct{}
+	links  map[string]string
+	modes  map[string]os.FileMode
+	mtimes map[string]time.Time
 }
 
 func NewMemoryFS() *MemoryFS {
 	f := &MemoryFS{
-		files: make(map[string][]byte),
-		dirs:  make(map[string]struct{}),
+		files:  make(map[string][]byte),
+		dirs:   make(map[string]struct{}),
+		links:  make(map[string]string),
+		modes:  make(map[string]os.FileMode),
+		mtimes: make(map[string]time.Time),
 	}
 	f.dirs["/"] = struct{}{}
 	f.dirs["."] = struct{}{}
@@ -48,7 +54,7 @@ func (f *MemoryFS) ensureDirExists(p string) error {
 // FS Interface Implementation
 
 func (f *MemoryFS) Open(p string) (io.ReadSeekCloser, error) {
-	p = clean(p)
+	p = f.resolve(clean(p))
 	data, ok := f.files[p]
 	if !ok {
 		return nil, fs.ErrNotExist
@@ -63,7 +69,7 @@ type memReadSeekCloser struct {
 func (m *memReadSeekCloser) Close() error { return nil }
 
 func (f *MemoryFS) ReadFile(p string) ([]byte, error) {
-	p = clean(p)
+	p = f.resolve(clean(p))
 	data, ok := f.files[p]
 	if !ok {
 		return nil, fs.ErrNotExist
@@ -78,6 +84,8 @@ func (f *MemoryFS) WriteFile(p string, data []byte, perm os.FileMode) error {
 		return fmt.Errorf("write: dir %q does not exist", dir)
 	}
 	f.files[p] = append([]byte(nil), data...)
+	f.modes[p] = perm
+	f.mtimes[p] = time.Now()
 	return nil
 }
 
@@ -85,6 +93,9 @@ func (f *MemoryFS) MkdirAll(p string, perm os.FileMode) error {
 	p = clean(p)
 	parts := strings.Split(p, "/")
 	cur := ""
+	if path.IsAbs(p) {
+		cur = "/"
+	}
 	for _, seg := range parts {
 		if seg == "" || seg == "." {
 			continue
@@ -99,8 +110,14 @@ func (f *MemoryFS) MkdirAll(p string, perm os.FileMode) error {
 
 func (f *MemoryFS) Remove(p string) error {
 	p = clean(p)
+	if _, ok := f.links[p]; ok {
+		delete(f.links, p)
+		return nil
+	}
 	if _, ok := f.files[p]; ok {
 		delete(f.files, p)
+		delete(f.modes, p)
+		delete(f.mtimes, p)
 		return nil
 	}
 	if _, ok := f.dirs[p]; ok {
@@ -121,6 +138,17 @@ func (f *MemoryFS) Rename(oldp, newp string) error {
 		}
 		delete(f.files, oldp)
 		f.files[newp] = data
+		f.modes[newp], f.mtimes[newp] = f.modes[oldp], f.mtimes[oldp]
+		delete(f.modes, oldp)
+		delete(f.mtimes, oldp)
+		delete(f.links, newp)
+		return nil
+	}
+
+	// link rename
+	if target, ok := f.links[oldp]; ok {
+		delete(f.links, oldp)
+		f.links[newp] = target
 		return nil
 	}
 
@@ -136,15 +164,89 @@ func (f *MemoryFS) Rename(oldp, newp string) error {
 
 func (f *MemoryFS) Stat(p string) (os.FileInfo, error) {
 	p = clean(p)
+	info, err := f.Lstat(f.resolve(p))
+	if err != nil {
+		return nil, err
+	}
+	info.(*fakeInfo).name = filepath.Base(p)
+	return info, nil
+}
+
+func (f *MemoryFS) Lstat(p string) (os.FileInfo, error) {
+	p = clean(p)
+	if target, ok := f.links[p]; ok {
+		return &fakeInfo{name: filepath.Base(p), size: int64(len(target)), mode: os.ModeSymlink | 0o777}, nil
+	}
 	if data, ok := f.files[p]; ok {
-		return &fakeInfo{name: filepath.Base(p), size: int64(len(data)), dir: false}, nil
+		mode := f.modes[p]
+		if mode == 0 {
+			mode = 0o644
+		}
+		return &fakeInfo{name: filepath.Base(p), size: int64(len(data)), mode: mode, mtime: f.mtimes[p]}, nil
 	}
 	if _, ok := f.dirs[p]; ok {
-		return &fakeInfo{name: filepath.Base(p), dir: true}, nil
+		return &fakeInfo{name: filepath.Base(p), dir: true, mode: os.ModeDir | 0o755, mtime: f.mtimes[p]}, nil
 	}
 	return nil, fs.ErrNotExist
 }
 
+func (f *MemoryFS) Readlink(p string) (string, error) {
+	target, ok := f.links[clean(p)]
+	if !ok {
+		return "", fs.ErrNotExist
+	}
+	return target, nil
+}
+
+func (f *MemoryFS) Symlink(target, p string) error {
+	p = clean(p)
+	if f.Exists(p) {
+		return fs.ErrExist
+	}
+	if err := f.ensureDirExists(path.Dir(p)); err != nil {
+		return err
+	}
+	f.links[p] = target
+	return nil
+}
+
+func (f *MemoryFS) Chmod(p string, mode os.FileMode) error {
+	p = f.resolve(clean(p))
+	if _, ok := f.files[p]; !ok {
+		if _, ok := f.dirs[p]; !ok {
+			return fs.ErrNotExist
+		}
+		return nil
+	}
+	f.modes[p] = mode.Perm()
+	return nil
+}
+
+func (f *MemoryFS) Chtimes(p string, atime, mtime time.Time) error {
+	p = f.resolve(clean(p))
+	if !f.Exists(p) {
+		return fs.ErrNotExist
+	}
+	f.mtimes[p] = mtime
+	return nil
+}
+
+// resolve follows symlinks (relative targets are resolved against the link's
+// directory) and gives up after a fixed number of hops.
+func (f *MemoryFS) resolve(p string) string {
+	for i := 0; i < 40; i++ {
+		target, ok := f.links[p]
+		if !ok {
+			return p
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = clean(target)
+	}
+	return p
+}
+
 func (f *MemoryFS) ReadDir(p string) ([]os.DirEntry, error) {
 	p = clean(p)
 	if _, ok := f.dirs[p]; !ok {
@@ -171,8 +273,8 @@ func (f *MemoryFS) ReadDir(p string) ([]os.DirEntry, error) {
 		}
 	}
 
-	// then files
-	for fp := range f.files {
+	// then files and links
+	for _, fp := range f.names() {
 		if strings.HasPrefix(fp, prefix) {
 			rest := strings.TrimPrefix(fp, prefix)
 			name := strings.Split(rest, "/")[0]
@@ -216,27 +318,99 @@ func (m *memWriteCloser) Close() error {
 	return nil
 }
 
+func (f *MemoryFS) OpenInPlace(p string) (File, error) {
+	p = f.resolve(clean(p))
+	if _, ok := f.files[p]; !ok {
+		return nil, fs.ErrNotExist
+	}
+	return &memFile{fs: f, path: p}, nil
+}
+
+// memFile edits a MemoryFS file in place.
+type memFile struct {
+	fs   *MemoryFS
+	path string
+}
+
+func (m *memFile) ReadAt(b []byte, off int64) (int, error) {
+	data := m.fs.files[m.path]
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memFile) WriteAt(b []byte, off int64) (int, error) {
+	data := m.fs.files[m.path]
+	if end := off + int64(len(b)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[off:], b)
+	m.fs.files[m.path] = data
+	m.fs.mtimes[m.path] = time.Now()
+	return len(b), nil
+}
+
+func (m *memFile) Truncate(size int64) error {
+	data := m.fs.files[m.path]
+	if size <= int64(len(data)) {
+		data = data[:size]
+	} else {
+		data = append(data, make([]byte, size-int64(len(data)))...)
+	}
+	m.fs.files[m.path] = data
+	m.fs.mtimes[m.path] = time.Now()
+	return nil
+}
+
+func (m *memFile) Sync() error  { return nil }
+func (m *memFile) Close() error { return nil }
+
 func (f *MemoryFS) IsNotExist(err error) bool { return errors.Is(err, fs.ErrNotExist) }
-func (f *MemoryFS) IsDir(p string) bool       { _, ok := f.dirs[clean(p)]; return ok }
+func (f *MemoryFS) IsDir(p string) bool       { _, ok := f.dirs[f.resolve(clean(p))]; return ok }
 func (f *MemoryFS) Exists(p string) bool {
 	p = clean(p)
 	_, f1 := f.files[p]
 	_, d1 := f.dirs[p]
-	return f1 || d1
+	_, l1 := f.links[p]
+	return f1 || d1 || l1
+}
+
+// names returns the paths of all files and links.
+func (f *MemoryFS) names() []string {
+	out := make([]string, 0, len(f.files)+len(f.links))
+	for p := range f.files {
+		out = append(out, p)
+	}
+	for p := range f.links {
+		out = append(out, p)
+	}
+	return out
 }
 
 // Helpers
 
 type fakeInfo struct {
-	name string
-	size int64
-	dir  bool
+	name  string
+	size  int64
+	dir   bool
+	mode  fs.FileMode
+	mtime time.Time
 }
 
-func (f *fakeInfo) Name() string       { return f.name }
-func (f *fakeInfo) Size() int64        { return f.size }
-func (f *fakeInfo) Mode() fs.FileMode  { return 0o644 }
-func (f *fakeInfo) ModTime() time.Time { return time.Time{} }
+func (f *fakeInfo) Name() string { return f.name }
+func (f *fakeInfo) Size() int64  { return f.size }
+func (f *fakeInfo) Mode() fs.FileMode {
+	if f.mode == 0 {
+		return 0o644
+	}
+	return f.mode
+}
+func (f *fakeInfo) ModTime() time.Time { return f.mtime }
 func (f *fakeInfo) IsDir() bool        { return f.dir }
 func (f *fakeInfo) Sys() interface{}   { return nil }
 
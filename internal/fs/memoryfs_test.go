@@ -191,6 +191,39 @@ func TestMemoryFS_CreateTempFile(t *testing.T) {
 	}
 }
 
+func TestMemoryFS_OpenInPlace(t *testing.T) {
+	m := fs.NewMemoryFS()
+	m.MkdirAll("d", 0o755)
+	m.WriteFile("d/f", []byte("0123456789"), 0o644)
+
+	f, err := m.OpenInPlace("d/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("ab"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("XY"), 12); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := f.ReadAt(buf, 0); err != nil || string(buf) != "01ab" {
+		t.Fatalf("ReadAt = %q, %v", buf, err)
+	}
+	if err := f.Truncate(6); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, _ := m.ReadFile("d/f")
+	if string(data) != "01ab45" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if _, err := m.OpenInPlace("d/missing"); !m.IsNotExist(err) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
+
 func TestMemoryFS_Exists(t *testing.T) {
 	m := fs.NewMemoryFS()
 	m.MkdirAll("d", 0o755)
@@ -217,3 +250,46 @@ func TestMemoryFS_PathNormalization(t *testing.T) {
 		t.Fatal("path normalization failed for dir")
 	}
 }
+
+func TestMemoryFS_SymlinkAndMetadata(t *testing.T) {
+	m := fs.NewMemoryFS()
+	if err := m.MkdirAll("/abs/dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsDir("/abs/dir") {
+		t.Fatal("absolute directory not created")
+	}
+	if err := m.WriteFile("/abs/dir/tool.sh", []byte("run"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chmod("/abs/dir/tool.sh", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink("tool.sh", "/abs/dir/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	li, err := m.Lstat("/abs/dir/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if li.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected symlink mode, got %v", li.Mode())
+	}
+
+	// Stat and ReadFile follow the link
+	si, err := m.Stat("/abs/dir/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si.Mode().Perm() != 0o755 || si.Size() != 3 {
+		t.Errorf("unexpected target info: mode %v size %d", si.Mode(), si.Size())
+	}
+	if data, err := m.ReadFile("/abs/dir/link"); err != nil || string(data) != "run" {
+		t.Errorf("ReadFile through link = %q, %v", data, err)
+	}
+
+	if target, err := m.Readlink("/abs/dir/link"); err != nil || target != "tool.sh" {
+		t.Errorf("Readlink = %q, %v", target, err)
+	}
+}
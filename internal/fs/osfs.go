@@ -3,6 +3,7 @@ package fs
 import (
 	"io"
 	"os"
+	"time"
 )
 
 // OSFS is a production FS implementation using the standard library.
@@ -20,6 +21,26 @@ func (fsys *OSFS) Stat(path string) (os.FileInfo, error) {
 	return stat(path)
 }
 
+func (fsys *OSFS) Lstat(path string) (os.FileInfo, error) {
+	return lstat(path)
+}
+
+func (fsys *OSFS) Readlink(path string) (string, error) {
+	return readlink(path)
+}
+
+func (fsys *OSFS) Symlink(target, path string) error {
+	return symlink(target, path)
+}
+
+func (fsys *OSFS) Chmod(path string, mode os.FileMode) error {
+	return chmod(path, mode)
+}
+
+func (fsys *OSFS) Chtimes(path string, atime, mtime time.Time) error {
+	return chtimes(path, atime, mtime)
+}
+
 func (fsys *OSFS) ReadFile(path string) ([]byte, error) {
 	return readFile(path)
 }
@@ -48,6 +69,10 @@ func (fsys *OSFS) CreateTempFile(dir, pattern string) (io.WriteCloser, string, e
 	return createTemp(dir, pattern)
 }
 
+func (fsys *OSFS) OpenInPlace(path string) (File, error) {
+	return openInPlace(path)
+}
+
 func (fsys *OSFS) IsNotExist(err error) bool {
 	return isNotExist(err)
 }
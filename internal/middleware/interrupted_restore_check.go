@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/keshon/bvc/internal/command"
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+)
+
+// WithInterruptedRestoreCheck is a middleware that refuses to run a command
+// while a checkout or reset --hard is interrupted, except that command with
+// --continue or --abort.
+func WithInterruptedRestoreCheck() command.Middleware {
+	return func(cmd command.Command) command.Command {
+		return &command.WrappedCommand{
+			Command: cmd,
+			Wrap: func(ctx *command.Context) error {
+				r, err := repo.NewRepositoryByPath(config.ResolveRepoDir())
+				if err != nil {
+					return fmt.Errorf("failed to open repository: %w", err)
+				}
+				j, err := r.Meta.GetRestoreJournal()
+				if err != nil {
+					return err
+				}
+				if j == nil || (cmd.Name() == j.Command && (isFlagSet(ctx, "continue") || isFlagSet(ctx, "abort"))) {
+					return cmd.Run(ctx)
+				}
+				return fmt.Errorf("%s", repo.InterruptedRestoreHint(j))
+			},
+		}
+	}
+}
+
+// isFlagSet reports whether a boolean flag was given.
+func isFlagSet(ctx *command.Context, name string) bool {
+	if ctx.Flags == nil {
+		return false
+	}
+	f := ctx.Flags.Lookup(name)
+	return f != nil && f.Value.String() == "true"
+}
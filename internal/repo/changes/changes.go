@@ -0,0 +1,438 @@
+// Package changes compares two trees file by file and renders the result as
+// a patch, a diffstat or a name-status list. Text files get a unified line
+// diff; other files are described by how their blocks changed.
+package changes
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+	"github.com/keshon/bvc/internal/textdiff"
+)
+
+// Statuses of a changed path.
+const (
+	Added    = "A"
+	Deleted  = "D"
+	Modified = "M"
+	Renamed  = "R"
+	Copied   = "C"
+)
+
+// TextLimit is the size above which files are not line-diffed, text or not.
+const TextLimit = 1 << 20
+
+// Change is a path that differs between two trees. Old is nil for added
+// paths and New for deleted ones. Renames and copies keep their source path
+// in From and how much of the content the two sides share in Similarity.
+type Change struct {
+	Status     string
+	Path       string
+	From       string
+	Similarity int // percent, renames and copies only
+	Old        *file.Entry
+	New        *file.Entry
+}
+
+// Label is the path of a change, written "from -> to" for renames and
+// copies.
+func (c Change) Label() string {
+	if c.From != "" {
+		return c.From + " -> " + c.Path
+	}
+	return c.Path
+}
+
+// Compare lists the paths that differ from one fileset to another, sorted
+// by path.
+func Compare(from, to *snapshot.Fileset) []Change {
+	oldMap := entryMap(from)
+	newMap := entryMap(to)
+
+	var out []Change
+	for p, n := range newMap {
+		if o, ok := oldMap[p]; !ok {
+			out = append(out, Change{Status: Added, Path: p, New: n})
+		} else if !o.Equal(n) {
+			out = append(out, Change{Status: Modified, Path: p, Old: o, New: n})
+		}
+	}
+	for p, o := range oldMap {
+		if _, ok := newMap[p]; !ok {
+			out = append(out, Change{Status: Deleted, Path: p, Old: o})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func entryMap(fs *snapshot.Fileset) map[string]*file.Entry {
+	m := map[string]*file.Entry{}
+	if fs == nil {
+		return m
+	}
+	for i := range fs.Files {
+		m[filepath.ToSlash(filepath.Clean(fs.Files[i].Path))] = &fs.Files[i]
+	}
+	return m
+}
+
+// Filter keeps the changes under one of the given repository-relative paths
+// or matching one of them as a glob. Renames and copies are kept when either
+// side matches. No paths keeps everything.
+func Filter(changes []Change, paths []string) []Change {
+	if len(paths) == 0 {
+		return changes
+	}
+	var out []Change
+	for _, c := range changes {
+		for _, p := range paths {
+			if Matches(c.Path, p) || (c.From != "" && Matches(c.From, p)) {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Matches reports whether a repository-relative path is the pattern, lies
+// below it, or matches it as a glob.
+func Matches(p, pattern string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	if pattern == "." || p == pattern || strings.HasPrefix(p, pattern+"/") {
+		return true
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+// Options controls how changes are rendered.
+type Options struct {
+	OldContent func(file.Entry) ([]byte, error) // reads the old side
+	NewContent func(file.Entry) ([]byte, error) // reads the new side
+	Context    int                              // lines of context around text changes
+	Color      bool                             // wrap output in ANSI colors
+}
+
+// analysis is the content-level comparison of one change.
+type analysis struct {
+	text  bool
+	a, b  []string
+	edits []textdiff.Edit
+	delta block.Delta
+}
+
+// analyze line-diffs a change when both sides are small text, and compares
+// blocks otherwise.
+func analyze(c Change, o Options) analysis {
+	var oldBlocks, newBlocks []block.BlockRef
+	if c.Old != nil {
+		oldBlocks = c.Old.Blocks
+	}
+	if c.New != nil {
+		newBlocks = c.New.Blocks
+	}
+	an := analysis{delta: block.Compare(oldBlocks, newBlocks)}
+
+	a, ok := textContent(c.Old, o.OldContent)
+	if !ok {
+		return an
+	}
+	b, ok := textContent(c.New, o.NewContent)
+	if !ok {
+		return an
+	}
+	an.text = true
+	an.a, an.b = textdiff.Lines(a), textdiff.Lines(b)
+	an.edits = textdiff.Diff(an.a, an.b)
+	return an
+}
+
+// textContent loads a side that is small enough and looks like text. A
+// missing side is empty text.
+func textContent(e *file.Entry, read func(file.Entry) ([]byte, error)) ([]byte, bool) {
+	if e == nil {
+		return nil, true
+	}
+	if e.IsDir() || Size(*e) > TextLimit || read == nil {
+		return nil, false
+	}
+	data, err := read(*e)
+	if err != nil || !textdiff.IsText(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// Size returns the content size of an entry.
+func Size(e file.Entry) int64 {
+	if e.Size > 0 {
+		return e.Size
+	}
+	var n int64
+	for _, b := range e.Blocks {
+		n += b.Size
+	}
+	return n
+}
+
+// WriteNameStatus writes one "status<TAB>path" line per change. Renames and
+// copies read "R<similarity><TAB>from<TAB>to".
+func WriteNameStatus(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		var err error
+		if c.From != "" {
+			_, err = fmt.Fprintf(w, "%s%03d\t%s\t%s\n", c.Status, c.Similarity, c.From, c.Path)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\t%s\n", c.Status, c.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSummary writes one line per change with its status, path and size,
+// and for modified files how their blocks changed. Content is not read.
+func WriteSummary(w io.Writer, changes []Change) error {
+	width := 0
+	for _, c := range changes {
+		width = max(width, len(c.Label()))
+	}
+	for _, c := range changes {
+		var detail string
+		switch c.Status {
+		case Added:
+			detail = FormatSize(Size(*c.New))
+		case Deleted:
+			detail = FormatSize(Size(*c.Old))
+		default:
+			detail = fmt.Sprintf("%s -> %s", FormatSize(Size(*c.Old)), FormatSize(Size(*c.New)))
+			if len(c.Old.Blocks) > 0 || len(c.New.Blocks) > 0 {
+				detail += "  " + describeDelta(block.Compare(c.Old.Blocks, c.New.Blocks))
+			}
+		}
+		if c.From != "" {
+			detail = fmt.Sprintf("%d%% similar  ", c.Similarity) + detail
+		}
+		if _, err := fmt.Fprintf(w, " %s  %-*s  %s\n", c.Status, width, c.Label(), detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePatch writes a patch: for each change a header, then a unified diff
+// for text or block statistics for anything else.
+func WritePatch(w io.Writer, changes []Change, o Options) error {
+	color := colorizer(o.Color)
+	for _, c := range changes {
+		fromPath := c.Path
+		if c.From != "" {
+			fromPath = c.From
+		}
+		if _, err := fmt.Fprintln(w, color('h', fmt.Sprintf("diff --bvc a/%s b/%s", fromPath, c.Path))); err != nil {
+			return err
+		}
+		if err := writeModeLines(w, c, color); err != nil {
+			return err
+		}
+		if (c.Old != nil && c.Old.IsDir()) || (c.New != nil && c.New.IsDir()) {
+			continue
+		}
+		if c.From != "" && c.Similarity == 100 && sameBlocks(c.Old.Blocks, c.New.Blocks) {
+			continue
+		}
+
+		an := analyze(c, o)
+		if !an.text {
+			if _, err := fmt.Fprintln(w, "Binary file: "+describeDelta(an.delta)); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(an.edits) == 0 || !hasChanges(an.edits) {
+			continue // only the mode changed
+		}
+		from, to := "a/"+fromPath, "b/"+c.Path
+		if c.Old == nil {
+			from = "/dev/null"
+		}
+		if c.New == nil {
+			to = "/dev/null"
+		}
+		if _, err := fmt.Fprintf(w, "%s\n%s\n", color('h', "--- "+from), color('h', "+++ "+to)); err != nil {
+			return err
+		}
+		if err := textdiff.WriteUnified(w, an.a, an.b, an.edits, o.Context, color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeModeLines notes added, deleted, renamed and copied paths and changes
+// of file kind or permissions.
+func writeModeLines(w io.Writer, c Change, color func(byte, string) string) error {
+	if c.From != "" {
+		verb := "rename"
+		if c.Status == Copied {
+			verb = "copy"
+		}
+		line := fmt.Sprintf("similarity index %d%%\n%s from %s\n%s to %s", c.Similarity, verb, c.From, verb, c.Path)
+		if _, err := fmt.Fprintln(w, color('h', line)); err != nil {
+			return err
+		}
+	}
+	var line string
+	switch {
+	case c.Old == nil:
+		line = fmt.Sprintf("new %s mode %s", kind(c.New), c.New.Mode)
+	case c.New == nil:
+		line = fmt.Sprintf("deleted %s mode %s", kind(c.Old), c.Old.Mode)
+	case c.Old.Mode != 0 && c.New.Mode != 0 && (c.Old.Mode.Type() != c.New.Mode.Type() || c.Old.Mode&0o111 != c.New.Mode&0o111):
+		line = fmt.Sprintf("old mode %s\nnew mode %s", c.Old.Mode, c.New.Mode)
+	default:
+		return nil
+	}
+	_, err := fmt.Fprintln(w, color('h', line))
+	return err
+}
+
+func kind(e *file.Entry) string {
+	switch {
+	case e.IsDir():
+		return "directory"
+	case e.IsSymlink():
+		return "symlink"
+	}
+	return "file"
+}
+
+func hasChanges(edits []textdiff.Edit) bool {
+	for _, e := range edits {
+		if e.Op != textdiff.Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func describeDelta(d block.Delta) string {
+	return fmt.Sprintf("%s reused, %s new; blocks: %d unchanged, %d moved, %d changed, %d removed",
+		FormatSize(d.ReusedBytes), FormatSize(d.NewBytes), d.Unchanged, d.Moved, d.Changed, d.Removed)
+}
+
+// statWidth is the widest a diffstat bar gets.
+const statWidth = 40
+
+// WriteStat writes a diffstat: one line per change with its inserted and
+// deleted lines, or its block statistics, then a summary.
+func WriteStat(w io.Writer, changes []Change, o Options) error {
+	type row struct {
+		path     string
+		an       analysis
+		ins, del int
+	}
+	rows := make([]row, len(changes))
+	width, most := 0, 0
+	insTotal, delTotal := 0, 0
+	for i, c := range changes {
+		rows[i] = row{path: c.Label(), an: analyze(c, o)}
+		if rows[i].an.text {
+			rows[i].ins, rows[i].del = textdiff.Stat(rows[i].an.edits)
+			most = max(most, rows[i].ins+rows[i].del)
+			insTotal += rows[i].ins
+			delTotal += rows[i].del
+		}
+		width = max(width, len(rows[i].path))
+	}
+
+	color := colorizer(o.Color)
+	for _, r := range rows {
+		var detail string
+		if r.an.text {
+			ins, del := r.ins, r.del
+			if most > statWidth {
+				// scale, keeping any change visible
+				ins = scaled(ins, most)
+				del = scaled(del, most)
+			}
+			detail = fmt.Sprintf("%d %s%s", r.ins+r.del,
+				color('+', strings.Repeat("+", ins)), color('-', strings.Repeat("-", del)))
+		} else {
+			d := r.an.delta
+			detail = fmt.Sprintf("Bin %s reused, %s new", FormatSize(d.ReusedBytes), FormatSize(d.NewBytes))
+		}
+		if _, err := fmt.Fprintf(w, " %-*s | %s\n", width, r.path, detail); err != nil {
+			return err
+		}
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(rows), plural(len(rows), "file", "files"))
+	if insTotal > 0 {
+		summary += fmt.Sprintf(", %d %s(+)", insTotal, plural(insTotal, "insertion", "insertions"))
+	}
+	if delTotal > 0 {
+		summary += fmt.Sprintf(", %d %s(-)", delTotal, plural(delTotal, "deletion", "deletions"))
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func scaled(n, most int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*statWidth/most)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// FormatSize formats a byte count with a binary unit.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// colorizer returns the line wrapper for WriteUnified and the headers.
+func colorizer(enabled bool) func(op byte, line string) string {
+	return func(op byte, line string) string {
+		if !enabled || line == "" {
+			return line
+		}
+		switch op {
+		case '+':
+			return "\033[32m" + line + "\033[0m"
+		case '-':
+			return "\033[31m" + line + "\033[0m"
+		case '@':
+			return "\033[36m" + line + "\033[0m"
+		case 'h':
+			return "\033[1m" + line + "\033[0m"
+		}
+		return line
+	}
+}
@@ -0,0 +1,160 @@
+package changes_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+// content maps a block hash to its bytes; entries here have one block each.
+var content = map[string]string{
+	"t1":  "one\ntwo\n",
+	"t2":  "one\n2\n",
+	"bin": "\x00\x01\x02",
+	"new": "hello\n",
+}
+
+func entry(path, hash string) file.Entry {
+	return file.Entry{Path: path, Mode: 0o644, Blocks: []block.BlockRef{{Hash: hash, Size: int64(len(content[hash]))}}}
+}
+
+func read(e file.Entry) ([]byte, error) {
+	data, ok := content[e.Blocks[0].Hash]
+	if !ok {
+		return nil, fmt.Errorf("missing block")
+	}
+	return []byte(data), nil
+}
+
+func TestCompareAndRender(t *testing.T) {
+	old := &snapshot.Fileset{Files: []file.Entry{entry("a.txt", "t1"), entry("b.bin", "bin"), entry("gone.txt", "t1")}}
+	new := &snapshot.Fileset{Files: []file.Entry{entry("a.txt", "t2"), entry("b.bin", "bin"), entry("dir/c.txt", "new")}}
+
+	list := changes.Compare(old, new)
+	var ns bytes.Buffer
+	if err := changes.WriteNameStatus(&ns, list); err != nil {
+		t.Fatal(err)
+	}
+	if want := "M\ta.txt\nA\tdir/c.txt\nD\tgone.txt\n"; ns.String() != want {
+		t.Fatalf("name-status:\n%s\nwant:\n%s", ns.String(), want)
+	}
+
+	if got := changes.Filter(list, []string{"dir"}); len(got) != 1 || got[0].Path != "dir/c.txt" {
+		t.Fatalf("Filter(dir) = %v", got)
+	}
+	if got := changes.Filter(list, []string{"*.txt"}); len(got) != 2 {
+		t.Fatalf("Filter(*.txt) = %v", got)
+	}
+
+	opts := changes.Options{OldContent: read, NewContent: read, Context: 3}
+	var patch bytes.Buffer
+	if err := changes.WritePatch(&patch, list[:1], opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "diff --bvc a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"
+	if patch.String() != want {
+		t.Fatalf("patch:\n%s\nwant:\n%s", patch.String(), want)
+	}
+
+	var stat bytes.Buffer
+	if err := changes.WriteStat(&stat, list, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(stat.String(), " 3 files changed, 2 insertions(+), 3 deletions(-)\n") {
+		t.Fatalf("stat:\n%s", stat.String())
+	}
+}
+
+func TestBinaryPatch(t *testing.T) {
+	old := &snapshot.Fileset{Files: []file.Entry{entry("b.bin", "bin")}}
+	changed := entry("b.bin", "bin")
+	changed.Blocks = append(changed.Blocks, block.BlockRef{Hash: "more", Offset: 3, Size: 1024})
+	new := &snapshot.Fileset{Files: []file.Entry{changed}}
+
+	var patch bytes.Buffer
+	opts := changes.Options{OldContent: read, NewContent: read}
+	if err := changes.WritePatch(&patch, changes.Compare(old, new), opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(patch.String(), "Binary file: 3 B reused, 1.0 KiB new; blocks: 1 unchanged, 0 moved, 1 changed, 0 removed") {
+		t.Fatalf("patch:\n%s", patch.String())
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	old := &snapshot.Fileset{Files: []file.Entry{entry("a.txt", "t1"), entry("gone.txt", "new")}}
+	new := &snapshot.Fileset{Files: []file.Entry{entry("a.txt", "t2"), entry("b.bin", "bin")}}
+
+	var out bytes.Buffer
+	if err := changes.WriteSummary(&out, changes.Compare(old, new)); err != nil {
+		t.Fatal(err)
+	}
+	want := " M  a.txt     8 B -> 6 B  0 B reused, 6 B new; blocks: 0 unchanged, 0 moved, 1 changed, 1 removed\n" +
+		" A  b.bin     3 B\n" +
+		" D  gone.txt  6 B\n"
+	if out.String() != want {
+		t.Fatalf("summary:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func blocks(path string, sizes map[string]int64, hashes ...string) file.Entry {
+	e := file.Entry{Path: path, Mode: 0o644}
+	var off int64
+	for _, h := range hashes {
+		e.Blocks = append(e.Blocks, block.BlockRef{Hash: h, Size: sizes[h], Offset: off})
+		off += sizes[h]
+	}
+	return e
+}
+
+func TestDetectRenames(t *testing.T) {
+	sizes := map[string]int64{"a": 100, "b": 100, "c": 100, "d": 100, "x": 100}
+	old := &snapshot.Fileset{Files: []file.Entry{
+		blocks("art/hero.psd", sizes, "a", "b", "c", "d"),
+		blocks("art/tree.psd", sizes, "x"),
+		blocks("keep.psd", sizes, "c", "d"),
+	}}
+	new := &snapshot.Fileset{Files: []file.Entry{
+		blocks("assets/hero.psd", sizes, "a", "b", "c", "x"), // 75% of hero
+		blocks("assets/tree.psd", sizes, "x"),                // identical to tree
+		blocks("keep.psd", sizes, "c", "d"),
+		blocks("keep-copy.psd", sizes, "c", "d"),
+	}}
+
+	list := changes.DetectRenames(changes.Compare(old, new), old, changes.RenameThreshold, false)
+	var ns bytes.Buffer
+	if err := changes.WriteNameStatus(&ns, list); err != nil {
+		t.Fatal(err)
+	}
+	want := "R075\tart/hero.psd\tassets/hero.psd\nR100\tart/tree.psd\tassets/tree.psd\nA\tkeep-copy.psd\n"
+	if ns.String() != want {
+		t.Fatalf("renames:\n%s\nwant:\n%s", ns.String(), want)
+	}
+
+	list = changes.DetectRenames(changes.Compare(old, new), old, changes.RenameThreshold, true)
+	if c := list[2]; c.Status != changes.Copied || c.From != "keep.psd" || c.Similarity != 100 {
+		t.Fatalf("copy = %+v", c)
+	}
+	if got := changes.Filter(list, []string{"art"}); len(got) != 2 {
+		t.Fatalf("Filter(art) = %v", got)
+	}
+
+	var patch bytes.Buffer
+	if err := changes.WritePatch(&patch, list[1:2], changes.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "diff --bvc a/art/tree.psd b/assets/tree.psd\nsimilarity index 100%\nrename from art/tree.psd\nrename to assets/tree.psd\n"; patch.String() != want {
+		t.Fatalf("patch:\n%s\nwant:\n%s", patch.String(), want)
+	}
+
+	// below the threshold an add and a delete stay apart
+	if got := changes.DetectRenames(changes.Compare(old, new), old, 80, false); got[0].Status != changes.Deleted {
+		t.Fatalf("threshold 80: %+v", got[0])
+	}
+}
@@ -0,0 +1,194 @@
+package changes
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+// RenameThreshold is the similarity, in percent, from which an added file
+// is taken for a rename or copy of an old one.
+const RenameThreshold = 50
+
+// maxRenamePairs bounds the inexact comparisons of rename detection; past
+// it only identical content is paired.
+const maxRenamePairs = 1_000_000
+
+// DetectRenames pairs added and deleted files whose blocks are mostly shared
+// into renames. With copies, an added file still unpaired is also compared
+// with the files of the old tree, from, that share a block with it, and
+// becomes a copy of the closest one; as this looks at unmodified files too,
+// callers enable it only on request. Identical content is paired first. Similarity is the share of the
+// larger side's bytes found in the other side's blocks, so it reads from
+// block lists only. Empty files, directories and symbolic links are never
+// paired.
+func DetectRenames(changes []Change, from *snapshot.Fileset, threshold int, copies bool) []Change {
+	var added, deleted []int
+	for i, c := range changes {
+		switch {
+		case c.Status == Added && pairable(c.New):
+			added = append(added, i)
+		case c.Status == Deleted && pairable(c.Old):
+			deleted = append(deleted, i)
+		}
+	}
+	if len(added) == 0 || (len(deleted) == 0 && !copies) {
+		return changes
+	}
+
+	paired := make(map[int]Change) // by index of the added change
+	used := make(map[int]bool)     // deleted changes turned into renames
+	exact := len(added)*len(deleted) > maxRenamePairs
+
+	// identical content first, then the best match above the threshold
+	for _, wantExact := range []bool{true, false} {
+		if !wantExact && exact {
+			break
+		}
+		for _, ai := range added {
+			if _, ok := paired[ai]; ok {
+				continue
+			}
+			n := changes[ai].New
+			best, bestScore := -1, threshold-1
+			for _, di := range deleted {
+				if used[di] {
+					continue
+				}
+				o := changes[di].Old
+				var score int
+				if wantExact {
+					if !sameBlocks(o.Blocks, n.Blocks) {
+						continue
+					}
+					score = 100
+				} else {
+					score = Similarity(o.Blocks, n.Blocks)
+				}
+				if score > bestScore {
+					best, bestScore = di, score
+				}
+			}
+			if best >= 0 {
+				used[best] = true
+				paired[ai] = Change{Status: Renamed, Path: changes[ai].Path, From: changes[best].Path,
+					Similarity: bestScore, Old: changes[best].Old, New: n}
+			}
+		}
+	}
+
+	if copies && from != nil {
+		// only old files sharing a block with the added one can reach the
+		// threshold, so the old tree's blocks are indexed once by hash
+		byHash := make(map[string][]int)
+		for i := range from.Files {
+			o := &from.Files[i]
+			if !pairable(o) {
+				continue
+			}
+			for _, r := range o.Blocks {
+				if l := byHash[r.Hash]; len(l) == 0 || l[len(l)-1] != i {
+					byHash[r.Hash] = append(l, i)
+				}
+			}
+		}
+		compared := 0
+		for _, ai := range added {
+			if _, ok := paired[ai]; ok {
+				continue
+			}
+			n := changes[ai].New
+			seen := make(map[int]bool)
+			var best *file.Entry
+			bestScore := threshold - 1
+			for _, r := range n.Blocks {
+				for _, i := range byHash[r.Hash] {
+					if seen[i] {
+						continue
+					}
+					seen[i] = true
+					o := &from.Files[i]
+					var score int
+					if sameBlocks(o.Blocks, n.Blocks) {
+						score = 100
+					} else if compared >= maxRenamePairs {
+						continue
+					} else {
+						compared++
+						score = Similarity(o.Blocks, n.Blocks)
+					}
+					// ties go to the first path, whatever the fileset order
+					if score > bestScore || (score == bestScore && best != nil && entryPath(o) < entryPath(best)) {
+						best, bestScore = o, score
+					}
+				}
+			}
+			if best != nil {
+				paired[ai] = Change{Status: Copied, Path: changes[ai].Path, From: entryPath(best),
+					Similarity: bestScore, Old: best, New: n}
+			}
+		}
+	}
+
+	out := make([]Change, 0, len(changes))
+	for i, c := range changes {
+		if used[i] {
+			continue
+		}
+		if p, ok := paired[i]; ok {
+			c = p
+		}
+		out = append(out, c)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Similarity returns how much of two block lists is shared, in percent of
+// the larger one's bytes.
+func Similarity(a, b []block.BlockRef) int {
+	var sizeA, sizeB int64
+	count := make(map[string]int, len(a))
+	for _, r := range a {
+		sizeA += r.Size
+		count[r.Hash]++
+	}
+	var shared int64
+	for _, r := range b {
+		sizeB += r.Size
+		if count[r.Hash] > 0 {
+			count[r.Hash]--
+			shared += r.Size
+		}
+	}
+	larger := max(sizeA, sizeB)
+	if larger == 0 {
+		return 0
+	}
+	return int(shared * 100 / larger)
+}
+
+func sameBlocks(a, b []block.BlockRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// pairable reports whether an entry can be the source or target of a rename:
+// a regular file with content.
+func pairable(e *file.Entry) bool {
+	return e != nil && !e.IsDir() && !e.IsSymlink() && len(e.Blocks) > 0 && Size(*e) > 0
+}
+
+func entryPath(e *file.Entry) string {
+	return filepath.ToSlash(filepath.Clean(e.Path))
+}
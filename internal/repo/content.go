@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/repo/changes"
+	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+// GetWorkingFileset returns the working tree as the next commit would see
+// it after `add --update`: the files of the index tree as they are on disk.
+// Untracked files are left out, and files left out by sparse checkout are
+// taken from the index.
+func (r *Repository) GetWorkingFileset() (*snapshot.Fileset, error) {
+	next, err := r.GetIndexFileset()
+	if err != nil {
+		return nil, err
+	}
+	tracked, staged, _, err := r.Store.SnapshotCtx.BuildAllRepositoryFilesets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan working tree: %w", err)
+	}
+	sparse, err := r.Store.FileCtx.LoadSparse()
+	if err != nil {
+		return nil, err
+	}
+
+	work := make(map[string]file.Entry, len(tracked.Files)+len(staged.Files))
+	for _, e := range append(tracked.Files, staged.Files...) {
+		work[filepath.ToSlash(e.Path)] = e
+	}
+	var files []file.Entry
+	for _, e := range next.Files {
+		p := filepath.ToSlash(e.Path)
+		if w, ok := work[p]; ok {
+			files = append(files, w)
+		} else if !sparse.Includes(p) {
+			files = append(files, e)
+		}
+	}
+	return &snapshot.Fileset{Files: files}, nil
+}
+
+// StoredContent reads the content of an entry from the block store.
+// Symbolic links read as their target.
+func (r *Repository) StoredContent(e file.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.WriteStoredContent(&buf, e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteStoredContent streams the content of an entry from the block store,
+// one block at a time. Symbolic links read as their target.
+func (r *Repository) WriteStoredContent(w io.Writer, e file.Entry) error {
+	if e.IsSymlink() {
+		_, err := io.WriteString(w, e.Link)
+		return err
+	}
+	for _, b := range e.Blocks {
+		data, err := r.Store.BlockCtx.Read(b.Hash)
+		if err != nil {
+			return fmt.Errorf("missing block %s for %s", b.Hash, e.Path)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorkingContent reads the content of an entry from the working tree.
+// Symbolic links read as their target.
+func (r *Repository) WorkingContent(e file.Entry) ([]byte, error) {
+	if e.IsSymlink() {
+		return []byte(e.Link), nil
+	}
+	fc := r.Store.FileCtx
+	data, err := fc.FS.ReadFile(filepath.Join(fc.WorkingTreeDir, e.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", e.Path, err)
+	}
+	return data, nil
+}
+
+// CommitChanges lists the files a commit changed against its first parent,
+// with renames detected unless renames is false, and copies too with copies.
+func (r *Repository) CommitChanges(cmt *meta.Commit, renames, copies bool) ([]changes.Change, error) {
+	fs, err := r.GetCommittedFileset(cmt.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fileset of commit %s: %w", cmt.ID, err)
+	}
+	parentFS := &snapshot.Fileset{}
+	if len(cmt.Parents) > 0 {
+		if parentFS, err = r.GetCommittedFileset(cmt.Parents[0]); err != nil {
+			return nil, fmt.Errorf("failed to load fileset of parent %s: %w", cmt.Parents[0], err)
+		}
+	}
+	list := changes.Compare(parentFS, fs)
+	if renames {
+		list = changes.DetectRenames(list, parentFS, changes.RenameThreshold, copies)
+	}
+	return list, nil
+}
@@ -34,6 +34,7 @@ func (mc *MetaContext) CreateCommit(commit *Commit) (string, error) {
 	if err := util.WriteJSON(path, commit); err != nil {
 		return "", fmt.Errorf("failed to write commit %q: %w", commit.ID, err)
 	}
+	mc.addToCommitGraph(commit)
 	return commit.ID, nil
 }
 
@@ -68,6 +69,11 @@ func (mc *MetaContext) AllCommitIDs(branch string) ([]string, error) {
 	if lastID == "" {
 		return nil, nil
 	}
+	if g, err := mc.CommitGraphFor(lastID); err == nil && g.Contains(lastID) {
+		return g.FirstParentChain(lastID), nil
+	}
+
+	// fall back to walking commit files
 	var ids []string
 	seen := map[string]bool{}
 	for id := lastID; id != ""; {
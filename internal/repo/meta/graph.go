@@ -0,0 +1,620 @@
+package meta
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Commit-graph file layout (little endian):
+//
+//	magic "BVCG" | version u8 | reserved [3]u8 | count u32
+//	count × node: idLen u8 | id | timestamp i64 | generation u32 | flags u8 | nparents u8 | parents []u32
+//	checksum u64 (xxh3 of everything above)
+const (
+	graphMagic   = "BVCG"
+	graphVersion = 1
+
+	// nodeMissing marks a parent referenced by some commit whose own
+	// commit file was not available when the graph was built.
+	nodeMissing = 1 << 0
+)
+
+// GraphNode is one commit in the commit-graph.
+type GraphNode struct {
+	ID         string
+	Parents    []uint32 // indices into CommitGraph.Nodes
+	Generation uint32   // 1 for roots, 1 + max(parent generation) otherwise; 0 for missing commits
+	Timestamp  int64    // commit time (unix seconds)
+	Missing    bool
+}
+
+// CommitGraph is a compact, index-based view of the whole commit history.
+// It lets ancestry queries run in memory instead of reading one JSON file per commit.
+type CommitGraph struct {
+	Nodes []GraphNode
+	index map[string]uint32
+}
+
+func newCommitGraph() *CommitGraph {
+	return &CommitGraph{index: make(map[string]uint32)}
+}
+
+// Len returns the number of commits in the graph (missing ones included).
+func (g *CommitGraph) Len() int { return len(g.Nodes) }
+
+// Contains reports whether the graph knows a real (non-missing) commit with this ID.
+func (g *CommitGraph) Contains(id string) bool {
+	i, ok := g.index[id]
+	return ok && !g.Nodes[i].Missing
+}
+
+// Lookup returns the node index of a commit ID.
+func (g *CommitGraph) Lookup(id string) (uint32, bool) {
+	i, ok := g.index[id]
+	return i, ok
+}
+
+// Node returns the node for a commit ID.
+func (g *CommitGraph) Node(id string) (*GraphNode, bool) {
+	i, ok := g.index[id]
+	if !ok {
+		return nil, false
+	}
+	return &g.Nodes[i], true
+}
+
+// ParentIDs returns the parent commit IDs of id in commit order.
+func (g *CommitGraph) ParentIDs(id string) []string {
+	n, ok := g.Node(id)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(n.Parents))
+	for _, p := range n.Parents {
+		out = append(out, g.Nodes[p].ID)
+	}
+	return out
+}
+
+// add appends a commit to the graph. Parents that are not known yet are
+// recorded as missing nodes. It returns false if the commit was already
+// referenced as a missing parent, in which case generations of its children
+// are stale and the graph has to be rebuilt.
+func (g *CommitGraph) add(id string, parents []string, ts int64) bool {
+	if i, ok := g.index[id]; ok {
+		return !g.Nodes[i].Missing
+	}
+
+	node := GraphNode{ID: id, Timestamp: ts}
+	var maxGen uint32
+	for _, p := range parents {
+		if p == "" {
+			continue
+		}
+		pi, ok := g.index[p]
+		if !ok {
+			pi = uint32(len(g.Nodes))
+			g.Nodes = append(g.Nodes, GraphNode{ID: p, Missing: true})
+			g.index[p] = pi
+		}
+		node.Parents = append(node.Parents, pi)
+		if gen := g.Nodes[pi].Generation; gen > maxGen {
+			maxGen = gen
+		}
+	}
+	node.Generation = maxGen + 1
+
+	g.index[id] = uint32(len(g.Nodes))
+	g.Nodes = append(g.Nodes, node)
+	return true
+}
+
+// FirstParentChain returns id followed by its first-parent ancestors (latest -> oldest).
+func (g *CommitGraph) FirstParentChain(id string) []string {
+	i, ok := g.index[id]
+	if !ok {
+		return nil
+	}
+	var ids []string
+	seen := map[uint32]bool{}
+	for {
+		if seen[i] || g.Nodes[i].Missing {
+			break
+		}
+		seen[i] = true
+		ids = append(ids, g.Nodes[i].ID)
+		if len(g.Nodes[i].Parents) == 0 {
+			break
+		}
+		i = g.Nodes[i].Parents[0]
+	}
+	return ids
+}
+
+// Ancestors returns every commit reachable from the given tips (tips included),
+// newest first by commit time.
+func (g *CommitGraph) Ancestors(tips ...string) []string {
+	pq := &nodeQueue{g: g}
+	seen := map[uint32]bool{}
+	for _, t := range tips {
+		if i, ok := g.index[t]; ok && !seen[i] {
+			seen[i] = true
+			heap.Push(pq, i)
+		}
+	}
+
+	var out []string
+	for pq.Len() > 0 {
+		i := heap.Pop(pq).(uint32)
+		n := &g.Nodes[i]
+		if n.Missing {
+			continue
+		}
+		out = append(out, n.ID)
+		for _, p := range n.Parents {
+			if !seen[p] {
+				seen[p] = true
+				heap.Push(pq, p)
+			}
+		}
+	}
+	return out
+}
+
+// Range returns commits reachable from any of include but from none of exclude,
+// newest first by commit time.
+func (g *CommitGraph) Range(include, exclude []string) []string {
+	hidden := map[string]bool{}
+	if len(exclude) > 0 {
+		for _, id := range g.Ancestors(exclude...) {
+			hidden[id] = true
+		}
+	}
+
+	var out []string
+	for _, id := range g.Ancestors(include...) {
+		if !hidden[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// TopoOrder returns ids sorted parents first, by generation, keeping the
+// given order among commits of the same generation. Commit times are not
+// trusted: rebased or imported commits may be older than their parents.
+func (g *CommitGraph) TopoOrder(ids []string) []string {
+	out := slices.Clone(ids)
+	gen := func(id string) uint32 {
+		if i, ok := g.index[id]; ok {
+			return g.Nodes[i].Generation
+		}
+		return 0
+	}
+	sort.SliceStable(out, func(i, j int) bool { return gen(out[i]) < gen(out[j]) })
+	return out
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant (a commit is its own ancestor).
+func (g *CommitGraph) IsAncestor(ancestor, descendant string) bool {
+	a, ok := g.index[ancestor]
+	if !ok {
+		return false
+	}
+	d, ok := g.index[descendant]
+	if !ok {
+		return false
+	}
+	minGen := g.Nodes[a].Generation
+
+	stack := []uint32{d}
+	seen := map[uint32]bool{d: true}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i == a {
+			return true
+		}
+		for _, p := range g.Nodes[i].Parents {
+			// generation numbers let us skip whole subgraphs that are too old
+			if seen[p] || g.Nodes[p].Generation < minGen {
+				continue
+			}
+			seen[p] = true
+			stack = append(stack, p)
+		}
+	}
+	return false
+}
+
+// MergeBases returns all best common ancestors of a and b.
+func (g *CommitGraph) MergeBases(a, b string) []string {
+	ai, ok := g.index[a]
+	if !ok {
+		return nil
+	}
+	bi, ok := g.index[b]
+	if !ok {
+		return nil
+	}
+	if ai == bi {
+		return []string{a}
+	}
+
+	const (
+		fromA  = 1 << 0
+		fromB  = 1 << 1
+		stale  = 1 << 2
+		result = 1 << 3
+	)
+
+	// a commit is queued at most once, picking up flags while it waits;
+	// the walk ends when every queued commit is stale
+	flags := map[uint32]uint8{ai: fromA, bi: fromB}
+	queued := map[uint32]bool{ai: true, bi: true}
+	nonStale := 2
+	pq := &nodeQueue{g: g, byGeneration: true}
+	heap.Push(pq, ai)
+	heap.Push(pq, bi)
+
+	var found []uint32
+	for nonStale > 0 {
+		i := heap.Pop(pq).(uint32)
+		delete(queued, i)
+		if flags[i]&stale == 0 {
+			nonStale--
+		}
+		f := flags[i] & (fromA | fromB | stale)
+		if f == fromA|fromB {
+			if flags[i]&result == 0 {
+				flags[i] |= result
+				found = append(found, i)
+			}
+			f |= stale
+		}
+		for _, p := range g.Nodes[i].Parents {
+			if flags[p]&f == f {
+				continue
+			}
+			wasStale := flags[p]&stale != 0
+			flags[p] |= f
+			if queued[p] {
+				if !wasStale && f&stale != 0 {
+					nonStale--
+				}
+				continue
+			}
+			queued[p] = true
+			heap.Push(pq, p)
+			if flags[p]&stale == 0 {
+				nonStale++
+			}
+		}
+	}
+
+	// drop candidates that are ancestors of other candidates
+	var out []string
+	for _, c := range found {
+		redundant := false
+		for _, o := range found {
+			if o != c && g.IsAncestor(g.Nodes[c].ID, g.Nodes[o].ID) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant && !g.Nodes[c].Missing {
+			out = append(out, g.Nodes[c].ID)
+		}
+	}
+	return out
+}
+
+// MergeBase returns the single best common ancestor of a and b, or "" if none exists.
+// When several candidates are equally good, the most recent one is returned.
+func (g *CommitGraph) MergeBase(a, b string) string {
+	bases := g.MergeBases(a, b)
+	best := ""
+	for _, id := range bases {
+		if best == "" {
+			best = id
+			continue
+		}
+		bn, _ := g.Node(best)
+		n, _ := g.Node(id)
+		if n.Generation > bn.Generation || (n.Generation == bn.Generation && n.Timestamp > bn.Timestamp) {
+			best = id
+		}
+	}
+	return best
+}
+
+// nodeQueue is a max-heap of node indices ordered by commit time
+// (or by generation, when byGeneration is set).
+type nodeQueue struct {
+	g            *CommitGraph
+	items        []uint32
+	byGeneration bool
+}
+
+func (q *nodeQueue) Len() int { return len(q.items) }
+func (q *nodeQueue) Less(i, j int) bool {
+	a, b := &q.g.Nodes[q.items[i]], &q.g.Nodes[q.items[j]]
+	if q.byGeneration && a.Generation != b.Generation {
+		return a.Generation > b.Generation
+	}
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp > b.Timestamp
+	}
+	return a.ID > b.ID
+}
+func (q *nodeQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *nodeQueue) Push(x any)    { q.items = append(q.items, x.(uint32)) }
+func (q *nodeQueue) Pop() any {
+	n := len(q.items)
+	x := q.items[n-1]
+	q.items = q.items[:n-1]
+	return x
+}
+
+// encode serializes the graph into the commit-graph binary format.
+func (g *CommitGraph) encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(graphMagic)
+	buf.Write([]byte{graphVersion, 0, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, uint32(len(g.Nodes)))
+
+	for _, n := range g.Nodes {
+		buf.WriteByte(byte(len(n.ID)))
+		buf.WriteString(n.ID)
+		binary.Write(&buf, binary.LittleEndian, n.Timestamp)
+		binary.Write(&buf, binary.LittleEndian, n.Generation)
+		var fl uint8
+		if n.Missing {
+			fl |= nodeMissing
+		}
+		buf.WriteByte(fl)
+		buf.WriteByte(byte(len(n.Parents)))
+		for _, p := range n.Parents {
+			binary.Write(&buf, binary.LittleEndian, p)
+		}
+	}
+
+	binary.Write(&buf, binary.LittleEndian, xxh3.Hash(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// decodeCommitGraph parses a commit-graph file.
+func decodeCommitGraph(data []byte) (*CommitGraph, error) {
+	if len(data) < 20 || string(data[:4]) != graphMagic {
+		return nil, fmt.Errorf("not a commit-graph file")
+	}
+	if data[4] != graphVersion {
+		return nil, fmt.Errorf("unsupported commit-graph version %d", data[4])
+	}
+	body, sum := data[:len(data)-8], binary.LittleEndian.Uint64(data[len(data)-8:])
+	if xxh3.Hash(body) != sum {
+		return nil, fmt.Errorf("commit-graph checksum mismatch")
+	}
+
+	r := bytes.NewReader(body[8:])
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	g := newCommitGraph()
+	g.Nodes = make([]GraphNode, 0, count)
+	for i := uint32(0); i < count; i++ {
+		idLen, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		id := make([]byte, idLen)
+		if _, err := io.ReadFull(r, id); err != nil {
+			return nil, err
+		}
+		n := GraphNode{ID: string(id)}
+		if err := binary.Read(r, binary.LittleEndian, &n.Timestamp); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n.Generation); err != nil {
+			return nil, err
+		}
+		fl, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n.Missing = fl&nodeMissing != 0
+		np, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n.Parents = make([]uint32, np)
+		if err := binary.Read(r, binary.LittleEndian, n.Parents); err != nil {
+			return nil, err
+		}
+		for _, p := range n.Parents {
+			if p >= count {
+				return nil, fmt.Errorf("commit-graph parent index %d out of range", p)
+			}
+		}
+		g.index[n.ID] = i
+		g.Nodes = append(g.Nodes, n)
+	}
+	return g, nil
+}
+
+// CommitGraph returns the repository commit-graph, loading it from disk on
+// first use. A missing or unreadable graph file is rebuilt from the commits directory.
+func (mc *MetaContext) CommitGraph() (*CommitGraph, error) {
+	if mc.graph != nil {
+		return mc.graph, nil
+	}
+
+	data, err := mc.FS.ReadFile(mc.Config.CommitGraphFile())
+	if err == nil {
+		if g, derr := decodeCommitGraph(data); derr == nil {
+			mc.graph = g
+			return g, nil
+		}
+	}
+	return mc.RebuildCommitGraph()
+}
+
+// CommitGraphFor returns the commit-graph, rebuilding it once if it does not
+// know one of the given commits (e.g. commits written by an older bvc).
+func (mc *MetaContext) CommitGraphFor(ids ...string) (*CommitGraph, error) {
+	g, err := mc.CommitGraph()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id != "" && !g.Contains(id) && mc.commitExists(id) {
+			return mc.RebuildCommitGraph()
+		}
+	}
+	return g, nil
+}
+
+// RebuildCommitGraph regenerates the commit-graph from all commit files and writes it to disk.
+func (mc *MetaContext) RebuildCommitGraph() (*CommitGraph, error) {
+	entries, err := mc.FS.ReadDir(mc.Config.CommitsDir())
+	if err != nil && !mc.FS.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read commits directory: %w", err)
+	}
+
+	commits := make(map[string]*Commit, len(entries))
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, "tmp-") {
+			continue
+		}
+		c, err := mc.GetCommit(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		commits[c.ID] = c
+		ids = append(ids, c.ID)
+	}
+
+	// insert commits parents-first; edges closing a cycle are dropped
+	g := newCommitGraph()
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(commits))
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		c := commits[id]
+		var parents []string
+		for _, p := range c.Parents {
+			if p == "" || state[p] == visiting {
+				continue
+			}
+			if _, ok := commits[p]; ok && state[p] == 0 {
+				visit(p)
+			}
+			parents = append(parents, p)
+		}
+		g.add(id, parents, parseCommitTime(c.Timestamp))
+		state[id] = done
+	}
+	for _, id := range ids {
+		if state[id] == 0 {
+			visit(id)
+		}
+	}
+
+	mc.graph = g
+	if err := mc.writeCommitGraph(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// addToCommitGraph records a freshly written commit in the commit-graph.
+// The graph is only a cache: if it cannot be updated it is removed and
+// rebuilt on next use.
+func (mc *MetaContext) addToCommitGraph(c *Commit) {
+	g, err := mc.CommitGraph()
+	if err == nil {
+		if g.add(c.ID, c.Parents, parseCommitTime(c.Timestamp)) {
+			err = mc.writeCommitGraph(g)
+		} else {
+			_, err = mc.RebuildCommitGraph()
+		}
+	}
+	if err != nil {
+		mc.graph = nil
+		_ = mc.FS.Remove(mc.Config.CommitGraphFile())
+	}
+}
+
+// writeCommitGraph atomically replaces the commit-graph file.
+func (mc *MetaContext) writeCommitGraph(g *CommitGraph) error {
+	path := mc.Config.CommitGraphFile()
+	tmp, tmpPath, err := mc.FS.CreateTempFile(filepath.Dir(path), "tmp-graph-*")
+	if err != nil {
+		return fmt.Errorf("failed to write commit-graph: %w", err)
+	}
+	defer mc.FS.Remove(tmpPath)
+
+	if _, err := tmp.Write(g.encode()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write commit-graph: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write commit-graph: %w", err)
+	}
+	if err := mc.FS.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write commit-graph: %w", err)
+	}
+	return nil
+}
+
+func (mc *MetaContext) commitExists(id string) bool {
+	_, err := mc.FS.Stat(filepath.Join(mc.Config.CommitsDir(), id+".json"))
+	return err == nil
+}
+
+func parseCommitTime(ts string) int64 {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// MergeBase returns the best common ancestor of two commits, or "" if they share no history.
+func (mc *MetaContext) MergeBase(a, b string) (string, error) {
+	if a == "" || b == "" {
+		return "", nil
+	}
+	g, err := mc.CommitGraphFor(a, b)
+	if err != nil {
+		return "", err
+	}
+	return g.MergeBase(a, b), nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant.
+func (mc *MetaContext) IsAncestor(ancestor, descendant string) (bool, error) {
+	g, err := mc.CommitGraphFor(ancestor, descendant)
+	if err != nil {
+		return false, err
+	}
+	return g.IsAncestor(ancestor, descendant), nil
+}
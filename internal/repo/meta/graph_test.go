@@ -0,0 +1,206 @@
+package meta_test
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+// mkCommit writes a commit with the given parents and a deterministic timestamp.
+func mkCommit(t *testing.T, mc *meta.MetaContext, id string, ts int, parents ...string) {
+	t.Helper()
+	c := &meta.Commit{
+		ID:        id,
+		Parents:   parents,
+		Branch:    config.DefaultBranch,
+		Message:   id,
+		Timestamp: time.Unix(int64(1700000000+ts), 0).UTC().Format(time.RFC3339),
+	}
+	if _, err := mc.CreateCommit(c); err != nil {
+		t.Fatalf("CreateCommit %s failed: %v", id, err)
+	}
+}
+
+// history:
+//
+//	A - B - C - F (main)
+//	     \     /
+//	      D - E (feature)
+func buildHistory(t *testing.T) *repo.Repository {
+	t.Helper()
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+	mkCommit(t, r.Meta, "A", 1)
+	mkCommit(t, r.Meta, "B", 2, "A")
+	mkCommit(t, r.Meta, "C", 3, "B")
+	mkCommit(t, r.Meta, "D", 4, "B")
+	mkCommit(t, r.Meta, "E", 5, "D")
+	mkCommit(t, r.Meta, "F", 6, "C", "E")
+	return r
+}
+
+func TestCommitGraph_MergeBase(t *testing.T) {
+	r := buildHistory(t)
+
+	cases := []struct{ a, b, want string }{
+		{"C", "E", "B"},
+		{"F", "E", "E"},
+		{"A", "F", "A"},
+		{"D", "D", "D"},
+	}
+	for _, tc := range cases {
+		got, err := r.Meta.MergeBase(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("MergeBase(%s, %s) failed: %v", tc.a, tc.b, err)
+		}
+		if got != tc.want {
+			t.Errorf("MergeBase(%s, %s) = %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// criss-cross history:
+//
+//	A - B - M1
+//	 \   X
+//	  C - M2
+func TestCommitGraph_MergeBases_CrissCross(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+	mkCommit(t, r.Meta, "A", 1)
+	mkCommit(t, r.Meta, "B", 2, "A")
+	mkCommit(t, r.Meta, "C", 3, "A")
+	mkCommit(t, r.Meta, "M1", 4, "B", "C")
+	mkCommit(t, r.Meta, "M2", 5, "C", "B")
+
+	g, err := r.Meta.CommitGraph()
+	if err != nil {
+		t.Fatalf("CommitGraph failed: %v", err)
+	}
+	got := g.MergeBases("M1", "M2")
+	sort.Strings(got)
+	if want := []string{"B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeBases(M1, M2) = %v, want %v", got, want)
+	}
+}
+
+func TestCommitGraph_TopoOrder(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+	// B and D were rebased: older than their parents
+	mkCommit(t, r.Meta, "A", 5)
+	mkCommit(t, r.Meta, "B", 1, "A")
+	mkCommit(t, r.Meta, "C", 9, "B")
+	mkCommit(t, r.Meta, "D", 2, "C")
+
+	g, err := r.Meta.CommitGraph()
+	if err != nil {
+		t.Fatalf("CommitGraph failed: %v", err)
+	}
+	ids := g.Range([]string{"D"}, []string{"A"})
+	if got, want := g.TopoOrder(ids), []string{"B", "C", "D"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TopoOrder(%v) = %v, want %v", ids, got, want)
+	}
+}
+
+func TestCommitGraph_Ancestry(t *testing.T) {
+	r := buildHistory(t)
+
+	g, err := r.Meta.CommitGraph()
+	if err != nil {
+		t.Fatalf("CommitGraph failed: %v", err)
+	}
+
+	if !g.IsAncestor("D", "F") {
+		t.Error("expected D to be an ancestor of F")
+	}
+	if g.IsAncestor("C", "E") {
+		t.Error("did not expect C to be an ancestor of E")
+	}
+
+	got := g.Ancestors("F")
+	want := []string{"F", "E", "D", "C", "B", "A"}
+	if len(got) != len(want) {
+		t.Fatalf("Ancestors(F) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Ancestors(F) = %v, want %v", got, want)
+		}
+	}
+
+	if n, _ := g.Node("F"); n.Generation != 5 {
+		t.Errorf("expected generation 5 for F, got %d", n.Generation)
+	}
+
+	chain := g.FirstParentChain("F")
+	if len(chain) != 4 || chain[1] != "C" {
+		t.Errorf("unexpected first-parent chain: %v", chain)
+	}
+}
+
+func TestCommitGraph_PersistAndRebuild(t *testing.T) {
+	r := buildHistory(t)
+	cfg := r.Config
+
+	if _, err := os.Stat(cfg.CommitGraphFile()); err != nil {
+		t.Fatalf("expected commit-graph file: %v", err)
+	}
+
+	// reopen: graph is read back from disk
+	r2, err := repo.NewRepositoryByPath(cfg.RepoDir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	g, err := r2.Meta.CommitGraph()
+	if err != nil {
+		t.Fatalf("CommitGraph failed: %v", err)
+	}
+	if g.Len() != 6 {
+		t.Errorf("expected 6 commits in graph, got %d", g.Len())
+	}
+
+	// corrupt graph is rebuilt from commit files
+	if err := os.WriteFile(cfg.CommitGraphFile(), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r3, _ := repo.NewRepositoryByPath(cfg.RepoDir)
+	base, err := r3.Meta.MergeBase("C", "E")
+	if err != nil || base != "B" {
+		t.Errorf("expected merge base B after rebuild, got %q (%v)", base, err)
+	}
+}
+
+func TestCommitGraph_OutOfOrderCommits(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+
+	// child is recorded before its parent exists
+	mkCommit(t, r.Meta, "child", 2, "parent")
+	mkCommit(t, r.Meta, "parent", 1)
+
+	g, err := r.Meta.CommitGraph()
+	if err != nil {
+		t.Fatalf("CommitGraph failed: %v", err)
+	}
+	if n, _ := g.Node("child"); n.Generation != 2 {
+		t.Errorf("expected generation 2 for child, got %d", n.Generation)
+	}
+	if !g.IsAncestor("parent", "child") {
+		t.Error("expected parent to be an ancestor of child")
+	}
+}
@@ -13,6 +13,8 @@ import (
 type MetaContext struct {
 	Config *config.RepoConfig
 	FS     fs.FS
+
+	graph *CommitGraph // lazily loaded commit-graph cache
 }
 
 // NewMetaDefault creates a meta with default dependencies (FS)
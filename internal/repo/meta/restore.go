@@ -0,0 +1,125 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// RestoreJournal records a working tree update in progress, so that a
+// checkout or reset --hard interrupted half way can be finished or undone.
+type RestoreJournal struct {
+	Command    string   `json:"command"`         // "checkout" or "reset"
+	Branch     string   `json:"branch"`          // branch HEAD points to when done
+	FromBranch string   `json:"from_branch"`     // branch HEAD pointed to before
+	FromCommit string   `json:"from_commit"`     // commit the working tree was at
+	ToCommit   string   `json:"to_commit"`       // target commit; "" for an empty branch
+	Reset      bool     `json:"reset,omitempty"` // every tracked path is rewritten, as reset --hard does
+	Force      bool     `json:"force,omitempty"` // local changes are discarded
+	Done       []string `json:"done,omitempty"`  // paths already updated
+	Label      string   `json:"label,omitempty"` // description for progress output
+}
+
+// The journal file holds the RestoreJournal as a JSON object, followed by
+// one JSON string per line for each path updated since it was written.
+// Progress is appended, so recording it costs the new paths only.
+
+// GetRestoreJournal returns the journal of an interrupted restore, or nil.
+// A path cut short by a crash while it was appended is ignored.
+func (mc *MetaContext) GetRestoreJournal() (*RestoreJournal, error) {
+	data, err := mc.FS.ReadFile(mc.Config.RestoreJournalFile())
+	if err != nil {
+		if mc.FS.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read restore journal: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var j RestoreJournal
+	if err := dec.Decode(&j); err != nil {
+		return nil, fmt.Errorf("failed to read restore journal: %w", err)
+	}
+	for {
+		var p string
+		if err := dec.Decode(&p); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read restore journal: %w", err)
+		}
+		j.Done = append(j.Done, p)
+	}
+	return &j, nil
+}
+
+// SaveRestoreJournal atomically replaces the restore journal with j.
+func (mc *MetaContext) SaveRestoreJournal(j *RestoreJournal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to write restore journal: %w", err)
+	}
+	path := mc.Config.RestoreJournalFile()
+	tmp, tmpPath, err := mc.FS.CreateTempFile(filepath.Dir(path), "tmp-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to write restore journal: %w", err)
+	}
+	defer mc.FS.Remove(tmpPath)
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write restore journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write restore journal: %w", err)
+	}
+	if err := mc.FS.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write restore journal: %w", err)
+	}
+	return nil
+}
+
+// AppendRestoreJournal records more updated paths at the end of the restore
+// journal, which SaveRestoreJournal must have written.
+func (mc *MetaContext) AppendRestoreJournal(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, p := range paths {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("failed to append to restore journal: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	path := mc.Config.RestoreJournalFile()
+	info, err := mc.FS.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to append to restore journal: %w", err)
+	}
+	f, err := mc.FS.OpenInPlace(path)
+	if err != nil {
+		return fmt.Errorf("failed to append to restore journal: %w", err)
+	}
+	if _, err := f.WriteAt(buf.Bytes(), info.Size()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append to restore journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to append to restore journal: %w", err)
+	}
+	return nil
+}
+
+// ClearRestoreJournal removes the restore journal once the restore is
+// finished or aborted.
+func (mc *MetaContext) ClearRestoreJournal() error {
+	if err := mc.FS.Remove(mc.Config.RestoreJournalFile()); err != nil && !mc.FS.IsNotExist(err) {
+		return fmt.Errorf("failed to remove restore journal: %w", err)
+	}
+	return nil
+}
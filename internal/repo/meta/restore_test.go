@@ -0,0 +1,78 @@
+package meta_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+func TestRestoreJournal(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+
+	if j, err := r.Meta.GetRestoreJournal(); err != nil || j != nil {
+		t.Fatalf("expected no journal, got %+v (%v)", j, err)
+	}
+
+	want := &meta.RestoreJournal{
+		Command:    "checkout",
+		Branch:     "feature",
+		FromBranch: "main",
+		FromCommit: "c1",
+		ToCommit:   "c2",
+		Done:       []string{"a.txt", "dir/b.txt"},
+	}
+	if err := r.Meta.SaveRestoreJournal(want); err != nil {
+		t.Fatalf("SaveRestoreJournal failed: %v", err)
+	}
+	got, err := r.Meta.GetRestoreJournal()
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRestoreJournal = %+v (%v), want %+v", got, err, want)
+	}
+
+	if err := r.Meta.ClearRestoreJournal(); err != nil {
+		t.Fatalf("ClearRestoreJournal failed: %v", err)
+	}
+	if j, _ := r.Meta.GetRestoreJournal(); j != nil {
+		t.Errorf("expected journal to be cleared, got %+v", j)
+	}
+	if err := r.Meta.ClearRestoreJournal(); err != nil {
+		t.Errorf("clearing a missing journal: %v", err)
+	}
+}
+
+func TestRestoreJournal_Append(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+
+	j := &meta.RestoreJournal{Command: "reset", Branch: "main", FromCommit: "c1", ToCommit: "c2", Done: []string{"a.txt"}}
+	if err := r.Meta.SaveRestoreJournal(j); err != nil {
+		t.Fatalf("SaveRestoreJournal failed: %v", err)
+	}
+	if err := r.Meta.AppendRestoreJournal([]string{"b.txt", "line\nbreak.txt"}); err != nil {
+		t.Fatalf("AppendRestoreJournal failed: %v", err)
+	}
+	if err := r.Meta.AppendRestoreJournal([]string{"c.txt"}); err != nil {
+		t.Fatalf("AppendRestoreJournal failed: %v", err)
+	}
+	want := []string{"a.txt", "b.txt", "line\nbreak.txt", "c.txt"}
+	got, err := r.Meta.GetRestoreJournal()
+	if err != nil || !reflect.DeepEqual(got.Done, want) {
+		t.Fatalf("Done = %q (%v), want %q", got.Done, err, want)
+	}
+
+	// a path cut short by a crash is dropped
+	path := r.Meta.Config.RestoreJournalFile()
+	data, _ := r.Meta.FS.ReadFile(path)
+	r.Meta.FS.WriteFile(path, append(data, `"d.t`...), 0o644)
+	got, err = r.Meta.GetRestoreJournal()
+	if err != nil || !reflect.DeepEqual(got.Done, want) {
+		t.Errorf("Done = %q (%v), want %q", got.Done, err, want)
+	}
+}
@@ -0,0 +1,142 @@
+package meta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResolveRevision turns a revision expression into a commit ID.
+//
+// Supported forms:
+//
+//	HEAD              last commit of the current branch
+//	<branch>          last commit of a branch
+//	<commit-id>       full commit ID or a unique prefix of one
+//	<rev>^, <rev>^N   first (or N-th) parent
+//	<rev>~N           N-th first-parent ancestor
+//
+// A prefix must be at least minPrefixLen characters long.
+func (mc *MetaContext) ResolveRevision(rev string) (string, error) {
+	if rev == "" {
+		return "", fmt.Errorf("empty revision")
+	}
+
+	// split off trailing ^/~ navigation
+	cut := strings.IndexAny(rev, "^~")
+	base, nav := rev, ""
+	if cut > 0 {
+		base, nav = rev[:cut], rev[cut:]
+	}
+
+	id, err := mc.resolveBaseRevision(base)
+	if err != nil {
+		return "", err
+	}
+
+	for nav != "" {
+		op := nav[0]
+		nav = nav[1:]
+		n := 1
+		digits := 0
+		for digits < len(nav) && nav[digits] >= '0' && nav[digits] <= '9' {
+			digits++
+		}
+		if digits > 0 {
+			n, _ = strconv.Atoi(nav[:digits])
+			nav = nav[digits:]
+		}
+
+		switch op {
+		case '^':
+			if n == 0 {
+				continue
+			}
+			c, err := mc.GetCommit(id)
+			if err != nil {
+				return "", err
+			}
+			if n > len(c.Parents) || c.Parents[n-1] == "" {
+				return "", fmt.Errorf("revision %q: commit %s has no parent %d", rev, id, n)
+			}
+			id = c.Parents[n-1]
+		case '~':
+			for i := 0; i < n; i++ {
+				c, err := mc.GetCommit(id)
+				if err != nil {
+					return "", err
+				}
+				if len(c.Parents) == 0 || c.Parents[0] == "" {
+					return "", fmt.Errorf("revision %q: commit %s has no parent", rev, id)
+				}
+				id = c.Parents[0]
+			}
+		default:
+			return "", fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+	return id, nil
+}
+
+// minPrefixLen is the shortest commit ID prefix a revision may give.
+const minPrefixLen = 4
+
+func (mc *MetaContext) resolveBaseRevision(name string) (string, error) {
+	if name == "HEAD" || name == "@" {
+		branch, err := mc.GetCurrentBranch()
+		if err != nil {
+			return "", err
+		}
+		id, err := mc.GetLastCommitID(branch.Name)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			return "", fmt.Errorf("HEAD: branch %q has no commits", branch.Name)
+		}
+		return id, nil
+	}
+
+	// names are looked up as files in the repository directory
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid revision %q", name)
+	}
+
+	if ok, _ := mc.BranchExists(name); ok {
+		id, err := mc.GetLastCommitID(name)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			return "", fmt.Errorf("branch %q has no commits", name)
+		}
+		return id, nil
+	}
+
+	if mc.commitExists(name) {
+		return name, nil
+	}
+
+	// unique prefix of a known commit
+	if len(name) < minPrefixLen {
+		return "", fmt.Errorf("unknown revision %q", name)
+	}
+	g, err := mc.CommitGraph()
+	if err != nil {
+		return "", err
+	}
+	match := ""
+	for _, n := range g.Nodes {
+		if n.Missing || !strings.HasPrefix(n.ID, name) {
+			continue
+		}
+		if match != "" {
+			return "", fmt.Errorf("ambiguous revision %q", name)
+		}
+		match = n.ID
+	}
+	if match == "" {
+		return "", fmt.Errorf("unknown revision %q", name)
+	}
+	return match, nil
+}
@@ -0,0 +1,73 @@
+package meta_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/config"
+)
+
+func TestResolveRevision(t *testing.T) {
+	r := buildHistory(t)
+	if err := r.Meta.SetLastCommitID(config.DefaultBranch, "F"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct{ rev, want string }{
+		{"HEAD", "F"},
+		{config.DefaultBranch, "F"},
+		{"E", "E"},
+		{"F^", "C"},
+		{"F^2", "E"},
+		{"F~2", "B"},
+		{"HEAD^2~1", "D"},
+		{"F^0", "F"},
+	}
+	for _, tc := range cases {
+		got, err := r.Meta.ResolveRevision(tc.rev)
+		if err != nil {
+			t.Errorf("ResolveRevision(%q) failed: %v", tc.rev, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ResolveRevision(%q) = %q, want %q", tc.rev, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"", "nope", "A^", "B~5", "../branches/main", "x/y", `x\y`} {
+		if _, err := r.Meta.ResolveRevision(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestResolveRevision_Prefix(t *testing.T) {
+	r := buildHistory(t)
+	mkCommit(t, r.Meta, "1a2b3c4d", 7, "F")
+	mkCommit(t, r.Meta, "1a2bffff", 8, "F")
+
+	if got, err := r.Meta.ResolveRevision("1a2b3"); err != nil || got != "1a2b3c4d" {
+		t.Errorf("ResolveRevision(1a2b3) = %q (%v), want 1a2b3c4d", got, err)
+	}
+	for _, bad := range []string{"1", "1a2", "1a2b"} {
+		if got, err := r.Meta.ResolveRevision(bad); err == nil {
+			t.Errorf("ResolveRevision(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestCommitGraph_Range(t *testing.T) {
+	r := buildHistory(t)
+	g, err := r.Meta.CommitGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.Range([]string{"E"}, []string{"C"})
+	if len(got) != 2 || got[0] != "E" || got[1] != "D" {
+		t.Errorf("Range(C..E) = %v, want [E D]", got)
+	}
+
+	if got := g.Range([]string{"C"}, []string{"F"}); len(got) != 0 {
+		t.Errorf("Range(F..C) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,83 @@
+package meta
+
+import (
+	"fmt"
+
+	"github.com/keshon/bvc/internal/util"
+)
+
+// StashEntry is one shelved set of index and working-tree changes.
+type StashEntry struct {
+	ID             string   `json:"id"`
+	Branch         string   `json:"branch"`
+	Base           string   `json:"base"` // commit the changes were made on top of
+	Message        string   `json:"message"`
+	Timestamp      string   `json:"timestamp"`
+	IndexFilesetID string   `json:"index_fileset_id,omitempty"`
+	WorkFilesetID  string   `json:"work_fileset_id,omitempty"`
+	IndexDeleted   []string `json:"index_deleted,omitempty"` // paths staged for deletion
+	Deleted        []string `json:"deleted,omitempty"`       // tracked paths missing from the working tree
+}
+
+// ListStash returns the stash stack, newest first.
+func (mc *MetaContext) ListStash() ([]StashEntry, error) {
+	path := mc.Config.StashFile()
+	if _, err := mc.FS.Stat(path); mc.FS.IsNotExist(err) {
+		return nil, nil
+	}
+	var entries []StashEntry
+	if err := util.ReadJSON(path, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read stash: %w", err)
+	}
+	return entries, nil
+}
+
+// GetStash returns the stash entry at position n (0 is the newest).
+func (mc *MetaContext) GetStash(n int) (StashEntry, error) {
+	entries, err := mc.ListStash()
+	if err != nil {
+		return StashEntry{}, err
+	}
+	if n < 0 || n >= len(entries) {
+		return StashEntry{}, fmt.Errorf("stash@{%d} does not exist", n)
+	}
+	return entries[n], nil
+}
+
+// PushStash puts a new entry on top of the stash stack.
+func (mc *MetaContext) PushStash(e StashEntry) error {
+	entries, err := mc.ListStash()
+	if err != nil {
+		return err
+	}
+	entries = append([]StashEntry{e}, entries...)
+	return mc.saveStash(entries)
+}
+
+// DropStash removes the entry at position n and returns it.
+func (mc *MetaContext) DropStash(n int) (StashEntry, error) {
+	entries, err := mc.ListStash()
+	if err != nil {
+		return StashEntry{}, err
+	}
+	if n < 0 || n >= len(entries) {
+		return StashEntry{}, fmt.Errorf("stash@{%d} does not exist", n)
+	}
+	dropped := entries[n]
+	entries = append(entries[:n], entries[n+1:]...)
+	return dropped, mc.saveStash(entries)
+}
+
+func (mc *MetaContext) saveStash(entries []StashEntry) error {
+	path := mc.Config.StashFile()
+	if len(entries) == 0 {
+		if err := mc.FS.Remove(path); err != nil && !mc.FS.IsNotExist(err) {
+			return fmt.Errorf("failed to clear stash: %w", err)
+		}
+		return nil
+	}
+	if err := util.WriteJSON(path, entries); err != nil {
+		return fmt.Errorf("failed to write stash: %w", err)
+	}
+	return nil
+}
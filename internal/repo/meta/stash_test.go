@@ -0,0 +1,51 @@
+package meta_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo"
+	"github.com/keshon/bvc/internal/repo/meta"
+)
+
+func TestStashStack(t *testing.T) {
+	r, err := repo.NewRepositoryByPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("InitAt failed: %v", err)
+	}
+
+	entries, err := r.Meta.ListStash()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty stash, got %v (%v)", entries, err)
+	}
+
+	for _, msg := range []string{"first", "second", "third"} {
+		if err := r.Meta.PushStash(meta.StashEntry{ID: msg, Message: msg}); err != nil {
+			t.Fatalf("PushStash failed: %v", err)
+		}
+	}
+
+	top, err := r.Meta.GetStash(0)
+	if err != nil || top.Message != "third" {
+		t.Errorf("expected newest entry on top, got %q (%v)", top.Message, err)
+	}
+
+	dropped, err := r.Meta.DropStash(1)
+	if err != nil || dropped.Message != "second" {
+		t.Errorf("expected to drop second, got %q (%v)", dropped.Message, err)
+	}
+
+	entries, _ = r.Meta.ListStash()
+	if len(entries) != 2 || entries[0].Message != "third" || entries[1].Message != "first" {
+		t.Errorf("unexpected stash after drop: %+v", entries)
+	}
+
+	if _, err := r.Meta.GetStash(5); err == nil {
+		t.Error("expected error for out-of-range stash")
+	}
+
+	r.Meta.DropStash(0)
+	r.Meta.DropStash(0)
+	if entries, _ := r.Meta.ListStash(); len(entries) != 0 {
+		t.Errorf("expected empty stash, got %d entries", len(entries))
+	}
+}
@@ -49,6 +49,38 @@ func (r *Repository) GetCommittedFileset(commitID string) (*snapshot.Fileset, er
 	return &fs, nil
 }
 
+// GetHeadFileset returns the fileset of the current branch's last commit,
+// or an empty fileset when the branch has no commits yet.
+func (r *Repository) GetHeadFileset() (*snapshot.Fileset, error) {
+	branch, err := r.Meta.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	commitID, err := r.Meta.GetLastCommitID(branch.Name)
+	if err != nil {
+		return nil, err
+	}
+	if commitID == "" {
+		return &snapshot.Fileset{}, nil
+	}
+	return r.GetCommittedFileset(commitID)
+}
+
+// GetIndexFileset returns the tree the next commit would record:
+// the HEAD fileset with the staged index entries overlaid.
+func (r *Repository) GetIndexFileset() (*snapshot.Fileset, error) {
+	head, err := r.GetHeadFileset()
+	if err != nil {
+		return nil, err
+	}
+	index, err := r.Store.FileCtx.LoadIndex()
+	if err != nil {
+		return nil, err
+	}
+	next := snapshot.ApplyIndex(head, index)
+	return &next, nil
+}
+
 func IsRepoExists(path string) bool {
 	cfg := config.NewRepoConfig(path)
 	return meta.IsMetaExists(cfg)
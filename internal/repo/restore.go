@@ -0,0 +1,260 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/meta"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+// restoreFlushEvery is how many updated paths may be lost from the restore
+// journal on a crash. Redoing them is harmless: a path already as wanted is
+// skipped.
+const restoreFlushEvery = 100
+
+// Restore moves the working tree from j.FromCommit to j.ToCommit and then
+// points HEAD at j.Branch, recording progress in the restore journal. If the
+// restore fails half way the journal is kept, and ContinueRestore or
+// AbortRestore finishes or undoes it. Paths in j.Done are not touched again.
+func (r *Repository) Restore(j *meta.RestoreJournal) error {
+	from, err := r.filesetOf(j.FromCommit)
+	if err != nil {
+		return err
+	}
+	to, err := r.filesetOf(j.ToCommit)
+	if err != nil {
+		return err
+	}
+	resumed := len(j.Done) > 0
+
+	// a change staged on a path the restore rewrites would be carried over
+	// and recorded by the next commit; reset --hard drops the index anyway
+	if !resumed && !j.Reset {
+		staged, err := r.stagedOn(touchedPaths(from, to))
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(staged) > 0 && j.Force:
+			if err := r.unstage(staged); err != nil {
+				return err
+			}
+		case len(staged) > 0:
+			return &file.DirtyTreeError{Staged: staged}
+		}
+	}
+
+	if err := r.Meta.SaveRestoreJournal(j); err != nil {
+		return err
+	}
+
+	done := make(map[string]bool, len(j.Done))
+	for _, p := range j.Done {
+		done[p] = true
+	}
+	var unsaved []string
+	opts := file.CheckoutOptions{
+		Force: j.Force,
+		Reset: j.Reset,
+		Label: j.Label,
+		Skip:  func(p string) bool { return done[p] },
+		Done: func(p string) error {
+			j.Done = append(j.Done, p)
+			if unsaved = append(unsaved, p); len(unsaved) < restoreFlushEvery {
+				return nil
+			}
+			err := r.Meta.AppendRestoreJournal(unsaved)
+			unsaved = unsaved[:0]
+			return err
+		},
+	}
+	if err := r.Store.FileCtx.Checkout(from.Files, to.Files, opts); err != nil {
+		var dirty *file.DirtyTreeError
+		if errors.As(err, &dirty) && !resumed {
+			// nothing was touched
+			if cerr := r.Meta.ClearRestoreJournal(); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+		if serr := r.Meta.AppendRestoreJournal(unsaved); serr != nil {
+			return fmt.Errorf("%v; %w", err, serr)
+		}
+		return fmt.Errorf("%w\n%s", err, InterruptedRestoreHint(j))
+	}
+
+	if _, err := r.Meta.SetHeadRef(j.Branch); err != nil {
+		return err
+	}
+	if j.ToCommit != "" {
+		if err := r.Meta.SetLastCommitID(j.Branch, j.ToCommit); err != nil {
+			return err
+		}
+	}
+	if j.Reset {
+		if err := r.Store.FileCtx.ClearIndex(); err != nil {
+			return err
+		}
+	}
+	return r.Meta.ClearRestoreJournal()
+}
+
+// ContinueRestore finishes an interrupted restore.
+func (r *Repository) ContinueRestore() (*meta.RestoreJournal, error) {
+	j, err := r.interruptedRestore()
+	if err != nil {
+		return nil, err
+	}
+	return j, r.Restore(j)
+}
+
+// AbortRestore undoes an interrupted restore: the working tree is moved back
+// to j.FromCommit and HEAD is left where it was. Every path is checked, not
+// only those in j.Done, since the last few updates may not have been
+// journaled. Local changes a forced restore discarded cannot be brought back.
+func (r *Repository) AbortRestore() (*meta.RestoreJournal, error) {
+	j, err := r.interruptedRestore()
+	if err != nil {
+		return nil, err
+	}
+	from, err := r.filesetOf(j.FromCommit)
+	if err != nil {
+		return nil, err
+	}
+	to, err := r.filesetOf(j.ToCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	// paths not reached yet are already as wanted and are left alone
+	opts := file.CheckoutOptions{
+		Force: j.Force,
+		Label: "back to " + j.FromBranch,
+	}
+	if err := r.Store.FileCtx.Checkout(to.Files, from.Files, opts); err != nil {
+		return nil, err
+	}
+	if _, err := r.Meta.SetHeadRef(j.FromBranch); err != nil {
+		return nil, err
+	}
+	return j, r.Meta.ClearRestoreJournal()
+}
+
+// ApplyTree moves the working tree from one tree to another, as revert and
+// cherry-pick do with the result of a merge onto HEAD. Paths the move would
+// touch must have no changes staged in the index nor made in the working
+// tree, and no untracked file may be in the way; otherwise a
+// *file.DirtyTreeError is returned and nothing is touched.
+func (r *Repository) ApplyTree(from, to *snapshot.Fileset, label, hint string) error {
+	staged, err := r.stagedOn(touchedPaths(from, to))
+	if err != nil {
+		return err
+	}
+	dirty := &file.DirtyTreeError{Hint: hint}
+	if len(staged) > 0 {
+		dirty.Staged = staged
+		return dirty
+	}
+
+	if err := r.Store.FileCtx.Checkout(from.Files, to.Files, file.CheckoutOptions{Label: label}); err != nil {
+		if errors.As(err, &dirty) {
+			dirty.Hint = hint
+		}
+		return err
+	}
+	return nil
+}
+
+// touchedPaths returns the slash-separated paths that differ between two
+// trees.
+func touchedPaths(from, to *snapshot.Fileset) map[string]bool {
+	changed, removed := snapshot.DiffFilesets(from, to)
+	touched := make(map[string]bool, len(changed)+len(removed))
+	for _, e := range changed {
+		touched[filepath.ToSlash(filepath.Clean(e.Path))] = true
+	}
+	for _, p := range removed {
+		touched[filepath.ToSlash(p)] = true
+	}
+	return touched
+}
+
+// stagedOn returns the touched paths whose index entry differs from HEAD,
+// sorted. Moving them would carry the staged change over to the new tree.
+func (r *Repository) stagedOn(touched map[string]bool) ([]string, error) {
+	head, err := r.GetHeadFileset()
+	if err != nil {
+		return nil, err
+	}
+	next, err := r.GetIndexFileset()
+	if err != nil {
+		return nil, err
+	}
+	changed, gone := snapshot.DiffFilesets(head, next)
+	var staged []string
+	for _, e := range changed {
+		if p := filepath.ToSlash(filepath.Clean(e.Path)); touched[p] {
+			staged = append(staged, p)
+		}
+	}
+	for _, p := range gone {
+		if p = filepath.ToSlash(p); touched[p] {
+			staged = append(staged, p)
+		}
+	}
+	sort.Strings(staged)
+	return staged, nil
+}
+
+// unstage drops the index entries of paths.
+func (r *Repository) unstage(paths []string) error {
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+	fc := r.Store.FileCtx
+	entries, err := fc.LoadIndex()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if !drop[filepath.ToSlash(filepath.Clean(e.Path))] {
+			kept = append(kept, e)
+		}
+	}
+	return fc.SaveIndexReplace(kept)
+}
+
+// InterruptedRestoreHint tells how to finish or undo an interrupted restore.
+func InterruptedRestoreHint(j *meta.RestoreJournal) string {
+	return fmt.Sprintf("a %s was interrupted with %d paths updated; run `bvc %s --continue` to finish it or `bvc %s --abort` to undo it",
+		j.Command, len(j.Done), j.Command, j.Command)
+}
+
+func (r *Repository) interruptedRestore() (*meta.RestoreJournal, error) {
+	j, err := r.Meta.GetRestoreJournal()
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, fmt.Errorf("no interrupted checkout or reset")
+	}
+	return j, nil
+}
+
+// filesetOf returns the fileset of a commit, or an empty one for "".
+func (r *Repository) filesetOf(commitID string) (*snapshot.Fileset, error) {
+	if commitID == "" {
+		return &snapshot.Fileset{}, nil
+	}
+	fs, err := r.GetCommittedFileset(commitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fileset of commit %s: %w", commitID, err)
+	}
+	return fs, nil
+}
@@ -1,8 +1,9 @@
 package block
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -87,6 +88,32 @@ const (
 	readBufSize  = 32 * 1024 // 32 KiB streaming read buffer
 )
 
+// compressedExt marks blocks stored gzip-compressed instead of raw (".bin").
+const compressedExt = ".bin.gz"
+
+// Policy controls how a file's content is split into blocks and stored.
+type Policy struct {
+	MinChunk int  // minimum block size before a content-defined split
+	MaxChunk int  // forced split size
+	Compress bool // store new blocks compressed when that saves space
+}
+
+// DefaultPolicy is used for files without attributes.
+var DefaultPolicy = Policy{MinChunk: minChunkSize, MaxChunk: maxChunkSize}
+
+// ChunkPolicy returns the default policy with blocks of at least size bytes
+// and at most four times that, mirroring the default 2/8 MiB ratio.
+func ChunkPolicy(size int64) Policy {
+	const floor, ceil = 4 * 1024, 64 * 1024 * 1024
+	if size < floor {
+		size = floor
+	}
+	if size > ceil/4 {
+		size = ceil / 4
+	}
+	return Policy{MinChunk: int(size), MaxChunk: int(size) * 4}
+}
+
 // BlockRef describes one physical block of content.
 type BlockRef struct {
 	Hash   string `json:"hash"`
@@ -122,54 +149,74 @@ func NewBlockContext(root string, fs fs.FS) *BlockContext {
 	return &BlockContext{blocksDir: root, FS: fs}
 }
 
-// Read retrieves a block by its hash.
+// BlockPath returns the path of a stored block: the raw file if present,
+// otherwise the compressed one. For missing blocks the raw path is returned.
+func (bc *BlockContext) BlockPath(hash string) string {
+	raw := filepath.Join(bc.blocksDir, hash+".bin")
+	if bc.FS.Exists(raw) {
+		return raw
+	}
+	if gz := filepath.Join(bc.blocksDir, hash+compressedExt); bc.FS.Exists(gz) {
+		return gz
+	}
+	return raw
+}
+
+// Read retrieves a block by its hash, decompressing it if needed.
 func (bc *BlockContext) Read(hash string) ([]byte, error) {
-	path := filepath.Join(bc.blocksDir, hash+".bin")
+	path := bc.BlockPath(hash)
 	data, err := bc.FS.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read block %q: %w", hash, err)
 	}
+	if strings.HasSuffix(path, compressedExt) {
+		if data, err = decompress(data); err != nil {
+			return nil, fmt.Errorf("decompress block %q: %w", hash, err)
+		}
+	}
 	return data, nil
 }
 
 // Write stores all blocks for a given file.
 func (bc *BlockContext) Write(filePath string, blocks []BlockRef) error {
-	if err := bc.FS.MkdirAll(bc.blocksDir, 0o755); err != nil {
-		return fmt.Errorf("create objects dir: %w", err)
-	}
-	workers := util.WorkerCount()
-	return util.Parallel(blocks, workers, func(b BlockRef) error {
-		return bc.writeBlockAtomic(filePath, b)
-	})
+	return bc.WriteWith(filePath, blocks, DefaultPolicy)
 }
 
-// writeBlockAtomic writes a block to disk atomically.
-func (bc *BlockContext) writeBlockAtomic(filePath string, block BlockRef) error {
-	dst := filepath.Join(bc.blocksDir, block.Hash+".bin")
-
-	// Skip if block exists
-	if fi, err := bc.FS.Stat(dst); err == nil && fi.Size() == block.Size {
-		return nil
+// WriteWith stores all blocks for a given file according to the policy.
+// Blocks already stored, compressed or not, are left as they are. The file
+// is read once, and content that no longer matches its block is refused.
+func (bc *BlockContext) WriteWith(filePath string, blocks []BlockRef, p Policy) error {
+	pl := bc.NewPipeline(util.WorkerCount(), 0)
+	err := pl.WriteBlocks(filePath, blocks, p.Compress)
+	if cerr := pl.Close(); err == nil {
+		err = cerr
 	}
+	return err
+}
 
-	if err := bc.FS.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return fmt.Errorf("ensure dir for %q: %w", dst, err)
-	}
+// Has reports whether a block is stored, compressed or not.
+func (bc *BlockContext) Has(hash string) bool {
+	return bc.FS.Exists(filepath.Join(bc.blocksDir, hash+".bin")) ||
+		bc.FS.Exists(filepath.Join(bc.blocksDir, hash+compressedExt))
+}
 
-	// Read block from source
-	src, err := bc.FS.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("open source file %q: %w", filePath, err)
+// storeBlock writes the content of a block to disk atomically, unless it is
+// stored already.
+func (bc *BlockContext) storeBlock(hash string, data []byte, compress bool) error {
+	dst := filepath.Join(bc.blocksDir, hash+".bin")
+	if fi, err := bc.FS.Stat(dst); err == nil && fi.Size() == int64(len(data)) {
+		return nil
 	}
-	defer src.Close()
-
-	if _, err := src.Seek(block.Offset, io.SeekStart); err != nil {
-		return fmt.Errorf("seek to offset %d in %q: %w", block.Offset, filePath, err)
+	if bc.FS.Exists(filepath.Join(bc.blocksDir, hash+compressedExt)) {
+		return nil
 	}
 
-	blockData := make([]byte, block.Size)
-	if _, err := io.ReadFull(src, blockData); err != nil && !errors.Is(err, io.EOF) {
-		return fmt.Errorf("read block %q: %w", block.Hash, err)
+	// keep the compressed form only when it is actually smaller
+	if compress {
+		if packed, err := compressBlock(data); err == nil && len(packed) < len(data) {
+			data = packed
+			dst = filepath.Join(bc.blocksDir, hash+compressedExt)
+		}
 	}
 
 	// Write block atomically via FS abstraction
@@ -179,7 +226,7 @@ func (bc *BlockContext) writeBlockAtomic(filePath string, block BlockRef) error
 	}
 	defer bc.FS.Remove(tmpPath)
 
-	if _, err := tmp.Write(blockData); err != nil {
+	if _, err := tmp.Write(data); err != nil {
 		tmp.Close()
 		return fmt.Errorf("write temp block: %w", err)
 	}
@@ -250,7 +297,7 @@ func (bc *BlockContext) Verify(hashes map[string]struct{}, workers int) <-chan B
 // VerifyBlock checks a single block for integrity using the selected hash.
 // Blocks are modestly sized (<= maxChunkSize), so reading into memory is fine.
 func (bc *BlockContext) VerifyBlock(hash string) (BlockStatus, error) {
-	path := filepath.Join(bc.blocksDir, hash+".bin")
+	path := bc.BlockPath(hash)
 	data, err := bc.FS.ReadFile(path)
 	if err != nil {
 		if bc.FS.IsNotExist(err) {
@@ -259,6 +306,11 @@ func (bc *BlockContext) VerifyBlock(hash string) (BlockStatus, error) {
 		// Treat read errors as damaged block.
 		return Damaged, err
 	}
+	if strings.HasSuffix(path, compressedExt) {
+		if data, err = decompress(data); err != nil {
+			return Damaged, err
+		}
+	}
 
 	h := xxh3.Hash128(data).Bytes()
 	actual := hex.EncodeToString(h[:])
@@ -273,66 +325,85 @@ func (bc *BlockContext) VerifyBlock(hash string) (BlockStatus, error) {
 // Gear-like rolling hash. The function streams the file and avoids huge
 // allocations. It returns BlockRefs in the order found.
 func (bc *BlockContext) SplitFile(path string) ([]BlockRef, error) {
+	return bc.SplitFileWith(path, DefaultPolicy)
+}
+
+// SplitFileWith splits a file using the chunk sizes of the policy.
+func (bc *BlockContext) SplitFileWith(path string, p Policy) ([]BlockRef, error) {
+	var blocks []BlockRef
+	err := bc.chunkFile(path, p, func(b BlockRef, _ []byte) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	return blocks, err
+}
+
+// chunkFile streams a file through the chunker, calling emit with each block
+// and its content. The content is only valid until emit returns.
+func (bc *BlockContext) chunkFile(path string, p Policy, emit func(BlockRef, []byte) error) error {
 	fi, err := bc.FS.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("stat file %q: %w", path, err)
+		return fmt.Errorf("stat file %q: %w", path, err)
 	}
 	if fi.Size() == 0 {
-		return nil, nil
+		return nil
 	}
 
 	f, err := bc.FS.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("open file %q: %w", path, err)
+		return fmt.Errorf("open file %q: %w", path, err)
 	}
 	defer f.Close()
 
-	var (
-		allBlocks []BlockRef
-		offset    int64
-	)
+	if err := chunk(f, p, emit); err != nil {
+		return fmt.Errorf("read file %q: %w", path, err)
+	}
+	return nil
+}
+
+// chunk divides a stream into content-defined blocks deterministically using
+// a Gear-like rolling hash, without holding more than one block in memory.
+func chunk(r io.Reader, p Policy, emit func(BlockRef, []byte) error) error {
+	if p.MinChunk <= 0 || p.MaxChunk < p.MinChunk {
+		p.MinChunk, p.MaxChunk = minChunkSize, maxChunkSize
+	}
+
+	var offset int64
 
 	// streaming read buffer
 	readBuf := make([]byte, readBufSize)
 
 	// accumulating block buffer (grow up to maxChunkSize)
-	blockBuf := make([]byte, 0, min(minChunkSize, 64*1024)) // start with small cap
+	blockBuf := make([]byte, 0, min(p.MinChunk, 64*1024)) // start with small cap
 
 	var rh uint32
-	var blockSize int
+
+	flush := func() error {
+		br := hashBlock(blockBuf, offset)
+		if err := emit(br, blockBuf); err != nil {
+			return err
+		}
+		offset += br.Size
+
+		// reset block buffer & rolling hash
+		blockBuf = blockBuf[:0]
+		rh = 0
+		return nil
+	}
 
 	for {
-		n, rerr := f.Read(readBuf)
-		if n > 0 {
-			data := readBuf[:n]
-			for i := 0; i < len(data); i++ {
-				b := data[i]
-				// append to block buffer (grow as needed, bounded by maxChunkSize)
-				blockBuf = append(blockBuf, b)
-				blockSize++
-
-				// Gear-like mixing: shift + table lookup
-				rh = (rh << 1) + gearTable[b]
-
-				// decide split
-				if shouldSplitBlock(blockSize, rh) {
-					// finalize block
-					br := hashBlock(blockBuf, offset)
-					allBlocks = append(allBlocks, br)
-					offset += br.Size
-
-					// reset block buffer & rolling hash
-					blockBuf = blockBuf[:0]
-					blockSize = 0
-					rh = 0
-				} else if blockSize >= maxChunkSize {
-					// forced max split
-					br := hashBlock(blockBuf, offset)
-					allBlocks = append(allBlocks, br)
-					offset += br.Size
-					blockBuf = blockBuf[:0]
-					blockSize = 0
-					rh = 0
+		n, rerr := r.Read(readBuf)
+		for _, b := range readBuf[:n] {
+			// append to block buffer (grow as needed, bounded by maxChunkSize)
+			blockBuf = append(blockBuf, b)
+
+			// Gear-like mixing: shift + table lookup
+			rh = (rh << 1) + gearTable[b]
+
+			// decide split, content-defined or forced at the max size
+			if shouldSplitBlock(len(blockBuf), rh, p) {
+				if err := flush(); err != nil {
+					return err
 				}
 			}
 		}
@@ -341,21 +412,40 @@ func (bc *BlockContext) SplitFile(path string) ([]BlockRef, error) {
 			if rerr == io.EOF {
 				break
 			}
-			return nil, fmt.Errorf("read file %q: %w", path, rerr)
+			return rerr
 		}
 	}
 
 	// flush remaining bytes
 	if len(blockBuf) > 0 {
-		br := hashBlock(blockBuf, offset)
-		allBlocks = append(allBlocks, br)
+		return flush()
 	}
+	return nil
+}
 
-	return allBlocks, nil
+func shouldSplitBlock(size int, rh uint32, p Policy) bool {
+	return (size >= p.MinChunk && rh%rollMod == 0) || size >= p.MaxChunk
 }
 
-func shouldSplitBlock(size int, rh uint32) bool {
-	return (size >= minChunkSize && rh%rollMod == 0) || size >= maxChunkSize
+func compressBlock(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
 }
 
 // hashBlock computes the hash of data using xxh3-128 and returns a BlockRef.
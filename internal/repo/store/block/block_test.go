@@ -183,3 +183,79 @@ func TestSplitFile(t *testing.T) {
 		t.Fatalf("sum of block sizes mismatch: %d vs %d", sum, len(data))
 	}
 }
+
+func TestWriteWithCompression(t *testing.T) {
+	bc, _ := newTestBC(t)
+
+	data := bytes.Repeat([]byte("compressible "), 4096)
+	src := filepath.Join(bc.BlocksDir(), "src.txt")
+	if err := bc.FS.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := block.DefaultPolicy
+	p.Compress = true
+	refs, err := bc.SplitFileWith(src, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.WriteWith(src, refs, p); err != nil {
+		t.Fatal(err)
+	}
+
+	path := bc.BlockPath(refs[0].Hash)
+	if filepath.Ext(path) != ".gz" {
+		t.Fatalf("expected a compressed block, got %s", path)
+	}
+	stored, _ := bc.FS.ReadFile(path)
+	if len(stored) >= int(refs[0].Size) {
+		t.Errorf("compressed block is %d bytes, raw %d", len(stored), refs[0].Size)
+	}
+
+	var out []byte
+	for _, r := range refs {
+		b, err := bc.Read(r.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b...)
+		if status, err := bc.VerifyBlock(r.Hash); status != block.OK {
+			t.Errorf("VerifyBlock(%s) = %v, %v", r.Hash, status, err)
+		}
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("read data mismatch")
+	}
+}
+
+func TestSplitFileWithPolicy(t *testing.T) {
+	bc, _ := newTestBC(t)
+
+	data := make([]byte, 256*1024)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	src := filepath.Join(bc.BlocksDir(), "data.json")
+	if err := bc.FS.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := block.ChunkPolicy(4 * 1024)
+	blocks, err := bc.SplitFileWith(src, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) < 256/16 {
+		t.Fatalf("expected small blocks, got %d", len(blocks))
+	}
+	var sum int64
+	for i, b := range blocks {
+		if b.Size > int64(p.MaxChunk) || (i < len(blocks)-1 && b.Size < int64(p.MinChunk)) {
+			t.Errorf("block %d has size %d outside [%d, %d]", i, b.Size, p.MinChunk, p.MaxChunk)
+		}
+		sum += b.Size
+	}
+	if sum != int64(len(data)) {
+		t.Fatalf("sum of block sizes mismatch: %d vs %d", sum, len(data))
+	}
+}
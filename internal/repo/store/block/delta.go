@@ -0,0 +1,52 @@
+package block
+
+// Delta describes a new version of a file by how its blocks relate to the
+// blocks of the old version.
+type Delta struct {
+	ReusedBytes int64 // content found in the old version
+	NewBytes    int64 // content not found in the old version
+
+	Unchanged int // blocks found at the same offset
+	Moved     int // blocks found at another offset
+	Changed   int // blocks not found in the old version
+	Removed   int // old blocks no longer used
+}
+
+// Compare computes the delta from the old blocks of a file to the new ones.
+func Compare(from, to []BlockRef) Delta {
+	offsets := make(map[string][]int64, len(from))
+	for _, b := range from {
+		offsets[b.Hash] = append(offsets[b.Hash], b.Offset)
+	}
+
+	var d Delta
+	used := make(map[string]bool, len(to))
+	for _, b := range to {
+		at, ok := offsets[b.Hash]
+		if !ok {
+			d.Changed++
+			d.NewBytes += b.Size
+			continue
+		}
+		used[b.Hash] = true
+		d.ReusedBytes += b.Size
+		moved := true
+		for _, o := range at {
+			if o == b.Offset {
+				moved = false
+				break
+			}
+		}
+		if moved {
+			d.Moved++
+		} else {
+			d.Unchanged++
+		}
+	}
+	for _, b := range from {
+		if !used[b.Hash] {
+			d.Removed++
+		}
+	}
+	return d
+}
@@ -0,0 +1,30 @@
+package block_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+)
+
+func TestCompare(t *testing.T) {
+	old := []block.BlockRef{
+		{Hash: "a", Offset: 0, Size: 10},
+		{Hash: "b", Offset: 10, Size: 20},
+		{Hash: "c", Offset: 30, Size: 5},
+	}
+	// a stays, a new block pushes b along, c is dropped
+	new := []block.BlockRef{
+		{Hash: "a", Offset: 0, Size: 10},
+		{Hash: "x", Offset: 10, Size: 7},
+		{Hash: "b", Offset: 17, Size: 20},
+	}
+	got := block.Compare(old, new)
+	want := block.Delta{ReusedBytes: 30, NewBytes: 7, Unchanged: 1, Moved: 1, Changed: 1, Removed: 1}
+	if got != want {
+		t.Fatalf("Compare = %+v, want %+v", got, want)
+	}
+
+	if got := block.Compare(nil, new); got.NewBytes != 37 || got.Changed != 3 {
+		t.Fatalf("Compare of a new file = %+v", got)
+	}
+}
@@ -0,0 +1,241 @@
+package block
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// defaultInFlight caps the block content handed to writers but not yet
+// written, across all files of a pipeline.
+const defaultInFlight = 64 * 1024 * 1024
+
+// ErrContentChanged is returned by WriteBlocks when a file no longer holds the
+// content its blocks were computed from.
+var ErrContentChanged = errors.New("content changed")
+
+// Pipeline stores the blocks of many files with a bounded pool of writers.
+// Files are read once: blocks are chunked and hashed as the file streams by,
+// and new ones are handed to the writers. Memory use is capped by the
+// in-flight budget plus one block per file being read.
+//
+// A Pipeline is safe for concurrent use; Close must be called once all files
+// are submitted.
+type Pipeline struct {
+	bc     *BlockContext
+	jobs   chan writeJob
+	budget *byteBudget
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[string]bool // hashes handed to writers, stored or not yet
+	err     error           // first write error
+}
+
+type writeJob struct {
+	hash     string
+	data     []byte
+	compress bool
+}
+
+// NewPipeline starts a pipeline with the given number of writers and
+// in-flight budget in bytes; a budget <= 0 uses the default.
+func (bc *BlockContext) NewPipeline(workers int, inFlight int64) *Pipeline {
+	if workers <= 0 {
+		workers = 1
+	}
+	if inFlight <= 0 {
+		inFlight = defaultInFlight
+	}
+	pl := &Pipeline{
+		bc:      bc,
+		jobs:    make(chan writeJob, workers),
+		budget:  newByteBudget(inFlight),
+		pending: make(map[string]bool),
+	}
+	for i := 0; i < workers; i++ {
+		pl.wg.Add(1)
+		go pl.writer()
+	}
+	return pl
+}
+
+func (pl *Pipeline) writer() {
+	defer pl.wg.Done()
+	for j := range pl.jobs {
+		if pl.Err() == nil {
+			if err := pl.bc.storeBlock(j.hash, j.data, j.compress); err != nil {
+				pl.fail(fmt.Errorf("store block %s: %w", j.hash, err))
+				pl.forget(j.hash)
+			}
+		}
+		pl.budget.release(int64(len(j.data)))
+	}
+}
+
+// StoreFile chunks a file with the policy and stores its new blocks, reading
+// the file once. It returns the blocks of the file as SplitFileWith would.
+func (pl *Pipeline) StoreFile(path string, p Policy) ([]BlockRef, error) {
+	if err := pl.bc.FS.MkdirAll(pl.bc.blocksDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create objects dir: %w", err)
+	}
+	var blocks []BlockRef
+	err := pl.bc.chunkFile(path, p, func(b BlockRef, data []byte) error {
+		blocks = append(blocks, b)
+		return pl.submit(b.Hash, data, p.Compress)
+	})
+	return blocks, err
+}
+
+// WriteBlocks stores the given blocks of a file, reading the file once in
+// offset order. Content that does not hash to its block is refused, so a file
+// changed since it was split never stores bytes under a wrong hash.
+func (pl *Pipeline) WriteBlocks(path string, blocks []BlockRef, compress bool) error {
+	if err := pl.bc.FS.MkdirAll(pl.bc.blocksDir, 0o755); err != nil {
+		return fmt.Errorf("create objects dir: %w", err)
+	}
+
+	var todo []BlockRef
+	for _, b := range blocks {
+		if !pl.bc.Has(b.Hash) {
+			todo = append(todo, b)
+		}
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+	sort.Slice(todo, func(i, j int) bool { return todo[i].Offset < todo[j].Offset })
+
+	src, err := pl.bc.FS.Open(path)
+	if err != nil {
+		return fmt.Errorf("open source file %q: %w", path, err)
+	}
+	defer src.Close()
+
+	var pos int64
+	for _, b := range todo {
+		if b.Offset != pos {
+			if _, err := src.Seek(b.Offset, io.SeekStart); err != nil {
+				return fmt.Errorf("seek to offset %d in %q: %w", b.Offset, path, err)
+			}
+		}
+		data := make([]byte, b.Size)
+		if _, err := io.ReadFull(src, data); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("%w: %q is shorter than its blocks", ErrContentChanged, path)
+			}
+			return fmt.Errorf("read block %q of %q: %w", b.Hash, path, err)
+		}
+		pos = b.Offset + b.Size
+		if hashBlock(data, b.Offset).Hash != b.Hash {
+			return fmt.Errorf("%w: %q at offset %d no longer matches block %s", ErrContentChanged, path, b.Offset, b.Hash)
+		}
+		if err := pl.handOff(b.Hash, data, compress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// submit hands a copy of a block's content to the writers unless the block
+// is stored or on its way.
+func (pl *Pipeline) submit(hash string, data []byte, compress bool) error {
+	if pl.claimed(hash) || pl.bc.Has(hash) {
+		return pl.Err()
+	}
+	pl.budget.acquire(int64(len(data)))
+	pl.jobs <- writeJob{hash: hash, data: bytes.Clone(data), compress: compress}
+	return pl.Err()
+}
+
+// handOff is submit for content the pipeline may keep.
+func (pl *Pipeline) handOff(hash string, data []byte, compress bool) error {
+	if pl.claimed(hash) {
+		return pl.Err()
+	}
+	pl.budget.acquire(int64(len(data)))
+	pl.jobs <- writeJob{hash: hash, data: data, compress: compress}
+	return pl.Err()
+}
+
+// claimed reports whether a block was already handed to the writers, and
+// claims it otherwise.
+func (pl *Pipeline) claimed(hash string) bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.pending[hash] {
+		return true
+	}
+	pl.pending[hash] = true
+	return false
+}
+
+func (pl *Pipeline) forget(hash string) {
+	pl.mu.Lock()
+	delete(pl.pending, hash)
+	pl.mu.Unlock()
+}
+
+func (pl *Pipeline) fail(err error) {
+	pl.mu.Lock()
+	if pl.err == nil {
+		pl.err = err
+	}
+	pl.mu.Unlock()
+}
+
+// Err returns the first error a writer hit, if any.
+func (pl *Pipeline) Err() error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.err
+}
+
+// Close waits for the writers to store everything submitted and returns the
+// first error they hit.
+func (pl *Pipeline) Close() error {
+	close(pl.jobs)
+	pl.wg.Wait()
+	return pl.Err()
+}
+
+// byteBudget is a counting semaphore over bytes.
+type byteBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	avail int64
+	max   int64
+}
+
+func newByteBudget(max int64) *byteBudget {
+	b := &byteBudget{avail: max, max: max}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n bytes are available. Requests larger than the whole
+// budget wait for all of it, so a single huge block still gets through.
+func (b *byteBudget) acquire(n int64) {
+	if n > b.max {
+		n = b.max
+	}
+	b.mu.Lock()
+	for b.avail < n {
+		b.cond.Wait()
+	}
+	b.avail -= n
+	b.mu.Unlock()
+}
+
+func (b *byteBudget) release(n int64) {
+	if n > b.max {
+		n = b.max
+	}
+	b.mu.Lock()
+	b.avail += n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
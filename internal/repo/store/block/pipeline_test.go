@@ -0,0 +1,92 @@
+package block_test
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+)
+
+func TestPipelineStoreFile(t *testing.T) {
+	bc, _ := newTestBC(t)
+
+	p := block.ChunkPolicy(4 * 1024)
+	files := map[string][]byte{}
+	for i := 0; i < 4; i++ {
+		data := make([]byte, 100*1024)
+		for j := range data {
+			data[j] = byte(j*7 + j/251 + i*(j/8192)) // files share their first block
+		}
+		files[filepath.Join(bc.BlocksDir(), fmt.Sprintf("f%d", i))] = data
+	}
+	for path, data := range files {
+		if err := bc.FS.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a budget below the block size makes reading wait on the writer
+	pl := bc.NewPipeline(1, 2*1024)
+	got := map[string][]block.BlockRef{}
+	for path := range files {
+		refs, err := pl.StoreFile(path, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[path] = refs
+	}
+	if err := pl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, data := range files {
+		want, err := bc.SplitFileWith(path, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got[path]) != fmt.Sprint(want) {
+			t.Fatalf("%s: StoreFile blocks differ from SplitFileWith", path)
+		}
+		var out []byte
+		for _, b := range want {
+			chunk, err := bc.Read(b.Hash)
+			if err != nil {
+				t.Fatalf("%s: block %s not stored: %v", path, b.Hash, err)
+			}
+			out = append(out, chunk...)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%s: stored content mismatch", path)
+		}
+	}
+}
+
+func TestPipelineWriteBlocksChanged(t *testing.T) {
+	bc, _ := newTestBC(t)
+
+	src := filepath.Join(bc.BlocksDir(), "src.bin")
+	if err := bc.FS.WriteFile(src, []byte("staged content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := bc.SplitFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.FS.WriteFile(src, []byte("edited content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pl := bc.NewPipeline(1, 0)
+	err = pl.WriteBlocks(src, refs, false)
+	if cerr := pl.Close(); cerr != nil {
+		t.Fatal(cerr)
+	}
+	if err == nil {
+		t.Fatal("expected an error for content changed since split")
+	}
+	if _, err := bc.Read(refs[0].Hash); err == nil {
+		t.Fatal("changed content was stored under the old hash")
+	}
+}
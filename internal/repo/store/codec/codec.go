@@ -0,0 +1,214 @@
+// Package codec implements the versioned binary container used for the
+// index and snapshot objects.
+//
+// File layout (little endian):
+//
+//	magic [4]byte | version u8 | reserved [3]u8 | body | checksum u64 (xxh3 of everything above)
+//
+// Bodies are written with varints and length-prefixed strings. Files that do
+// not start with the expected magic are legacy JSON and left to the caller.
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+
+	"github.com/zeebo/xxh3"
+)
+
+const headerSize = 8
+
+// ErrNotEncoded is returned by Decode for data without the expected magic.
+var ErrNotEncoded = errors.New("not a binary encoded file")
+
+// Writer builds the body of an encoded file.
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// NewWriter starts a file with the given 4-byte magic and version.
+func NewWriter(magic string, version byte) *Writer {
+	w := &Writer{}
+	w.buf.WriteString(magic)
+	w.buf.Write([]byte{version, 0, 0, 0})
+	return w
+}
+
+// Uvarint appends an unsigned varint.
+func (w *Writer) Uvarint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// Varint appends a signed varint.
+func (w *Writer) Varint(v int64) {
+	w.buf.Write(binary.AppendVarint(nil, v))
+}
+
+// String appends a length-prefixed string.
+func (w *Writer) String(s string) {
+	w.Uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// Hash appends a content hash, stored as raw bytes when it is hex encoded.
+func (w *Writer) Hash(h string) {
+	if raw, err := hex.DecodeString(h); err == nil && len(h) > 0 && hex.EncodeToString(raw) == h {
+		w.buf.WriteByte(1)
+		w.String(string(raw))
+		return
+	}
+	w.buf.WriteByte(0)
+	w.String(h)
+}
+
+// Blocks appends a block list.
+func (w *Writer) Blocks(blocks []block.BlockRef) {
+	w.Uvarint(uint64(len(blocks)))
+	for _, b := range blocks {
+		w.Hash(b.Hash)
+		w.Varint(b.Size)
+		w.Varint(b.Offset)
+	}
+}
+
+// Bytes finishes the file by appending the checksum and returns it.
+func (w *Writer) Bytes() []byte {
+	sum := xxh3.Hash(w.buf.Bytes())
+	return binary.LittleEndian.AppendUint64(w.buf.Bytes(), sum)
+}
+
+// Reader decodes the body of an encoded file. The first error is sticky
+// and returned by Err; reads after it return zero values.
+type Reader struct {
+	r   *bytes.Reader
+	err error
+}
+
+// IsEncoded reports whether data starts with the given magic.
+func IsEncoded(data []byte, magic string) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == magic
+}
+
+// Decode verifies the header and checksum of data and returns a reader
+// positioned at the start of the body.
+func Decode(data []byte, magic string, version byte) (*Reader, error) {
+	if !IsEncoded(data, magic) {
+		return nil, ErrNotEncoded
+	}
+	if len(data) < headerSize+8 {
+		return nil, fmt.Errorf("truncated %s file", magic)
+	}
+	if data[4] != version {
+		return nil, fmt.Errorf("unsupported %s version %d", magic, data[4])
+	}
+	body, sum := data[:len(data)-8], binary.LittleEndian.Uint64(data[len(data)-8:])
+	if xxh3.Hash(body) != sum {
+		return nil, fmt.Errorf("%s checksum mismatch", magic)
+	}
+	return &Reader{r: bytes.NewReader(body[headerSize:])}, nil
+}
+
+// Err returns the first decoding error, if any.
+func (r *Reader) Err() error { return r.err }
+
+// Uvarint reads an unsigned varint.
+func (r *Reader) Uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.r)
+	r.err = err
+	return v
+}
+
+// Varint reads a signed varint.
+func (r *Reader) Varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.r)
+	r.err = err
+	return v
+}
+
+// String reads a length-prefixed string.
+func (r *Reader) String() string {
+	n := r.Uvarint()
+	if r.err != nil || n == 0 {
+		return ""
+	}
+	if n > uint64(r.r.Len()) {
+		r.err = fmt.Errorf("string length %d out of range", n)
+		return ""
+	}
+	b := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, b)
+	return string(b)
+}
+
+// Hash reads a content hash written by Writer.Hash.
+func (r *Reader) Hash() string {
+	if r.err != nil {
+		return ""
+	}
+	kind, err := r.r.ReadByte()
+	if err != nil {
+		r.err = err
+		return ""
+	}
+	s := r.String()
+	if kind == 1 {
+		return hex.EncodeToString([]byte(s))
+	}
+	return s
+}
+
+// Blocks reads a block list.
+func (r *Reader) Blocks() []block.BlockRef {
+	n := r.Count()
+	if n == 0 {
+		return nil
+	}
+	blocks := make([]block.BlockRef, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		blocks = append(blocks, block.BlockRef{Hash: r.Hash(), Size: r.Varint(), Offset: r.Varint()})
+	}
+	return blocks
+}
+
+// Count reads an element count, rejecting counts larger than the remaining
+// data could possibly hold.
+func (r *Reader) Count() int {
+	n := r.Uvarint()
+	if r.err == nil && n > uint64(r.r.Len()) {
+		r.err = fmt.Errorf("count %d out of range", n)
+		return 0
+	}
+	return int(n)
+}
+
+// WriteFile atomically replaces path with data.
+func WriteFile(fsys fs.FS, path string, data []byte) error {
+	tmp, tmpPath, err := fsys.CreateTempFile(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer fsys.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return fsys.Rename(tmpPath, path)
+}
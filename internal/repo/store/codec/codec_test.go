@@ -0,0 +1,72 @@
+package codec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/codec"
+)
+
+func TestRoundTrip(t *testing.T) {
+	blocks := []block.BlockRef{
+		{Hash: "9ae5169886cc54ef550839e575c3715f", Size: 4096, Offset: 0},
+		{Hash: "not-hex", Size: 12, Offset: 4096},
+	}
+
+	w := codec.NewWriter("TEST", 1)
+	w.String("path/to/file")
+	w.String("")
+	w.Varint(-42)
+	w.Uvarint(7)
+	w.Blocks(blocks)
+	data := w.Bytes()
+
+	r, err := codec.Decode(data, "TEST", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.String(); s != "path/to/file" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if s := r.String(); s != "" {
+		t.Errorf("expected empty string, got %q", s)
+	}
+	if v := r.Varint(); v != -42 {
+		t.Errorf("unexpected varint %d", v)
+	}
+	if v := r.Uvarint(); v != 7 {
+		t.Errorf("unexpected uvarint %d", v)
+	}
+	got := r.Blocks()
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+	if len(got) != len(blocks) {
+		t.Fatalf("expected %d blocks, got %d", len(blocks), len(got))
+	}
+	for i := range blocks {
+		if got[i] != blocks[i] {
+			t.Errorf("block %d: expected %+v, got %+v", i, blocks[i], got[i])
+		}
+	}
+}
+
+func TestDecodeRejectsBadInput(t *testing.T) {
+	w := codec.NewWriter("TEST", 1)
+	w.String("payload")
+	data := w.Bytes()
+
+	if _, err := codec.Decode([]byte(`{"id":"x"}`), "TEST", 1); !errors.Is(err, codec.ErrNotEncoded) {
+		t.Errorf("expected ErrNotEncoded for JSON, got %v", err)
+	}
+	if _, err := codec.Decode(data, "TEST", 2); err == nil {
+		t.Error("expected version error")
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-10] ^= 0xff
+	if _, err := codec.Decode(corrupt, "TEST", 1); err == nil {
+		t.Error("expected checksum error")
+	}
+}
@@ -0,0 +1,221 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+)
+
+// Attribute states besides explicit values.
+const (
+	AttrSet         = "set"         // "attr"
+	AttrUnset       = "unset"       // "-attr"
+	AttrUnspecified = "unspecified" // "!attr", or no matching rule
+)
+
+// Well-known attributes.
+const (
+	AttrCompress = "compress" // store blocks compressed
+	AttrChunk    = "chunk"    // chunk=<size>: minimum block size, e.g. 64k or 4M
+	AttrLockable = "lockable" // binary asset edited by one person at a time
+	AttrMerge    = "merge"    // merge=ours|theirs: how conflicting changes are resolved
+)
+
+// Attributes maps working tree paths to attributes from the .bvc-attributes
+// file in the working tree root. Each line is a pattern followed by
+// attribute assignments; patterns follow .bvc-ignore syntax without "!"
+// negation, and later lines override earlier ones per attribute.
+//
+//	*.txt       compress
+//	*.mp4       -compress
+//	*.json      chunk=64k
+//	*.psd       lockable
+//	generated/  merge=ours
+type Attributes struct {
+	rules []attrRule
+}
+
+type attrRule struct {
+	Rule
+	assigns []AttrAssign
+}
+
+// AttrAssign is one attribute assignment of a rule.
+type AttrAssign struct {
+	Name  string
+	Value string // AttrSet, AttrUnset, AttrUnspecified or a value
+}
+
+// AttrMatch is the assignment that decided an attribute for a path.
+type AttrMatch struct {
+	AttrAssign
+	Source  string
+	Line    int
+	Pattern string
+}
+
+// AttrValues holds the attributes of one path, by name.
+type AttrValues map[string]string
+
+// LoadAttributes reads .bvc-attributes from the working tree root.
+// A missing file yields no attributes.
+func LoadAttributes(root string, fsys fs.FS) *Attributes {
+	a := &Attributes{}
+	if fsys == nil {
+		return a
+	}
+	source := filepath.Join(root, config.AttributesFile)
+	f, err := fsys.Open(source)
+	if err != nil {
+		return a
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if r, ok := parseAttrLine(sc.Text(), source, n); ok {
+			a.rules = append(a.rules, r)
+		}
+	}
+	return a
+}
+
+// parseAttrLine parses "<pattern> <attr>...". Negated patterns are not allowed.
+func parseAttrLine(line, source string, n int) (attrRule, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+		return attrRule{}, false
+	}
+	rule, ok := parseRule(fields[0], "", source, n)
+	if !ok {
+		return attrRule{}, false
+	}
+	r := attrRule{Rule: rule}
+	for _, f := range fields[1:] {
+		switch {
+		case strings.HasPrefix(f, "-"):
+			r.assigns = append(r.assigns, AttrAssign{Name: f[1:], Value: AttrUnset})
+		case strings.HasPrefix(f, "!"):
+			r.assigns = append(r.assigns, AttrAssign{Name: f[1:], Value: AttrUnspecified})
+		case strings.Contains(f, "="):
+			name, value, _ := strings.Cut(f, "=")
+			r.assigns = append(r.assigns, AttrAssign{Name: name, Value: value})
+		default:
+			r.assigns = append(r.assigns, AttrAssign{Name: f, Value: AttrSet})
+		}
+	}
+	return r, true
+}
+
+// Explain returns, for each attribute any rule assigns to the path, the
+// assignment that decides it, in order of first appearance.
+func (a *Attributes) Explain(rel string) []AttrMatch {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	var order []string
+	decided := map[string]AttrMatch{}
+	for _, r := range a.rules {
+		if !r.matchesPath(rel) {
+			continue
+		}
+		for _, as := range r.assigns {
+			if _, seen := decided[as.Name]; !seen {
+				order = append(order, as.Name)
+			}
+			decided[as.Name] = AttrMatch{AttrAssign: as, Source: r.Source, Line: r.Line, Pattern: r.Pattern}
+		}
+	}
+	out := make([]AttrMatch, 0, len(order))
+	for _, name := range order {
+		out = append(out, decided[name])
+	}
+	return out
+}
+
+// For returns the attributes of a path. Unspecified attributes are omitted.
+func (a *Attributes) For(rel string) AttrValues {
+	values := AttrValues{}
+	for _, m := range a.Explain(rel) {
+		if m.Value != AttrUnspecified {
+			values[m.Name] = m.Value
+		}
+	}
+	return values
+}
+
+// MergeStrategy returns the merge strategy of a path; see AttrValues.MergeStrategy.
+func (a *Attributes) MergeStrategy(rel string) string {
+	return a.For(rel).MergeStrategy()
+}
+
+// Get returns the state or value of an attribute.
+func (v AttrValues) Get(name string) string {
+	if s, ok := v[name]; ok {
+		return s
+	}
+	return AttrUnspecified
+}
+
+// IsSet reports whether an attribute is set ("attr", not "-attr" or a value).
+func (v AttrValues) IsSet(name string) bool { return v[name] == AttrSet }
+
+// Lockable reports whether the path is a lockable asset.
+func (v AttrValues) Lockable() bool { return v.IsSet(AttrLockable) }
+
+// MergeStrategy returns "ours" or "theirs" if conflicting changes to the
+// path resolve to that side, or "" for the default conflict handling.
+func (v AttrValues) MergeStrategy() string {
+	switch s := v[AttrMerge]; s {
+	case "ours", "theirs":
+		return s
+	}
+	return ""
+}
+
+// Policy returns the block policy for the path.
+func (v AttrValues) Policy() block.Policy {
+	p := block.DefaultPolicy
+	if size, err := ParseSize(v[AttrChunk]); err == nil && size > 0 {
+		p = block.ChunkPolicy(size)
+	}
+	p.Compress = v.IsSet(AttrCompress)
+	return p
+}
+
+// ParseSize parses a byte size such as 4096, 64k, 64KiB or 2M.
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	num := strings.TrimRight(strings.ToLower(s), "ib")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(num, "k"):
+		mult = 1 << 10
+	case strings.HasSuffix(num, "m"):
+		mult = 1 << 20
+	case strings.HasSuffix(num, "g"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// Attributes returns the attributes of the working tree, loaded on first use.
+func (fc *FileContext) Attributes() *Attributes {
+	fc.attrsOnce.Do(func() {
+		fc.attrs = LoadAttributes(fc.WorkingTreeDir, fc.FS)
+	})
+	return fc.attrs
+}
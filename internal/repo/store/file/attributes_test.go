@@ -0,0 +1,88 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+)
+
+func TestAttributes_For(t *testing.T) {
+	mem := fs.NewMemoryFS()
+	mem.MkdirAll("/wt", 0o755)
+	mem.WriteFile("/wt/.bvc-attributes", []byte(`# storage
+*            compress
+*.mp4        -compress
+*.json       chunk=64k
+*.psd        lockable
+generated/   merge=ours
+!*.txt       lockable
+generated/keep.json  !merge
+`), 0o644)
+
+	a := LoadAttributes("/wt", mem)
+	cases := []struct {
+		path, attr, want string
+	}{
+		{"a.bin", AttrCompress, AttrSet},
+		{"movies/b.mp4", AttrCompress, AttrUnset},
+		{"data/c.json", AttrChunk, "64k"},
+		{"data/c.json", AttrCompress, AttrSet},
+		{"art/d.psd", AttrLockable, AttrSet},
+		{"e.txt", AttrLockable, AttrUnspecified},
+		{"generated/sub/f.go", AttrMerge, "ours"},
+		{"generated/keep.json", AttrMerge, AttrUnspecified},
+		{"src/generated.go", AttrMerge, AttrUnspecified},
+	}
+	for _, tt := range cases {
+		if got := a.For(tt.path).Get(tt.attr); got != tt.want {
+			t.Errorf("%s: %s = %q, want %q", tt.path, tt.attr, got, tt.want)
+		}
+	}
+
+	if !a.For("x.psd").Lockable() || a.For("x.txt").Lockable() {
+		t.Errorf("unexpected lockable state")
+	}
+	if got := a.MergeStrategy("generated/x"); got != "ours" {
+		t.Errorf("MergeStrategy = %q, want ours", got)
+	}
+
+	m := a.Explain("movies/b.mp4")
+	if len(m) != 1 || m[0].Name != AttrCompress || m[0].Line != 3 || m[0].Pattern != "*.mp4" {
+		t.Errorf("unexpected explanation %+v", m)
+	}
+}
+
+func TestAttributes_Policy(t *testing.T) {
+	a := AttrValues{AttrCompress: AttrSet, AttrChunk: "64k"}
+	p := a.Policy()
+	if !p.Compress || p.MinChunk != 64<<10 || p.MaxChunk != 256<<10 {
+		t.Errorf("unexpected policy %+v", p)
+	}
+	if p := (AttrValues{AttrChunk: "bogus"}).Policy(); p != block.DefaultPolicy {
+		t.Errorf("invalid chunk size should keep the default policy, got %+v", p)
+	}
+	if p := (AttrValues{AttrChunk: "1"}).Policy(); p.MinChunk != 4<<10 {
+		t.Errorf("chunk size should be clamped, got %+v", p)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"4096":  4096,
+		"64k":   64 << 10,
+		"64KiB": 64 << 10,
+		"2M":    2 << 20,
+		"1g":    1 << 30,
+	}
+	for s, want := range cases {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "k", "-1", "12x"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) should fail", s)
+		}
+	}
+}
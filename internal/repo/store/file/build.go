@@ -2,8 +2,10 @@ package file
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/keshon/bvc/internal/progress"
 	"github.com/keshon/bvc/internal/util"
@@ -11,6 +13,12 @@ import (
 
 // BuildEntry splits a file into block references (content-defined).
 func (fc *FileContext) BuildEntry(path string) (Entry, error) {
+	return fc.buildEntry(path, nil)
+}
+
+// buildEntry builds an entry, reusing the cached blocks of files whose stat
+// data is unchanged. A nil cache always re-chunks.
+func (fc *FileContext) buildEntry(path string, cache *StatCache) (Entry, error) {
 	if fc.BlockCtx == nil {
 		return Entry{}, fmt.Errorf("no BlockContext attached")
 	}
@@ -25,21 +33,63 @@ func (fc *FileContext) BuildEntry(path string) (Entry, error) {
 	}
 	relPath = filepath.ToSlash(relPath)
 
-	// Split based on FS path, not OS absolute path
-	blocks, err := fc.BlockCtx.SplitFile(cleanPath)
+	info, err := fc.FS.Lstat(cleanPath)
 	if err != nil {
-		return Entry{}, fmt.Errorf("split %q: %w", relPath, err)
+		return Entry{}, fmt.Errorf("stat %q: %w", relPath, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 && SymlinksMode() == SymlinksFollow {
+		// record what the link points to; dangling links stay links
+		if target, err := fc.FS.Stat(cleanPath); err == nil {
+			info = target
+		}
+	}
+	entry := Entry{Path: relPath, Mode: info.Mode(), ModTime: info.ModTime().UnixNano()}
+
+	switch {
+	case info.IsDir():
+		// empty directories are recorded without content
+		return entry, nil
+
+	case info.Mode()&specialMode != 0:
+		// FIFOs, sockets and devices have no content; reading one may block
+		return entry, nil
+
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := fc.FS.Readlink(cleanPath)
+		if err != nil {
+			return Entry{}, fmt.Errorf("readlink %q: %w", relPath, err)
+		}
+		entry.Link = filepath.ToSlash(target)
+		return entry, nil
+	}
+
+	policy := fc.Attributes().For(relPath).Policy()
+	blocks, ok := cache.lookup(relPath, info, policy)
+	if !ok {
+		// Split based on FS path, not OS absolute path
+		hashedAt := time.Now()
+		if blocks, err = fc.BlockCtx.SplitFileWith(cleanPath, policy); err != nil {
+			return Entry{}, fmt.Errorf("split %q: %w", relPath, err)
+		}
+		cache.store(relPath, info, policy, blocks, hashedAt)
+	}
+	entry.Blocks = blocks
+	for _, b := range blocks {
+		entry.Size += b.Size
 	}
 
-	return Entry{Path: relPath, Blocks: blocks}, nil
+	return entry, nil
 }
 
-// BuildEntries builds entries from a list of paths.
+// BuildEntries builds entries from a list of paths. Files whose size, mtime,
+// inode, mode and chunk policy match the stat cache are not read again.
 func (fc *FileContext) BuildEntries(paths []string, silent bool) ([]Entry, error) {
 	if len(paths) == 0 {
 		return nil, nil
 	}
 
+	cache := fc.LoadStatCache()
+
 	var bar *progress.ProgressTracker
 	if !silent {
 		bar = progress.NewProgress(len(paths), "Building entries ")
@@ -50,7 +100,7 @@ func (fc *FileContext) BuildEntries(paths []string, silent bool) ([]Entry, error
 	entries := make([]Entry, 0, len(paths))
 
 	err := util.Parallel(paths, util.WorkerCount(), func(p string) error {
-		entry, err := fc.BuildEntry(p)
+		entry, err := fc.buildEntry(p, cache)
 		if err != nil {
 			return err
 		}
@@ -69,15 +119,41 @@ func (fc *FileContext) BuildEntries(paths []string, silent bool) ([]Entry, error
 		return entries, err
 	}
 
+	if err := fc.SaveStatCache(cache); err != nil {
+		return entries, fmt.Errorf("save stat cache: %w", err)
+	}
 	return entries, nil
 }
 
-// Write stores all blocks of an entry into store.
+// ListEntries describes paths from their stat data alone, without reading
+// content. Used for files that are listed but never stored, such as ignored ones.
+func (fc *FileContext) ListEntries(paths []string) []Entry {
+	entries := make([]Entry, 0, len(paths))
+	for _, p := range paths {
+		cleanPath := filepath.ToSlash(filepath.Clean(p))
+		relPath, err := filepath.Rel(fc.WorkingTreeDir, cleanPath)
+		if err != nil {
+			relPath = cleanPath
+		}
+		entry := Entry{Path: filepath.ToSlash(relPath)}
+		if info, err := fc.FS.Lstat(cleanPath); err == nil {
+			entry.Mode, entry.ModTime = info.Mode(), info.ModTime().UnixNano()
+			if info.Mode().IsRegular() {
+				entry.Size = info.Size()
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Write stores all blocks of an entry into store, compressed if the entry's
+// attributes ask for it.
 func (fc *FileContext) Write(e Entry) error {
 	if fc.BlockCtx == nil {
 		return fmt.Errorf("no BlockContext attached")
 	}
-	return fc.BlockCtx.Write(e.Path, e.Blocks)
+	return fc.BlockCtx.WriteWith(e.Path, e.Blocks, fc.Attributes().For(e.Path).Policy())
 }
 
 // Exists checks whether a given path exists in the working tree.
@@ -0,0 +1,267 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/progress"
+)
+
+// CheckoutOptions controls how Checkout updates the working tree.
+type CheckoutOptions struct {
+	// Force discards local changes instead of refusing to overwrite them.
+	Force bool
+	// Reset makes every tracked path match the target, as reset --hard does.
+	// Otherwise only paths that differ between the current and target trees
+	// are touched, and local changes to other files are kept.
+	Reset bool
+	// Label describes the target in progress output.
+	Label string
+	// Skip, if set, leaves the paths it returns true for alone, e.g. those
+	// an interrupted checkout already updated.
+	Skip func(path string) bool
+	// Done, if set, is called with each path after it has been updated.
+	Done func(path string) error
+}
+
+// DirtyTreeError lists the paths whose uncommitted changes a checkout would
+// overwrite.
+type DirtyTreeError struct {
+	Staged    []string // files with changes staged in the index
+	Modified  []string // tracked files changed since the current commit
+	Untracked []string // untracked files in the way of target files
+	Hint      string   // how to get past it; a default mentioning --force if empty
+}
+
+func (e *DirtyTreeError) Error() string {
+	var b strings.Builder
+	if len(e.Staged) > 0 {
+		b.WriteString("your staged changes to the following files would be overwritten:\n\t")
+		b.WriteString(strings.Join(e.Staged, "\n\t"))
+		b.WriteString("\n")
+	}
+	if len(e.Modified) > 0 {
+		b.WriteString("your local changes to the following files would be overwritten:\n\t")
+		b.WriteString(strings.Join(e.Modified, "\n\t"))
+		b.WriteString("\n")
+	}
+	if len(e.Untracked) > 0 {
+		b.WriteString("the following untracked files would be overwritten:\n\t")
+		b.WriteString(strings.Join(e.Untracked, "\n\t"))
+		b.WriteString("\n")
+	}
+	if e.Hint != "" {
+		b.WriteString(e.Hint)
+	} else {
+		b.WriteString("commit or stash your changes, or use --force to discard them")
+	}
+	return b.String()
+}
+
+// Checkout moves the working tree from the current tree to the target tree.
+// Only files whose content must change are written, and files missing from
+// the target are deleted. Before anything is touched, files with uncommitted
+// changes that would be lost are collected; unless opts.Force is set they
+// are reported as a *DirtyTreeError and the working tree is left as it is.
+// Paths excluded by sparse checkout are never written.
+func (fc *FileContext) Checkout(current, target []Entry, opts CheckoutOptions) error {
+	if fc.BlockCtx == nil {
+		return fmt.Errorf("no BlockContext attached")
+	}
+	if err := fc.recoverPatch(); err != nil {
+		return err
+	}
+	sparse, err := fc.LoadSparse()
+	if err != nil {
+		return err
+	}
+
+	cur := entryMap(current)
+	tgt := entryMap(target)
+
+	// paths the checkout may touch
+	var candidates []string
+	for p, t := range tgt {
+		if opts.Skip != nil && opts.Skip(p) {
+			continue
+		}
+		if c, ok := cur[p]; opts.Reset || !ok || !c.Equal(&t) {
+			candidates = append(candidates, p)
+		}
+	}
+	for p := range cur {
+		if opts.Skip != nil && opts.Skip(p) {
+			continue
+		}
+		if _, ok := tgt[p]; !ok {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Strings(candidates)
+
+	work, err := fc.workingEntries(candidates)
+	if err != nil {
+		return err
+	}
+
+	var writes []Entry
+	var removals []string
+	dirty := &DirtyTreeError{}
+	exe := filepath.Base(os.Args[0])
+	for _, p := range candidates {
+		t, inTarget := tgt[p]
+		c, inCurrent := cur[p]
+		w, inWork := work[p]
+
+		if inTarget && (!sparse.Includes(p) || filepath.Base(p) == exe) {
+			continue
+		}
+		if inWork && inTarget && w.Equal(&t) {
+			continue // already as wanted
+		}
+		if !inWork && !inTarget {
+			continue // already gone
+		}
+
+		switch {
+		case inWork && !inCurrent:
+			dirty.Untracked = append(dirty.Untracked, p)
+		case inWork && !w.Equal(&c):
+			dirty.Modified = append(dirty.Modified, p)
+		}
+
+		if inTarget {
+			writes = append(writes, t)
+		} else {
+			removals = append(removals, p)
+		}
+	}
+
+	// files where the target needs directories, other than tracked ones
+	// the checkout removes anyway
+	var blockers []string
+	for _, e := range writes {
+		q := fc.fileInPath(e.Path)
+		if q == "" || slices.Contains(blockers, q) {
+			continue
+		}
+		if _, inTarget := tgt[q]; !inTarget {
+			if _, inCurrent := cur[q]; inCurrent && slices.Contains(removals, q) {
+				continue
+			}
+		}
+		blockers = append(blockers, q)
+		dirty.Untracked = append(dirty.Untracked, q)
+	}
+
+	if !opts.Force && (len(dirty.Modified) > 0 || len(dirty.Untracked) > 0) {
+		return dirty
+	}
+
+	bar := progress.NewProgress(len(writes)+len(removals), fmt.Sprintf("Checking out %s", opts.Label))
+	defer bar.Finish()
+
+	done := func(p string) error {
+		bar.Increment()
+		if opts.Done != nil {
+			return opts.Done(p)
+		}
+		return nil
+	}
+	for _, p := range append(removals, blockers...) {
+		abs := filepath.Join(fc.WorkingTreeDir, p)
+		if err := fc.checkParents(abs); err != nil {
+			return err
+		}
+		if err := fc.FS.Remove(abs); err != nil && !fc.FS.IsNotExist(err) {
+			if c := cur[p]; !c.IsDir() {
+				return fmt.Errorf("failed to remove %s: %w", p, err)
+			}
+			// a recorded empty directory that gained files is kept
+		}
+		fc.removeEmptyParents(abs)
+		if err := done(p); err != nil {
+			return err
+		}
+	}
+	for _, e := range writes {
+		abs := filepath.Join(fc.WorkingTreeDir, e.Path)
+		if err := fc.checkParents(abs); err != nil {
+			return err
+		}
+		// an empty directory may be in the way of a file, or the reverse
+		if info, err := fc.FS.Lstat(abs); err == nil && info.IsDir() != e.IsDir() {
+			if err := fc.FS.Remove(abs); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", e.Path, err)
+			}
+		}
+		at := e
+		at.Path = abs
+		// files that changed in a few blocks are patched in place
+		if w, ok := work[e.Path]; ok && w.Mode.IsRegular() && e.Mode.IsRegular() {
+			patched, err := fc.patchFile(at, w.Blocks)
+			if err != nil {
+				return err
+			}
+			if patched {
+				if err := done(e.Path); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := fc.restoreFile(at); err != nil {
+			return fmt.Errorf("restoring file %s: %w", e.Path, err)
+		}
+		if err := done(e.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileInPath returns the first parent directory of a path that exists in the
+// working tree as something other than a directory, or "".
+func (fc *FileContext) fileInPath(rel string) string {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		p := strings.Join(parts[:i], "/")
+		info, err := fc.FS.Lstat(filepath.Join(fc.WorkingTreeDir, p))
+		if err != nil {
+			return ""
+		}
+		if !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// workingEntries builds entries for the paths that exist in the working tree.
+func (fc *FileContext) workingEntries(paths []string) (map[string]Entry, error) {
+	var present []string
+	for _, p := range paths {
+		abs := filepath.Join(fc.WorkingTreeDir, p)
+		if _, err := fc.FS.Lstat(abs); err == nil {
+			present = append(present, abs)
+		}
+	}
+	entries, err := fc.BuildEntries(present, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan working tree: %w", err)
+	}
+	return entryMap(entries), nil
+}
+
+// entryMap indexes entries by their cleaned, slash-separated path.
+func entryMap(entries []Entry) map[string]Entry {
+	m := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		m[filepath.ToSlash(filepath.Clean(e.Path))] = e
+	}
+	return m
+}
@@ -0,0 +1,230 @@
+package file_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// storeEntry writes content to the working tree path, stores its blocks and
+// returns the entry.
+func storeEntry(t *testing.T, fc *file.FileContext, root, rel, content string) file.Entry {
+	t.Helper()
+	abs := filepath.Join(root, rel)
+	if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.FS.WriteFile(abs, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fc.BuildEntry(abs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.BlockCtx.Write(abs, e.Blocks); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func readFile(fc *file.FileContext, root, rel string) string {
+	data, err := fc.FS.ReadFile(filepath.Join(root, rel))
+	if err != nil {
+		return "<missing>"
+	}
+	return string(data)
+}
+
+func TestCheckout(t *testing.T) {
+	fc, root := newTestFC(t)
+
+	// target tree: changed.txt and added.txt differ from the current tree
+	target := []file.Entry{
+		storeEntry(t, fc, root, "same.txt", "same"),
+		storeEntry(t, fc, root, "changed.txt", "new"),
+		storeEntry(t, fc, root, "dir/added.txt", "added"),
+	}
+	current := []file.Entry{
+		target[0],
+		storeEntry(t, fc, root, "changed.txt", "old"),
+		storeEntry(t, fc, root, "removed.txt", "removed"),
+	}
+	if err := fc.FS.Remove(filepath.Join(root, "dir/added.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.FS.Remove(filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a local change to a file both trees share is kept
+	if err := fc.FS.WriteFile(filepath.Join(root, "same.txt"), []byte("local"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fc.Checkout(current, target, file.CheckoutOptions{Label: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"same.txt":      "local",
+		"changed.txt":   "new",
+		"dir/added.txt": "added",
+		"removed.txt":   "<missing>",
+	}
+	for p, content := range want {
+		if got := readFile(fc, root, p); got != content {
+			t.Errorf("%s = %q, want %q", p, got, content)
+		}
+	}
+
+	// back again, with a local edit in the way
+	if err := fc.FS.WriteFile(filepath.Join(root, "changed.txt"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.FS.WriteFile(filepath.Join(root, "removed.txt"), []byte("untracked"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := fc.Checkout(target, current, file.CheckoutOptions{Label: "test"})
+	var dirty *file.DirtyTreeError
+	if !errors.As(err, &dirty) {
+		t.Fatalf("expected a DirtyTreeError, got %v", err)
+	}
+	if !reflect.DeepEqual(dirty.Modified, []string{"changed.txt"}) || !reflect.DeepEqual(dirty.Untracked, []string{"removed.txt"}) {
+		t.Errorf("unexpected dirty paths %+v", dirty)
+	}
+	if got := readFile(fc, root, "dir/added.txt"); got != "added" {
+		t.Errorf("a refused checkout must not touch the working tree")
+	}
+
+	if err := fc.Checkout(target, current, file.CheckoutOptions{Force: true, Label: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{
+		"same.txt":      "local",
+		"changed.txt":   "old",
+		"dir/added.txt": "<missing>",
+		"removed.txt":   "removed",
+	}
+	for p, content := range want {
+		if got := readFile(fc, root, p); got != content {
+			t.Errorf("%s = %q, want %q", p, got, content)
+		}
+	}
+	if fc.Exists(filepath.Join(root, "dir")) {
+		t.Errorf("emptied directory should be removed")
+	}
+
+	// reset restores every tracked file, refusing to drop local changes
+	if err := fc.Checkout(current, current, file.CheckoutOptions{Reset: true, Label: "test"}); !errors.As(err, &dirty) {
+		t.Fatalf("expected a DirtyTreeError, got %v", err)
+	}
+	if err := fc.Checkout(current, current, file.CheckoutOptions{Reset: true, Force: true, Label: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(fc, root, "same.txt"); got != "same" {
+		t.Errorf("same.txt = %q, want %q", got, "same")
+	}
+}
+
+func TestCheckout_UntrackedFileInPath(t *testing.T) {
+	fc, root := newTestFC(t)
+
+	target := []file.Entry{storeEntry(t, fc, root, "d/c.txt", "c")}
+	for _, p := range []string{"d/c.txt", "d"} {
+		if err := fc.FS.Remove(filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fc.FS.WriteFile(filepath.Join(root, "d"), []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var dirty *file.DirtyTreeError
+	if err := fc.Checkout(nil, target, file.CheckoutOptions{Label: "test"}); !errors.As(err, &dirty) || !reflect.DeepEqual(dirty.Untracked, []string{"d"}) {
+		t.Fatalf("expected d to be reported, got %v", err)
+	}
+	if err := fc.Checkout(nil, target, file.CheckoutOptions{Force: true, Label: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(fc, root, "d/c.txt"); got != "c" {
+		t.Errorf("d/c.txt = %q, want c", got)
+	}
+}
+
+func TestCheckout_FileBelowSymlink(t *testing.T) {
+	fc, root := newTestFC(t)
+
+	outside := filepath.Join(filepath.Dir(root), "outside")
+	if err := fc.FS.MkdirAll(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	under := storeEntry(t, fc, root, "dir/x", "x")
+	for _, p := range []string{"dir/x", "dir"} {
+		if err := fc.FS.Remove(filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := file.Entry{Path: "dir", Mode: os.ModeSymlink | 0o777, Link: filepath.ToSlash(outside)}
+
+	err := fc.Checkout(nil, []file.Entry{link, under}, file.CheckoutOptions{Force: true, Label: "test"})
+	if err == nil || !strings.Contains(err.Error(), "symbolic link") {
+		t.Fatalf("expected a write below a symlink to be refused, got %v", err)
+	}
+	if fc.FS.Exists(filepath.Join(outside, "x")) {
+		t.Fatal("dir/x was written through the link")
+	}
+}
+
+func TestCheckout_Resume(t *testing.T) {
+	fc, root := newTestFC(t)
+
+	target := []file.Entry{
+		storeEntry(t, fc, root, "a.txt", "new a"),
+		storeEntry(t, fc, root, "b.txt", "new b"),
+		storeEntry(t, fc, root, "c.txt", "new c"),
+	}
+	current := []file.Entry{
+		storeEntry(t, fc, root, "a.txt", "old a"),
+		storeEntry(t, fc, root, "b.txt", "old b"),
+		storeEntry(t, fc, root, "c.txt", "old c"),
+	}
+
+	// interrupt after the first path
+	interrupted := errors.New("interrupted")
+	var done []string
+	opts := file.CheckoutOptions{Done: func(p string) error {
+		done = append(done, p)
+		return interrupted
+	}}
+	if err := fc.Checkout(current, target, opts); !errors.Is(err, interrupted) {
+		t.Fatalf("expected interruption, got %v", err)
+	}
+	if !reflect.DeepEqual(done, []string{"a.txt"}) || readFile(fc, root, "b.txt") != "old b" {
+		t.Fatalf("unexpected progress: done=%v b=%q", done, readFile(fc, root, "b.txt"))
+	}
+
+	// paths already done are skipped on the next run
+	if err := fc.FS.WriteFile(filepath.Join(root, "a.txt"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts = file.CheckoutOptions{
+		Skip: func(p string) bool { return p == "a.txt" },
+		Done: func(p string) error { done = append(done, p); return nil },
+	}
+	if err := fc.Checkout(current, target, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(done, []string{"a.txt", "b.txt", "c.txt"}) {
+		t.Errorf("done = %v", done)
+	}
+	want := map[string]string{"a.txt": "edited", "b.txt": "new b", "c.txt": "new c"}
+	for p, content := range want {
+		if got := readFile(fc, root, p); got != content {
+			t.Errorf("%s = %q, want %q", p, got, content)
+		}
+	}
+}
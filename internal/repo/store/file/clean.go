@@ -0,0 +1,283 @@
+package file
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/config"
+)
+
+// Untracked is a working tree path that no tree tracks, as found by
+// ListUntracked.
+type Untracked struct {
+	Path    string // repository-relative, slash-separated
+	Dir     bool   // a whole directory
+	Ignored bool   // matched by ignore rules; false for directories taken whole
+	Size    int64  // bytes, everything below for directories
+}
+
+// CleanOptions selects what ListUntracked reports.
+type CleanOptions struct {
+	Dirs    bool              // include untracked directories
+	Ignored bool              // include ignored files as well
+	Match   func(string) bool // limits the paths; nil matches everything
+}
+
+// ListUntracked classifies the working tree the way ScanAllRepository does
+// and returns the paths not in tracked, sorted. Files in directories that
+// hold no tracked path are reported only with Dirs, as their topmost
+// untracked directory when all of it can go. The repository directory, its
+// pointer file and repositories nested in the working tree, with everything
+// below them, are never reported.
+func (fc *FileContext) ListUntracked(tracked map[string]bool, opts CleanOptions) ([]Untracked, error) {
+	files, staged, ignored, err := fc.ScanAllRepository()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan working tree: %w", err)
+	}
+	match := opts.Match
+	if match == nil {
+		match = func(string) bool { return true }
+	}
+
+	trackedDirs := map[string]bool{".": true}
+	for p := range tracked {
+		for d := path.Dir(p); d != "." && !trackedDirs[d]; d = path.Dir(d) {
+			trackedDirs[d] = true
+		}
+	}
+	ignoredRel := make([]string, 0, len(ignored))
+	for _, p := range ignored {
+		ignoredRel = append(ignoredRel, fc.relSlash(p))
+	}
+
+	var out []Untracked
+	seen := map[string]bool{}
+	repos := map[string]bool{} // directories known to be, or not to be, nested repositories
+	add := func(rel string, isIgnored bool) {
+		if fc.protected(rel, repos) || !match(rel) && !opts.Dirs {
+			return
+		}
+		if top := topUntrackedDir(rel, trackedDirs); top != "" {
+			if !opts.Dirs {
+				return
+			}
+			if match(top) && fc.canRemoveDir(top, ignoredRel, opts.Ignored, repos) {
+				if !seen[top] {
+					seen[top] = true
+					out = append(out, Untracked{Path: top, Dir: true})
+				}
+				return
+			}
+		}
+		if !match(rel) || seen[rel] {
+			return
+		}
+		isDir := fc.FS.IsDir(filepath.Join(fc.WorkingTreeDir, rel))
+		if isDir && !opts.Dirs {
+			return
+		}
+		seen[rel] = true
+		out = append(out, Untracked{Path: rel, Dir: isDir, Ignored: isIgnored})
+	}
+
+	for _, p := range append(files, staged...) {
+		if rel := fc.relSlash(p); !tracked[rel] {
+			add(rel, false)
+		}
+	}
+	if opts.Ignored {
+		for _, rel := range ignoredRel {
+			add(rel, true)
+		}
+	}
+
+	// a directory taken whole covers whatever was listed below it first
+	kept := out[:0]
+	for _, u := range out {
+		if !coveredBy(u.Path, seen) {
+			kept = append(kept, u)
+		}
+	}
+	out = kept
+	for i := range out {
+		out[i].Size = fc.treeSize(filepath.Join(fc.WorkingTreeDir, out[i].Path))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// RemoveUntracked deletes the paths found by ListUntracked, directories with
+// everything below them. With dirs, directories left empty are removed too.
+func (fc *FileContext) RemoveUntracked(items []Untracked, dirs bool) error {
+	for _, u := range items {
+		abs := filepath.Join(fc.WorkingTreeDir, u.Path)
+		if err := fc.removeTree(abs); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", u.Path, err)
+		}
+		if dirs {
+			fc.removeEmptyParents(abs)
+		}
+	}
+	return nil
+}
+
+// relSlash turns a scanned path into a repository-relative one.
+func (fc *FileContext) relSlash(p string) string {
+	rel, err := filepath.Rel(fc.WorkingTreeDir, p)
+	if err != nil {
+		rel = p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// protected reports whether a path belongs to the repository itself or to
+// a repository nested in the working tree.
+func (fc *FileContext) protected(rel string, repos map[string]bool) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if part == config.RepoPointerFile || part == config.RepoDir {
+			return true
+		}
+	}
+	for d := rel; d != "."; d = path.Dir(d) {
+		if fc.isRepoRoot(d, repos) {
+			return true
+		}
+	}
+	repoDir, err := filepath.Abs(fc.RepoDir)
+	if err != nil {
+		return true
+	}
+	abs, err := filepath.Abs(filepath.Join(fc.WorkingTreeDir, rel))
+	return err != nil || within(abs, repoDir)
+}
+
+// isRepoRoot reports whether a directory holds a repository of its own: a
+// repository directory or a pointer file to one.
+func (fc *FileContext) isRepoRoot(dir string, repos map[string]bool) bool {
+	if known, ok := repos[dir]; ok {
+		return known
+	}
+	abs := filepath.Join(fc.WorkingTreeDir, dir)
+	found := fc.FS.Exists(filepath.Join(abs, config.RepoDir)) || fc.FS.Exists(filepath.Join(abs, config.RepoPointerFile))
+	repos[dir] = found
+	return found
+}
+
+// containsRepo reports whether a repository is nested anywhere below dir.
+// Links are not followed.
+func (fc *FileContext) containsRepo(dir string, repos map[string]bool) bool {
+	if fc.isRepoRoot(dir, repos) {
+		return true
+	}
+	children, err := fc.FS.ReadDir(filepath.Join(fc.WorkingTreeDir, dir))
+	if err != nil {
+		return true // unreadable: keep it
+	}
+	for _, c := range children {
+		sub := path.Join(dir, c.Name())
+		if info, err := fc.FS.Lstat(filepath.Join(fc.WorkingTreeDir, sub)); err == nil && info.IsDir() && fc.containsRepo(sub, repos) {
+			return true
+		}
+	}
+	return false
+}
+
+// canRemoveDir reports whether an untracked directory can go as a whole:
+// it holds neither the repository, a nested one, nor, unless they go too,
+// ignored files.
+func (fc *FileContext) canRemoveDir(dir string, ignored []string, withIgnored bool, repos map[string]bool) bool {
+	repoDir, err := filepath.Abs(fc.RepoDir)
+	if err != nil {
+		return false
+	}
+	if abs, err := filepath.Abs(filepath.Join(fc.WorkingTreeDir, dir)); err != nil || within(repoDir, abs) {
+		return false
+	}
+	if fc.containsRepo(dir, repos) {
+		return false
+	}
+	for _, p := range ignored {
+		if within(p, dir) && (!withIgnored || fc.protected(p, repos)) {
+			return false
+		}
+	}
+	return true
+}
+
+// topUntrackedDir returns the topmost directory above rel that holds no
+// tracked path, or "" when rel's directory is tracked.
+func topUntrackedDir(rel string, trackedDirs map[string]bool) string {
+	top := ""
+	for d := path.Dir(rel); !trackedDirs[d]; d = path.Dir(d) {
+		top = d
+	}
+	return top
+}
+
+// coveredBy reports whether a directory in seen lies above p.
+func coveredBy(p string, seen map[string]bool) bool {
+	for d := path.Dir(p); d != "."; d = path.Dir(d) {
+		if seen[d] {
+			return true
+		}
+	}
+	return false
+}
+
+// treeSize returns the bytes of a file, or of everything below a directory.
+func (fc *FileContext) treeSize(abs string) int64 {
+	info, err := fc.FS.Lstat(abs)
+	if err != nil {
+		return 0
+	}
+	if !info.IsDir() {
+		return info.Size()
+	}
+	children, err := fc.FS.ReadDir(abs)
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, c := range children {
+		n += fc.treeSize(filepath.Join(abs, c.Name()))
+	}
+	return n
+}
+
+// removeTree removes a file, or a directory with everything below it.
+// Links are removed, never followed.
+func (fc *FileContext) removeTree(abs string) error {
+	info, err := fc.FS.Lstat(abs)
+	if err != nil {
+		if fc.FS.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.IsDir() {
+		children, err := fc.FS.ReadDir(abs)
+		if err != nil {
+			return err
+		}
+		for _, c := range children {
+			if err := fc.removeTree(filepath.Join(abs, c.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	if err := fc.FS.Remove(abs); err != nil && !fc.FS.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// String formats an untracked path for listing, directories with a slash.
+func (u Untracked) String() string {
+	if u.Dir {
+		return strings.TrimSuffix(u.Path, "/") + "/"
+	}
+	return u.Path
+}
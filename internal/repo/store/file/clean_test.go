@@ -0,0 +1,132 @@
+package file_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func TestListAndRemoveUntracked(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	for p, data := range map[string]string{
+		"src/main.c":      "tracked",
+		"src/main.o":      "ignored!",
+		"src/notes.txt":   "untracked",
+		"build/out.bin":   "1234",
+		"build/deep/x":    "5",
+		"logs/run.log":    "ignored",
+		"logs/keep.txt":   "untracked",
+		".bvc-pointer":    ".bvc",
+		"src/.bvc-ignore": "",
+	} {
+		abs := filepath.Join(tmpDir, p)
+		if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.FS.WriteFile(abs, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fc.FS.WriteFile(filepath.Join(tmpDir, ".bvc-ignore"), []byte("*.o\n*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tracked := map[string]bool{"src/main.c": true, ".bvc-ignore": true, "src/.bvc-ignore": true}
+
+	paths := func(items []file.Untracked) []string {
+		var out []string
+		for _, u := range items {
+			out = append(out, u.String())
+		}
+		return out
+	}
+
+	items, err := fc.ListUntracked(tracked, file.CleanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"src/notes.txt"}; !reflect.DeepEqual(paths(items), want) {
+		t.Fatalf("files: %v, want %v", paths(items), want)
+	}
+
+	// logs/ holds an ignored file, so only its untracked file goes
+	items, err = fc.ListUntracked(tracked, file.CleanOptions{Dirs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"build/", "logs/keep.txt", "src/notes.txt"}; !reflect.DeepEqual(paths(items), want) {
+		t.Fatalf("dirs: %v, want %v", paths(items), want)
+	}
+	if items[0].Size != 5 {
+		t.Fatalf("build/ size = %d, want 5", items[0].Size)
+	}
+
+	items, err = fc.ListUntracked(tracked, file.CleanOptions{Dirs: true, Ignored: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"build/", "logs/", "src/main.o", "src/notes.txt"}; !reflect.DeepEqual(paths(items), want) {
+		t.Fatalf("dirs and ignored: %v, want %v", paths(items), want)
+	}
+
+	if err := fc.RemoveUntracked(items, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"build", "logs", "src/main.o", "src/notes.txt"} {
+		if fc.Exists(filepath.Join(tmpDir, p)) {
+			t.Errorf("%s was not removed", p)
+		}
+	}
+	for _, p := range []string{"src/main.c", ".bvc-pointer", ".bvc"} {
+		if !fc.Exists(filepath.Join(tmpDir, p)) {
+			t.Errorf("%s was removed", p)
+		}
+	}
+}
+
+func TestListUntrackedSkipsNestedRepos(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	for _, p := range []string{
+		"main.c",
+		"nested/loose.txt",
+		"nested/inner/x.txt",
+		"nested/inner/.bvc/HEAD",
+		"linked/y.txt",
+		"linked/.bvc-pointer",
+	} {
+		abs := filepath.Join(tmpDir, p)
+		if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.FS.WriteFile(abs, []byte(p), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracked := map[string]bool{"main.c": true}
+
+	var items []file.Untracked
+	for _, opts := range []file.CleanOptions{{Dirs: true}, {Dirs: true, Ignored: true}} {
+		var err error
+		if items, err = fc.ListUntracked(tracked, opts); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range items {
+			got = append(got, u.String())
+		}
+		if want := []string{"nested/loose.txt"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%+v: %v, want %v", opts, got, want)
+		}
+	}
+	if err := fc.RemoveUntracked(items, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"nested/inner/x.txt", "nested/inner/.bvc/HEAD", "linked/y.txt", "linked/.bvc-pointer"} {
+		if !fc.Exists(filepath.Join(tmpDir, p)) {
+			t.Errorf("%s in a nested repository was removed", p)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package file
+
+import (
+	"os"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/store/codec"
+)
+
+// entry flags in the binary encoding
+const entryDeleted = 1 << 0
+
+// WriteEntries appends entries to w sorted by path.
+func WriteEntries(w *codec.Writer, entries []Entry) {
+	sorted := append([]Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	w.Uvarint(uint64(len(sorted)))
+	for _, e := range sorted {
+		var flags uint64
+		if e.Deleted {
+			flags |= entryDeleted
+		}
+		w.String(e.Path)
+		w.Uvarint(flags)
+		w.Uvarint(uint64(e.Mode))
+		w.Varint(e.ModTime)
+		w.Varint(e.Size)
+		w.String(e.Link)
+		w.Blocks(e.Blocks)
+	}
+}
+
+// ReadEntries reads entries written by WriteEntries.
+func ReadEntries(r *codec.Reader) ([]Entry, error) {
+	n := r.Count()
+	entries := make([]Entry, 0, n)
+	for i := 0; i < n && r.Err() == nil; i++ {
+		e := Entry{Path: r.String()}
+		flags := r.Uvarint()
+		e.Deleted = flags&entryDeleted != 0
+		e.Mode = os.FileMode(r.Uvarint())
+		e.ModTime = r.Varint()
+		e.Size = r.Varint()
+		e.Link = r.String()
+		e.Blocks = r.Blocks()
+		entries = append(entries, e)
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -1,17 +1,41 @@
 package file
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
 	"github.com/keshon/bvc/internal/fs"
 	"github.com/keshon/bvc/internal/repo/store/block"
 )
 
 // Entry represents a tracked file and its content blocks.
+// Metadata fields are zero in entries recorded before they were introduced.
 type Entry struct {
 	Path   string
 	Blocks []block.BlockRef
+
+	Mode    os.FileMode `json:",omitempty"` // permission and type bits (dir, symlink)
+	ModTime int64       `json:",omitempty"` // modification time, unix nanoseconds
+	Size    int64       `json:",omitempty"` // total content size in bytes
+	Link    string      `json:",omitempty"` // symlink target
+
+	// Deleted marks an index entry that stages the removal of Path.
+	// It is never set on entries stored in a committed fileset.
+	Deleted bool `json:",omitempty"`
 }
 
-// Equal compares two entries by their blocks.
+// IsDir reports whether the entry records an (empty) directory.
+func (e *Entry) IsDir() bool { return e.Mode.IsDir() }
+
+// IsSymlink reports whether the entry records a symbolic link.
+func (e *Entry) IsSymlink() bool { return e.Mode&os.ModeSymlink != 0 }
+
+// Equal compares two entries by their blocks, kind, link target and
+// executable bit. Modification times are ignored, and so is the mode when
+// either entry predates metadata recording.
 func (e *Entry) Equal(other *Entry) bool {
 	if e == nil && other == nil {
 		return true
@@ -19,6 +43,14 @@ func (e *Entry) Equal(other *Entry) bool {
 	if e == nil || other == nil {
 		return false
 	}
+	if e.Deleted != other.Deleted || e.Link != other.Link {
+		return false
+	}
+	if e.Mode != 0 && other.Mode != 0 {
+		if e.Mode.Type() != other.Mode.Type() || e.Mode&0o111 != other.Mode&0o111 {
+			return false
+		}
+	}
 	if len(e.Blocks) != len(other.Blocks) {
 		return false
 	}
@@ -34,7 +66,9 @@ func (e *Entry) Equal(other *Entry) bool {
 type BlockContext interface {
 	BlocksDir() string
 	SplitFile(path string) ([]block.BlockRef, error)
+	SplitFileWith(path string, p block.Policy) ([]block.BlockRef, error)
 	Write(path string, blocks []block.BlockRef) error
+	WriteWith(path string, blocks []block.BlockRef, p block.Policy) error
 	Read(hash string) ([]byte, error)
 }
 
@@ -44,9 +78,30 @@ type FileContext struct {
 	RepoDir        string
 	BlockCtx       BlockContext
 	FS             fs.FS
+
+	attrsOnce sync.Once
+	attrs     *Attributes
 }
 
 // NewFileContext creates a new FileContext.
 func NewFileContext(workingTreeDir, repoDir string, blocks BlockContext, fs fs.FS) *FileContext {
 	return &FileContext{WorkingTreeDir: workingTreeDir, RepoDir: repoDir, BlockCtx: blocks, FS: fs}
 }
+
+// RelPath turns a path given on the command line, relative to the current
+// directory, into a slash-separated path relative to the working tree root.
+func (fc *FileContext) RelPath(arg string) (string, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", arg, err)
+	}
+	root, err := filepath.Abs(fc.WorkingTreeDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", fc.WorkingTreeDir, err)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the working tree", arg)
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -23,7 +23,13 @@ func newMockBlock() *mockBlock {
 func (b *mockBlock) SplitFile(path string) ([]block.BlockRef, error) {
 	return []block.BlockRef{{Hash: path + "-hash", Size: 123}}, nil
 }
+func (b *mockBlock) SplitFileWith(path string, p block.Policy) ([]block.BlockRef, error) {
+	return b.SplitFile(path)
+}
 func (b *mockBlock) Write(path string, blocks []block.BlockRef) error { return nil }
+func (b *mockBlock) WriteWith(path string, blocks []block.BlockRef, p block.Policy) error {
+	return nil
+}
 func (b *mockBlock) Read(hash string) ([]byte, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
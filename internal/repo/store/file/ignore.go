@@ -2,6 +2,7 @@ package file
 
 import (
 	"bufio"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -9,56 +10,247 @@ import (
 	"github.com/keshon/bvc/internal/fs"
 )
 
+// Ignore decides which working tree paths are ignored, following gitignore
+// semantics. Rules come from, in increasing order of precedence:
+//
+//   - the user-global excludes file (config.ResolveGlobalIgnoreFile)
+//   - .bvc-ignore in the working tree root
+//   - .bvc-ignore files in subdirectories, which apply below their directory
+//
+// Within a file, later rules override earlier ones, and a path inside an
+// ignored directory is ignored no matter what rules say about the path itself.
+// The repository's own files (config.DefaultIgnoredFiles) are always ignored.
 type Ignore struct {
-	static  map[string]bool
-	pattern []string
+	root   string
+	fs     fs.FS
+	static map[string]bool
+	global []Rule
+	dirs   map[string][]Rule // rules of the .bvc-ignore in each directory, loaded on demand
+	cache  map[string]*Rule  // decisions for directories
 }
 
-// NewIgnore loads defaults and .bvc-ignore from the given repo root.
+// Rule is one pattern line of an ignore file.
+type Rule struct {
+	Source  string // file the rule was read from; "" for built-in rules
+	Line    int
+	Pattern string // the pattern as written
+
+	base     string // directory the rule applies below, relative to the root
+	glob     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Negate reports whether the rule re-includes paths ("!pattern").
+func (r *Rule) Negate() bool { return r.negate }
+
+// NewIgnore loads defaults, the global excludes file and .bvc-ignore from the
+// given working tree root. Ignore files of subdirectories are read as paths
+// below them are matched.
 func NewIgnore(repoRoot string, fs fs.FS) *Ignore {
-	m := &Ignore{static: make(map[string]bool)}
+	m := &Ignore{
+		root:   repoRoot,
+		fs:     fs,
+		static: make(map[string]bool),
+		dirs:   make(map[string][]Rule),
+		cache:  make(map[string]*Rule),
+	}
 
 	// Default ignored files
 	for _, s := range config.DefaultIgnoredFiles {
-		m.static[filepath.Clean(s)] = true
+		m.static[filepath.ToSlash(filepath.Clean(s))] = true
 	}
 
-	ignorePath := filepath.Join(repoRoot, config.IgnoredFilesFile)
-	f, err := fs.Open(ignorePath)
-	if err == nil {
-		sc := bufio.NewScanner(f)
-		for sc.Scan() {
-			line := strings.TrimSpace(sc.Text())
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
-			}
-			m.pattern = append(m.pattern, line)
+	if global := config.ResolveGlobalIgnoreFile(); global != "" {
+		m.global = m.readRules(global, "")
+	}
+	return m
+}
+
+// newIgnoreFromLines builds a matcher from root-level pattern lines.
+func newIgnoreFromLines(static []string, lines ...string) *Ignore {
+	m := &Ignore{
+		static: make(map[string]bool),
+		dirs:   make(map[string][]Rule),
+		cache:  make(map[string]*Rule),
+	}
+	for _, s := range static {
+		m.static[s] = true
+	}
+	for i, line := range lines {
+		if r, ok := parseRule(line, "", config.IgnoredFilesFile, i+1); ok {
+			m.dirs[""] = append(m.dirs[""], r)
 		}
-		f.Close()
 	}
 	return m
 }
 
-// Match returns true if the path should be ignored
-// supply only relative paths
-func (m *Ignore) Match(path string) bool {
-	clean := filepath.ToSlash(filepath.Clean(path))
+// readRules parses an ignore file whose rules apply below base.
+func (m *Ignore) readRules(source, base string) []Rule {
+	if m.fs == nil {
+		return nil
+	}
+	f, err := m.fs.Open(source)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
 
-	// static exact match
-	if m.static[clean] {
-		return true
+	var rules []Rule
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if r, ok := parseRule(sc.Text(), base, source, n); ok {
+			rules = append(rules, r)
+		}
 	}
+	return rules
+}
 
-	// pattern match
-	for _, pat := range m.pattern {
-		if matchPattern(pat, clean) {
-			return true
+// parseRule parses one ignore file line. Blank lines and comments yield no rule.
+func parseRule(line, base, source string, n int) (Rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Rule{}, false
+	}
+
+	r := Rule{Source: source, Line: n, Pattern: line, base: base}
+	p := line
+	switch {
+	case strings.HasPrefix(p, "!"):
+		r.negate = true
+		p = p[1:]
+	case strings.HasPrefix(p, `\!`), strings.HasPrefix(p, `\#`):
+		p = p[1:]
+	}
+	p = strings.ReplaceAll(p, `\ `, " ")
+
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	// a slash anywhere but at the end anchors the pattern to its directory
+	if strings.Contains(p, "/") {
+		r.anchored = true
+		p = strings.TrimPrefix(p, "/")
+	}
+	if p == "" {
+		return Rule{}, false
+	}
+	r.glob = p
+	return r, true
+}
+
+// matches reports whether the rule's pattern matches a path relative to the root.
+func (r *Rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
 		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		return matchPattern(r.glob, path.Base(rel))
 	}
+	return matchPattern(r.glob, rel)
+}
 
+// matchesPath matches the rule against the path and its parent directories,
+// so directory patterns ("generated/") apply to everything below them.
+func (r *Rule) matchesPath(rel string) bool {
+	if r.matches(rel, false) {
+		return true
+	}
+	for dir := filepath.ToSlash(filepath.Dir(rel)); dir != "." && dir != "/"; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if r.matches(dir, true) {
+			return true
+		}
+	}
 	return false
 }
 
+// rulesFor returns the rules of the .bvc-ignore file in dir.
+func (m *Ignore) rulesFor(dir string) []Rule {
+	rules, ok := m.dirs[dir]
+	if !ok {
+		if m.root != "" {
+			rules = m.readRules(filepath.Join(m.root, filepath.FromSlash(dir), config.IgnoredFilesFile), dir)
+		}
+		m.dirs[dir] = rules
+	}
+	return rules
+}
+
+// decide returns the last rule matching rel itself, ignoring its parents.
+func (m *Ignore) decide(rel string, isDir bool) *Rule {
+	var match *Rule
+	check := func(rules []Rule) {
+		for i := range rules {
+			if rules[i].matches(rel, isDir) {
+				match = &rules[i]
+			}
+		}
+	}
+
+	check(m.global)
+	check(m.rulesFor(""))
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		check(m.rulesFor(strings.Join(parts[:i], "/")))
+	}
+	return match
+}
+
+// Check returns the rule that decides whether the path is ignored, or nil
+// when no rule matches. A path is ignored when the returned rule is not a
+// negation. Paths inside an ignored directory report the directory's rule.
+func (m *Ignore) Check(rel string, isDir bool) *Rule {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == "" {
+		return nil
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		p := strings.Join(parts[:i], "/")
+		if m.static[p] {
+			return &Rule{Pattern: p}
+		}
+		if i == len(parts) {
+			break
+		}
+		r, ok := m.cache[p]
+		if !ok {
+			r = m.decide(p, true)
+			m.cache[p] = r
+		}
+		if r != nil && !r.negate {
+			return r
+		}
+	}
+	return m.decide(rel, isDir)
+}
+
+// Ignored reports whether the path is ignored.
+func (m *Ignore) Ignored(rel string, isDir bool) bool {
+	r := m.Check(rel, isDir)
+	return r != nil && !r.negate
+}
+
+// Match returns true if the path should be ignored
+// supply only relative paths; directories are recognized via the FS
+func (m *Ignore) Match(rel string) bool {
+	isDir := m.fs != nil && m.root != "" && m.fs.IsDir(filepath.Join(m.root, filepath.FromSlash(rel)))
+	return m.Ignored(rel, isDir)
+}
+
 // matchPattern handles *, ?, and ** like Git
 func matchPattern(pattern, path string) bool {
 	pattern = filepath.ToSlash(pattern)
@@ -100,7 +292,7 @@ func matchSegments(pats, parts []string) bool {
 			return false
 		}
 
-		ok, _ := filepath.Match(p, parts[0])
+		ok, _ := path.Match(p, parts[0])
 		if !ok {
 			return false
 		}
@@ -3,6 +3,9 @@ package file
 import (
 	"path/filepath"
 	"testing"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/fs"
 )
 
 // helper for pattern test
@@ -88,17 +91,12 @@ func TestMatchPattern_Basics(t *testing.T) {
 // TestIgnore_StaticAndPatterns tests the Match function with a mix of static and pattern-based ignores.
 // It tests both exact matches and pattern matches, and ensures that the ignore logic works as expected.
 func TestIgnore_StaticAndPatterns(t *testing.T) {
-	m := &Ignore{
-		static: map[string]bool{
-			"exact.txt": true,
-			"temp.log":  true,
-		},
-		pattern: []string{
-			"*.bak",
-			"logs/**",
-			"**/*.tmp",
-		},
-	}
+	m := newIgnoreFromLines(
+		[]string{"exact.txt", "temp.log"},
+		"*.bak",
+		"logs/**",
+		"**/*.tmp",
+	)
 
 	cases := []struct {
 		path string
@@ -160,3 +158,101 @@ func TestMatchPattern_WeirdCases(t *testing.T) {
 		}
 	}
 }
+
+// TestIgnore_GitignoreSemantics tests negation, directory-only rules,
+// anchoring and unanchored patterns matching at any depth.
+func TestIgnore_GitignoreSemantics(t *testing.T) {
+	m := newIgnoreFromLines(nil,
+		"# comment",
+		"*.tmp",
+		"!keep.tmp",
+		"build/",
+		"/root-only.txt",
+		"docs/*.pdf",
+		`\#hash`,
+	)
+
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.tmp", false, true},
+		{"deep/dir/b.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"deep/keep.tmp", false, false},
+
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"build/out.o", false, true}, // inside ignored dir
+
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false},
+
+		{"docs/a.pdf", false, true},
+		{"sub/docs/a.pdf", false, false},
+
+		{"#hash", false, true},
+		{"comment", false, false},
+	}
+
+	for _, tt := range cases {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+// TestIgnore_ParentDirectoryExcluded tests that a negation cannot re-include
+// a file whose parent directory is excluded.
+func TestIgnore_ParentDirectoryExcluded(t *testing.T) {
+	m := newIgnoreFromLines(nil, "logs/", "!logs/important.log")
+	if !m.Ignored("logs/important.log", false) {
+		t.Error("file inside an excluded directory must stay ignored")
+	}
+
+	m = newIgnoreFromLines(nil, "logs/*", "!logs/important.log")
+	if m.Ignored("logs/important.log", false) {
+		t.Error("file re-included by negation should not be ignored")
+	}
+	if r := m.Check("logs/other.log", false); r == nil || r.Line != 1 {
+		t.Errorf("expected rule on line 1 to decide, got %+v", r)
+	}
+}
+
+// TestIgnore_NestedFiles tests .bvc-ignore files in subdirectories.
+func TestIgnore_NestedFiles(t *testing.T) {
+	mem := fs.NewMemoryFS()
+	mem.MkdirAll("/wt/sub/deeper", 0o755)
+	mem.WriteFile("/wt/.bvc-ignore", []byte("*.log\n"), 0o644)
+	mem.WriteFile("/wt/sub/.bvc-ignore", []byte("!keep.log\n/local.txt\n"), 0o644)
+
+	t.Setenv(config.GlobalIgnoreEnv, "/wt/global-ignore")
+	mem.WriteFile("/wt/global-ignore", []byte("*.swp\n"), 0o644)
+
+	m := NewIgnore("/wt", mem)
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"a.log", true},
+		{"sub/keep.log", false},
+		{"sub/deeper/keep.log", false},
+		{"keep.log", true},
+		{"sub/local.txt", true},
+		{"sub/deeper/local.txt", false},
+		{"local.txt", false},
+		{"x.swp", true},
+		{".bvc", true},
+	}
+	for _, tt := range cases {
+		if got := m.Ignored(tt.path, false); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if r := m.Check("sub/local.txt", false); r == nil || r.Source != "/wt/sub/.bvc-ignore" || r.Line != 2 {
+		t.Errorf("unexpected deciding rule %+v", r)
+	}
+}
@@ -3,21 +3,67 @@ package file
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
+
+	"github.com/keshon/bvc/internal/repo/store/codec"
 )
 
-// SaveIndexReplace overwrites the index completely (for hard resets or clean writes).
-func (fc *FileContext) SaveIndexReplace(entries []Entry) error {
-	indexPath := filepath.Join(fc.RepoDir, "index.json")
-	data, err := json.Marshal(entries)
+// The index is stored in the binary format under indexFile. Repositories
+// written before it was introduced have a JSON legacyIndexFile instead,
+// which is still read and is replaced on the next write.
+const (
+	indexFile       = "index"
+	legacyIndexFile = "index.json"
+
+	indexMagic   = "BVCI"
+	indexVersion = 1
+)
+
+// EncodeIndex serializes index entries, sorted by path.
+func EncodeIndex(entries []Entry) []byte {
+	w := codec.NewWriter(indexMagic, indexVersion)
+	WriteEntries(w, entries)
+	return w.Bytes()
+}
+
+// DecodeIndex parses an index in either the binary or the legacy JSON format.
+func DecodeIndex(data []byte) ([]Entry, error) {
+	if !codec.IsEncoded(data, indexMagic) {
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("unmarshal index: %w", err)
+		}
+		return entries, nil
+	}
+	r, err := codec.Decode(data, indexMagic, indexVersion)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ReadEntries(r)
 	if err != nil {
-		return fmt.Errorf("marshal index: %w", err)
+		return nil, fmt.Errorf("decode index: %w", err)
 	}
+	return entries, nil
+}
+
+// IndexPaths returns the paths of the binary index and of the legacy JSON index.
+func (fc *FileContext) IndexPaths() (current, legacy string) {
+	return filepath.Join(fc.RepoDir, indexFile), filepath.Join(fc.RepoDir, legacyIndexFile)
+}
+
+// SaveIndexReplace overwrites the index completely (for hard resets or clean writes).
+func (fc *FileContext) SaveIndexReplace(entries []Entry) error {
+	indexPath, legacyPath := fc.IndexPaths()
 	if err := fc.FS.MkdirAll(filepath.Dir(indexPath), 0o755); err != nil {
 		return fmt.Errorf("mkdir index dir: %w", err)
 	}
-	return fc.FS.WriteFile(indexPath, data, 0o644)
+	if err := codec.WriteFile(fc.FS, indexPath, EncodeIndex(entries)); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	if err := fc.FS.Remove(legacyPath); err != nil && !fc.FS.IsNotExist(err) {
+		return fmt.Errorf("remove legacy index: %w", err)
+	}
+	return nil
 }
 
 // SaveIndexMerge merges the given entries with any existing index on disk.
@@ -48,16 +94,22 @@ func (fc *FileContext) SaveIndexMerge(newEntries []Entry) error {
 
 // ClearIndex removes the staging index.
 func (fc *FileContext) ClearIndex() error {
-	indexPath := filepath.Join(fc.RepoDir, "index.json")
-	if _, err := fc.FS.Stat(indexPath); os.IsNotExist(err) {
-		return nil
+	indexPath, legacyPath := fc.IndexPaths()
+	for _, p := range []string{indexPath, legacyPath} {
+		if err := fc.FS.Remove(p); err != nil && !fc.FS.IsNotExist(err) {
+			return err
+		}
 	}
-	return fc.FS.Remove(indexPath)
+	return nil
 }
 
-// LoadIndex loads staged entries from disk.
+// LoadIndex loads staged entries from disk, preferring the binary index
+// over a legacy JSON one.
 func (fc *FileContext) LoadIndex() ([]Entry, error) {
-	indexPath := filepath.Join(fc.RepoDir, "index.json")
+	indexPath, legacyPath := fc.IndexPaths()
+	if !fc.FS.Exists(indexPath) {
+		indexPath = legacyPath
+	}
 	if _, err := fc.FS.Stat(indexPath); fc.FS.IsNotExist(err) {
 		return nil, nil
 	}
@@ -65,9 +117,10 @@ func (fc *FileContext) LoadIndex() ([]Entry, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read index: %w", err)
 	}
-	var entries []Entry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		return nil, fmt.Errorf("unmarshal index: %w", err)
-	}
-	return entries, nil
+	return DecodeIndex(data)
+}
+
+// DeletedEntry returns an index entry that stages the removal of path.
+func DeletedEntry(path string) Entry {
+	return Entry{Path: filepath.ToSlash(filepath.Clean(path)), Deleted: true}
 }
@@ -81,3 +81,32 @@ func TestClearIndexMissingFile(t *testing.T) {
 		t.Error("ClearIndex should succeed on missing file")
 	}
 }
+
+func TestLoadIndexLegacyJSON(t *testing.T) {
+	fc, _ := newTestFC(t)
+
+	legacy := filepath.Join(fc.RepoDir, "index.json")
+	if err := fc.FS.WriteFile(legacy, []byte(`[{"Path":"b.txt"},{"Path":"a.txt","Deleted":true}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := fc.LoadIndex()
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("expected legacy index to load, got %v (%v)", loaded, err)
+	}
+
+	// the next write replaces the legacy file with the binary index, sorted
+	if err := fc.SaveIndexReplace(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if fc.FS.Exists(legacy) {
+		t.Error("legacy index should be removed after rewrite")
+	}
+	loaded, err = fc.LoadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Path != "a.txt" || !loaded[0].Deleted || loaded[1].Path != "b.txt" {
+		t.Errorf("unexpected entries after round trip: %+v", loaded)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the inode number of a file, or 0 if the FS does not expose it.
+func inodeOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package file
+
+import "os"
+
+// inodeOf returns 0: file IDs are not part of os.FileInfo on Windows.
+func inodeOf(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,243 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/codec"
+)
+
+// A patch rewrites only the blocks of a file that changed, in place. Before
+// the file is touched, the bytes about to be overwritten are saved to the
+// patch journal, so a patch interrupted half way can be rolled back.
+const (
+	patchJournalFile    = "patch-journal"
+	patchJournalMagic   = "BVCJ"
+	patchJournalVersion = 1
+)
+
+// maxPatchBytes bounds the content a patch writes and the original content
+// its journal keeps, both of which are held in memory; larger changes are
+// made by rewriting the file, which streams block by block.
+const maxPatchBytes = 64 << 20 // 64 MiB
+
+// patchJournal records how to undo a patch.
+type patchJournal struct {
+	Path    string
+	Size    int64 // size of the file before the patch
+	Regions []patchRegion
+}
+
+// patchRegion is original content at an offset.
+type patchRegion struct {
+	Offset int64
+	Data   []byte
+}
+
+func (j *patchJournal) encode() []byte {
+	w := codec.NewWriter(patchJournalMagic, patchJournalVersion)
+	w.String(j.Path)
+	w.Varint(j.Size)
+	w.Uvarint(uint64(len(j.Regions)))
+	for _, r := range j.Regions {
+		w.Varint(r.Offset)
+		w.String(string(r.Data))
+	}
+	return w.Bytes()
+}
+
+func decodePatchJournal(data []byte) (patchJournal, error) {
+	r, err := codec.Decode(data, patchJournalMagic, patchJournalVersion)
+	if err != nil {
+		return patchJournal{}, err
+	}
+	j := patchJournal{Path: r.String(), Size: r.Varint()}
+	n := r.Count()
+	for i := 0; i < n && r.Err() == nil; i++ {
+		j.Regions = append(j.Regions, patchRegion{Offset: r.Varint(), Data: []byte(r.String())})
+	}
+	return j, r.Err()
+}
+
+// planPatch returns the blocks of the new layout that differ from the old
+// one, or false when patching is not worth it: block boundaries must line up
+// so that at least half of the new content stays where it is, and the bytes
+// written and truncated away must fit in maxPatchBytes.
+func planPatch(old, new []block.BlockRef) ([]block.BlockRef, bool) {
+	if len(old) < 2 || len(new) < 2 {
+		return nil, false
+	}
+	at := make(map[int64]block.BlockRef, len(old))
+	var oldSize int64
+	for _, b := range old {
+		at[b.Offset] = b
+		oldSize += b.Size
+	}
+	var changed []block.BlockRef
+	var kept, total int64
+	for _, b := range new {
+		total += b.Size
+		if o, ok := at[b.Offset]; ok && o.Hash == b.Hash && o.Size == b.Size {
+			kept += b.Size
+			continue
+		}
+		changed = append(changed, b)
+	}
+	if kept*2 < total || total-kept+max(oldSize-total, 0) > maxPatchBytes {
+		return nil, false
+	}
+	return changed, true
+}
+
+// patchFile turns the file at e.Path, whose content is made of the old
+// blocks, into e by rewriting only the changed blocks. It reports false when
+// the layouts are too different and the file must be rewritten instead.
+func (fc *FileContext) patchFile(e Entry, old []block.BlockRef) (bool, error) {
+	changed, ok := planPatch(old, e.Blocks)
+	if !ok {
+		return false, nil
+	}
+	var oldSize, newSize int64
+	for _, b := range old {
+		oldSize += b.Size
+	}
+	for _, b := range e.Blocks {
+		newSize += b.Size
+	}
+
+	// read the new content first: a missing block must not leave a half patched file
+	contents := make([][]byte, len(changed))
+	for i, b := range changed {
+		data, err := fc.BlockCtx.Read(b.Hash)
+		if err != nil {
+			return false, fmt.Errorf("missing block %s for %s", b.Hash, e.Path)
+		}
+		if int64(len(data)) != b.Size {
+			return false, fmt.Errorf("block %s for %s has size %d, want %d", b.Hash, e.Path, len(data), b.Size)
+		}
+		contents[i] = data
+	}
+
+	f, err := fc.FS.OpenInPlace(e.Path)
+	if err != nil {
+		return false, nil // not supported here; rewrite the file
+	}
+	defer f.Close()
+
+	// journal the bytes that are overwritten or truncated away
+	j := patchJournal{Path: e.Path, Size: oldSize}
+	save := func(off, end int64) error {
+		end = min(end, oldSize)
+		if off >= end {
+			return nil
+		}
+		data := make([]byte, end-off)
+		if _, err := f.ReadAt(data, off); err != nil {
+			return err
+		}
+		j.Regions = append(j.Regions, patchRegion{Offset: off, Data: data})
+		return nil
+	}
+	for _, b := range changed {
+		if err := save(b.Offset, b.Offset+b.Size); err != nil {
+			return false, fmt.Errorf("failed to journal %s: %w", e.Path, err)
+		}
+	}
+	if err := save(newSize, oldSize); err != nil {
+		return false, fmt.Errorf("failed to journal %s: %w", e.Path, err)
+	}
+	if err := fc.writePatchJournal(j); err != nil {
+		return false, err
+	}
+
+	apply := func() error {
+		for i, b := range changed {
+			if _, err := f.WriteAt(contents[i], b.Offset); err != nil {
+				return err
+			}
+		}
+		if err := f.Truncate(newSize); err != nil {
+			return err
+		}
+		return f.Sync()
+	}
+	if err := apply(); err != nil {
+		if rerr := fc.rollbackPatch(j); rerr != nil {
+			return false, fmt.Errorf("failed to patch %s: %v; rollback failed: %w", e.Path, err, rerr)
+		}
+		return false, fmt.Errorf("failed to patch %s: %w", e.Path, err)
+	}
+	if err := fc.clearPatchJournal(); err != nil {
+		return false, err
+	}
+	return true, fc.applyMetadata(e)
+}
+
+func (fc *FileContext) patchJournalPath() string {
+	return filepath.Join(fc.RepoDir, patchJournalFile)
+}
+
+func (fc *FileContext) writePatchJournal(j patchJournal) error {
+	if err := codec.WriteFile(fc.FS, fc.patchJournalPath(), j.encode()); err != nil {
+		return fmt.Errorf("failed to write patch journal: %w", err)
+	}
+	return nil
+}
+
+func (fc *FileContext) clearPatchJournal() error {
+	if err := fc.FS.Remove(fc.patchJournalPath()); err != nil && !fc.FS.IsNotExist(err) {
+		return fmt.Errorf("failed to remove patch journal: %w", err)
+	}
+	return nil
+}
+
+// rollbackPatch restores the original content saved in the journal.
+func (fc *FileContext) rollbackPatch(j patchJournal) error {
+	f, err := fc.FS.OpenInPlace(j.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, r := range j.Regions {
+		if _, err := f.WriteAt(r.Data, r.Offset); err != nil {
+			return err
+		}
+	}
+	if err := f.Truncate(j.Size); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return fc.clearPatchJournal()
+}
+
+// RecoverPatch rolls back a patch that was interrupted, returning the path
+// of the restored file, or "" if there was nothing to recover.
+func (fc *FileContext) RecoverPatch() (string, error) {
+	data, err := fc.FS.ReadFile(fc.patchJournalPath())
+	if err != nil {
+		if fc.FS.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read patch journal: %w", err)
+	}
+	j, err := decodePatchJournal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode patch journal: %w", err)
+	}
+	if err := fc.rollbackPatch(j); err != nil {
+		return "", fmt.Errorf("failed to roll back interrupted patch of %s: %w", j.Path, err)
+	}
+	return j.Path, nil
+}
+
+// recoverPatch is RecoverPatch for commands about to write the working tree.
+func (fc *FileContext) recoverPatch() error {
+	path, err := fc.RecoverPatch()
+	if path != "" {
+		fmt.Printf("Rolled back interrupted patch of %s\n", path)
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+)
+
+// patchFixture stores the given blocks in a memory block store and returns a
+// FileContext over it.
+func patchFixture(t *testing.T, blocks map[string]string) *FileContext {
+	t.Helper()
+	mem := fs.NewMemoryFS()
+	if err := mem.MkdirAll("/repo/blocks", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.MkdirAll("/wt", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for hash, data := range blocks {
+		if err := mem.WriteFile(filepath.Join("/repo/blocks", hash+".bin"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewFileContext("/wt", "/repo", block.NewBlockContext("/repo/blocks", mem), mem)
+}
+
+func layout(sizes map[string]int64, hashes ...string) []block.BlockRef {
+	var refs []block.BlockRef
+	var off int64
+	for _, h := range hashes {
+		refs = append(refs, block.BlockRef{Hash: h, Size: sizes[h], Offset: off})
+		off += sizes[h]
+	}
+	return refs
+}
+
+func TestPlanPatch(t *testing.T) {
+	sizes := map[string]int64{"a": 4, "b": 4, "c": 4, "x": 4, "long": 6}
+
+	changed, ok := planPatch(layout(sizes, "a", "b", "c"), layout(sizes, "a", "x", "c"))
+	if !ok || len(changed) != 1 || changed[0].Hash != "x" || changed[0].Offset != 4 {
+		t.Errorf("expected only x to be written, got %v %v", changed, ok)
+	}
+
+	// a longer block shifts everything after it
+	if _, ok := planPatch(layout(sizes, "a", "b", "c"), layout(sizes, "long", "b", "c")); ok {
+		t.Errorf("shifted layouts should be rewritten")
+	}
+	if _, ok := planPatch(layout(sizes, "a"), layout(sizes, "x")); ok {
+		t.Errorf("single block files should be rewritten")
+	}
+
+	// changes too large to hold in memory are rewritten, however much is kept
+	sizes = map[string]int64{"keep": 4 * maxPatchBytes, "b": maxPatchBytes / 2, "x": maxPatchBytes / 2, "y": maxPatchBytes / 2}
+	if _, ok := planPatch(layout(sizes, "keep", "b"), layout(sizes, "keep", "x")); !ok {
+		t.Errorf("a change within the limit should be patched")
+	}
+	if _, ok := planPatch(layout(sizes, "keep", "b", "y", "y"), layout(sizes, "keep", "x", "x", "x")); ok {
+		t.Errorf("a change over the limit should be rewritten")
+	}
+	if _, ok := planPatch(layout(sizes, "keep", "b", "y", "y", "y"), layout(sizes, "keep", "x")); ok {
+		t.Errorf("a truncation over the limit should be rewritten")
+	}
+}
+
+func TestPatchFile(t *testing.T) {
+	blocks := map[string]string{"a": "AAAA", "b": "BBBB", "c": "CCCC", "x": "XXXX"}
+	sizes := map[string]int64{"a": 4, "b": 4, "c": 4, "x": 4}
+	fc := patchFixture(t, blocks)
+
+	path := "/wt/level.dat"
+	if err := fc.FS.WriteFile(path, []byte("AAAABBBBCCCC"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// change the middle block and drop the last one
+	e := Entry{Path: path, Blocks: layout(sizes, "a", "x")}
+	patched, err := fc.patchFile(e, layout(sizes, "a", "b", "c"))
+	if err != nil || !patched {
+		t.Fatalf("patchFile = %v, %v", patched, err)
+	}
+	if data, _ := fc.FS.ReadFile(path); string(data) != "AAAAXXXX" {
+		t.Errorf("patched content = %q", data)
+	}
+	if fc.FS.Exists(fc.patchJournalPath()) {
+		t.Errorf("journal should be removed after a patch")
+	}
+}
+
+func TestRecoverPatch(t *testing.T) {
+	fc := patchFixture(t, nil)
+
+	path := "/wt/level.dat"
+	if err := fc.FS.WriteFile(path, []byte("AAAABBBBCCCC"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// journal written, then interrupted after one of two writes
+	j := patchJournal{Path: path, Size: 12, Regions: []patchRegion{
+		{Offset: 4, Data: []byte("BBBB")},
+		{Offset: 8, Data: []byte("CCCC")},
+	}}
+	if err := fc.writePatchJournal(j); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.FS.WriteFile(path, []byte("AAAAXXXXCCCCYY"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fc.RecoverPatch()
+	if err != nil || got != path {
+		t.Fatalf("RecoverPatch = %q, %v", got, err)
+	}
+	if data, _ := fc.FS.ReadFile(path); string(data) != "AAAABBBBCCCC" {
+		t.Errorf("rolled back content = %q", data)
+	}
+	if got, err := fc.RecoverPatch(); got != "" || err != nil {
+		t.Errorf("second RecoverPatch = %q, %v", got, err)
+	}
+}
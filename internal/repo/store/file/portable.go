@@ -0,0 +1,208 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/keshon/bvc/internal/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Kinds of path portability problems.
+const (
+	PathCaseCollision    = "case"     // differs from another path only by case
+	PathUnicodeCollision = "unicode"  // differs from another path only by Unicode normalization
+	PathReservedName     = "reserved" // a Windows device name such as CON or LPT1
+	PathInvalidChar      = "char"     // a character Windows does not allow in names
+	PathTrailingDot      = "trailing" // a name ending in a dot or space, which Windows strips
+	PathInvalidUTF8      = "utf8"     // not valid UTF-8, which macOS rejects
+)
+
+// PathProblem is a tracked path that cannot be checked out faithfully on
+// Windows or macOS.
+type PathProblem struct {
+	Kind  string
+	Path  string // the offending path, or the directory above it that offends
+	Other string // for collisions, the path it collides with
+}
+
+func (p PathProblem) String() string {
+	switch p.Kind {
+	case PathCaseCollision:
+		return fmt.Sprintf("%s: differs from %s only by case", p.Path, p.Other)
+	case PathUnicodeCollision:
+		return fmt.Sprintf("%s: differs from %s only by Unicode normalization", p.Path, p.Other)
+	case PathReservedName:
+		return fmt.Sprintf("%s: reserved name on Windows", p.Path)
+	case PathInvalidChar:
+		return fmt.Sprintf("%s: contains a character not allowed on Windows", p.Path)
+	case PathTrailingDot:
+		return fmt.Sprintf("%s: ends in a dot or space, which Windows strips", p.Path)
+	case PathInvalidUTF8:
+		return fmt.Sprintf("%s: not valid UTF-8", p.Path)
+	}
+	return p.Path
+}
+
+// Strictness of the portability check in add and commit, from the
+// portable-paths setting or $BVC_PORTABLE_PATHS.
+const (
+	PortableOff    = "off"
+	PortableWarn   = "warn" // default
+	PortableRefuse = "refuse"
+)
+
+// reservedNames are the Windows device names, reserved with any extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// CheckPortablePaths reports the paths of a tree that break checkouts on
+// other systems: paths that collide on case-insensitive or
+// normalization-insensitive file systems, and names Windows does not allow.
+// A problem with a directory is reported once, for the directory.
+func CheckPortablePaths(paths []string) []PathProblem {
+	// every file and the directories above it, each once
+	names := map[string]bool{}
+	for _, p := range paths {
+		parts := strings.Split(p, "/")
+		for i := 1; i <= len(parts); i++ {
+			names[strings.Join(parts[:i], "/")] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	var problems []PathProblem
+	bad := map[string]bool{} // names already reported; paths below them are not
+	underBad := func(n string) bool {
+		for dir := parentOf(n); dir != ""; dir = parentOf(dir) {
+			if bad[dir] {
+				return true
+			}
+		}
+		return false
+	}
+
+	seen := map[string]string{} // folded name -> first name with it
+	for _, n := range sorted {
+		if underBad(n) {
+			continue
+		}
+		if kind := checkName(n[strings.LastIndex(n, "/")+1:]); kind != "" {
+			problems = append(problems, PathProblem{Kind: kind, Path: n})
+			bad[n] = true
+			continue
+		}
+		key := foldPath(n)
+		other, ok := seen[key]
+		if !ok {
+			seen[key] = n
+			continue
+		}
+		kind := PathCaseCollision
+		if norm.NFD.String(other) == norm.NFD.String(n) {
+			kind = PathUnicodeCollision
+		}
+		problems = append(problems, PathProblem{Kind: kind, Path: n, Other: other})
+		bad[n] = true
+	}
+	return problems
+}
+
+// checkName returns the kind of problem with one path component, or "".
+func checkName(name string) string {
+	if !utf8.ValidString(name) {
+		return PathInvalidUTF8
+	}
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+			return PathInvalidChar
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return PathTrailingDot
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return PathReservedName
+	}
+	return ""
+}
+
+// foldPath maps paths that name the same file on a case-insensitive,
+// normalization-insensitive file system to the same key: "é" written as one
+// code point or as "e" and a combining accent fold alike.
+func foldPath(p string) string {
+	return strings.ToLower(norm.NFD.String(p))
+}
+
+func parentOf(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// FilterPathProblems keeps the problems that concern one of the given paths
+// or a directory above one, so existing problems do not block new changes.
+func FilterPathProblems(problems []PathProblem, paths []string) []PathProblem {
+	touched := map[string]bool{}
+	for _, p := range paths {
+		for ; p != ""; p = parentOf(p) {
+			touched[p] = true
+		}
+	}
+	var out []PathProblem
+	for _, pr := range problems {
+		if touched[pr.Path] || (pr.Other != "" && touched[pr.Other]) {
+			out = append(out, pr)
+		}
+	}
+	return out
+}
+
+// ReportPortablePaths prints the problems as warnings, or refuses them with
+// an error, as the strictness mode says.
+func ReportPortablePaths(problems []PathProblem, mode string) error {
+	if len(problems) == 0 || mode == PortableOff {
+		return nil
+	}
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = p.String()
+	}
+	if mode == PortableRefuse {
+		return fmt.Errorf("paths would break checkouts on other systems:\n\t%s\nrename them, or run `bvc config %s %s` to allow them",
+			strings.Join(lines, "\n\t"), SettingPortablePaths, PortableWarn)
+	}
+	for _, l := range lines {
+		fmt.Fprintln(os.Stderr, "warning:", l)
+	}
+	return nil
+}
+
+// PortablePathsMode returns the strictness of the portability check:
+// $BVC_PORTABLE_PATHS for one run, otherwise the portable-paths setting,
+// otherwise warn.
+func (fc *FileContext) PortablePathsMode() (string, error) {
+	if m := os.Getenv(config.PortablePathsEnv); CheckSetting(SettingPortablePaths, m) == nil {
+		return m, nil
+	}
+	s, err := fc.LoadSettings()
+	if err != nil {
+		return "", err
+	}
+	if m := s[SettingPortablePaths]; CheckSetting(SettingPortablePaths, m) == nil {
+		return m, nil
+	}
+	return PortableWarn, nil
+}
@@ -0,0 +1,57 @@
+package file
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckPortablePaths(t *testing.T) {
+	paths := []string{
+		"README.md",
+		"readme.md",
+		"Docs/a.txt",
+		"docs/b.txt", // reported once, for the directory
+		"docs/c.txt",
+		"caf\u00e9.txt",
+		"cafe\u0301.txt",
+		"\ud55c.txt",
+		"\u1112\u1161\u11ab.txt", // the same Hangul syllable as conjoining jamo
+		"a:b.txt",
+		"notes.",
+		"trailing /x",
+		"aux.c",
+		"con/readme",
+		"console.txt",
+		"ok/file.txt",
+	}
+	want := []PathProblem{
+		{Kind: PathInvalidChar, Path: "a:b.txt"},
+		{Kind: PathReservedName, Path: "aux.c"},
+		{Kind: PathUnicodeCollision, Path: "caf\u00e9.txt", Other: "cafe\u0301.txt"},
+		{Kind: PathReservedName, Path: "con"},
+		{Kind: PathCaseCollision, Path: "docs", Other: "Docs"},
+		{Kind: PathTrailingDot, Path: "notes."},
+		{Kind: PathCaseCollision, Path: "readme.md", Other: "README.md"},
+		{Kind: PathTrailingDot, Path: "trailing "},
+		{Kind: PathUnicodeCollision, Path: "\ud55c.txt", Other: "\u1112\u1161\u11ab.txt"},
+	}
+	if got := CheckPortablePaths(paths); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckPortablePaths =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFilterPathProblems(t *testing.T) {
+	problems := CheckPortablePaths([]string{"README.md", "readme.md", "Docs/a", "docs/b", "aux.c"})
+
+	got := FilterPathProblems(problems, []string{"docs/b", "README.md"})
+	want := []PathProblem{
+		{Kind: PathCaseCollision, Path: "docs", Other: "Docs"},
+		{Kind: PathCaseCollision, Path: "readme.md", Other: "README.md"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterPathProblems = %v, want %v", got, want)
+	}
+	if got := FilterPathProblems(problems, []string{"other.txt"}); len(got) != 0 {
+		t.Errorf("expected no problems for an unrelated path, got %v", got)
+	}
+}
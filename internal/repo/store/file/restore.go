@@ -5,55 +5,122 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/keshon/bvc/internal/progress"
 )
 
-// Restore rebuilds files from entries (e.g., from a snapshot).
+// Restore rebuilds files from entries (e.g., from a snapshot). Existing
+// files are overwritten and nothing is deleted; use Checkout to move the
+// working tree between trees. Entries excluded by the sparse checkout
+// patterns are not written.
 func (fc *FileContext) RestoreFilesToWorkingTree(entries []Entry, label string) error {
 	if fc.BlockCtx == nil {
 		return fmt.Errorf("no BlockContext attached")
 	}
 
+	if err := fc.recoverPatch(); err != nil {
+		return err
+	}
+
 	exe := filepath.Base(os.Args[0])
 	bar := progress.NewProgress(len(entries), fmt.Sprintf("Restoring %s", label))
 	defer bar.Finish()
 
-	// Build valid file map from Fileset entries
-	valid := make(map[string]bool, len(entries))
-	for _, e := range entries {
-		valid[filepath.Clean(e.Path)] = true
-	}
-
-	// Include staged files so we don't delete them
-	staged, err := fc.LoadIndex()
+	sparse, err := fc.LoadSparse()
 	if err != nil {
-		return fmt.Errorf("failed to load index: %w", err) //fmt.Printf("\nWarning: %v\n", err)
-	}
-	for _, s := range staged {
-		valid[filepath.Clean(s.Path)] = true
+		return err
 	}
 
-	// Restore Fileset entries first
+	cache := fc.LoadStatCache()
 	for _, e := range entries {
-		if filepath.Base(e.Path) == exe {
+		if filepath.Base(e.Path) == exe || !sparse.Includes(e.Path) {
+			bar.Increment()
 			continue
 		}
-		if err := fc.restoreFile(e); err != nil {
+		if err := fc.restoreOrPatch(e, cache); err != nil {
 			return fmt.Errorf("restoring file %s: %w", e.Path, err) //fmt.Printf("\nWarning: %v\n", err)
 		}
 		bar.Increment()
 	}
+	return fc.SaveStatCache(cache)
+}
+
+// RemoveFromWorkingTree deletes tracked paths from the working tree, and
+// the directories left empty above them. Paths already gone are skipped.
+func (fc *FileContext) RemoveFromWorkingTree(paths []string) error {
+	for _, p := range paths {
+		abs := filepath.Join(fc.WorkingTreeDir, p)
+		if err := fc.FS.Remove(abs); err != nil {
+			if fc.FS.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+		fc.removeEmptyParents(abs)
+	}
+	return nil
+}
 
-	// Now prune untracked files safely
+// MoveInWorkingTree renames a file or directory below the working tree
+// root, creating the parents of the destination and removing the
+// directories left empty behind it.
+func (fc *FileContext) MoveInWorkingTree(from, to string) error {
+	src := filepath.Join(fc.WorkingTreeDir, from)
+	dst := filepath.Join(fc.WorkingTreeDir, to)
+	if err := fc.FS.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", to, err)
+	}
+	if err := fc.FS.Rename(src, dst); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", from, to, err)
+	}
+	fc.removeEmptyParents(src)
 	return nil
 }
 
+// restoreOrPatch writes an entry below the working tree root. An existing
+// file with the same content is left alone, and one that differs in a few
+// blocks is patched in place.
+func (fc *FileContext) restoreOrPatch(e Entry, cache *StatCache) error {
+	if !filepath.IsAbs(e.Path) {
+		e.Path = filepath.Join(fc.WorkingTreeDir, e.Path)
+	}
+	if err := fc.checkParents(e.Path); err != nil {
+		return err
+	}
+	if info, err := fc.FS.Lstat(e.Path); err == nil && info.Mode().IsRegular() && e.Mode.IsRegular() {
+		if cur, err := fc.buildEntry(e.Path, cache); err == nil {
+			if cur.Equal(&e) {
+				return fc.applyMetadata(e)
+			}
+			if patched, err := fc.patchFile(e, cur.Blocks); err != nil || patched {
+				return err
+			}
+		}
+	}
+	return fc.restoreFile(e)
+}
+
 func (fc *FileContext) restoreFile(e Entry) error {
+	if err := fc.checkParents(e.Path); err != nil {
+		return err
+	}
+	if e.IsDir() {
+		return fc.FS.MkdirAll(e.Path, dirPerm(e.Mode))
+	}
+
 	if err := fc.FS.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
 		return err
 	}
 
+	if e.IsSymlink() {
+		if err := fc.FS.Remove(e.Path); err != nil && !fc.FS.IsNotExist(err) {
+			return err
+		}
+		return fc.FS.Symlink(filepath.FromSlash(e.Link), e.Path)
+	}
+
 	tmp, tmpPath, err := fc.FS.CreateTempFile(filepath.Dir(e.Path), "tmp-*")
 	if err != nil {
 		return err
@@ -80,5 +147,55 @@ func (fc *FileContext) restoreFile(e Entry) error {
 	}
 
 	// Rename temp file to final path
-	return fc.FS.Rename(tmpPath, e.Path)
+	if err := fc.FS.Rename(tmpPath, e.Path); err != nil {
+		return err
+	}
+	return fc.applyMetadata(e)
+}
+
+// checkParents refuses a path outside the working tree, or one below a
+// symbolic link in it: a tree holding a link and a file under it would
+// otherwise write the file wherever the link points.
+func (fc *FileContext) checkParents(abs string) error {
+	rel, err := filepath.Rel(fc.WorkingTreeDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to write %s outside the working tree", abs)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		info, err := fc.FS.Lstat(filepath.Join(fc.WorkingTreeDir, dir))
+		if err != nil {
+			return nil // created as a directory when written
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write %s: %s is a symbolic link", filepath.ToSlash(rel), dir)
+		}
+	}
+	return nil
+}
+
+// applyMetadata sets the recorded permissions and modification time on a
+// restored file. Entries recorded without metadata keep the defaults.
+func (fc *FileContext) applyMetadata(e Entry) error {
+	if e.Mode != 0 {
+		if err := fc.FS.Chmod(e.Path, e.Mode.Perm()); err != nil {
+			return fmt.Errorf("chmod: %w", err)
+		}
+	}
+	if e.ModTime != 0 {
+		mtime := time.Unix(0, e.ModTime)
+		if err := fc.FS.Chtimes(e.Path, mtime, mtime); err != nil {
+			return fmt.Errorf("chtimes: %w", err)
+		}
+	}
+	return nil
+}
+
+// dirPerm returns the permissions for a restored directory.
+func dirPerm(mode os.FileMode) os.FileMode {
+	if perm := mode.Perm(); perm != 0 {
+		return perm
+	}
+	return 0o755
 }
@@ -1,8 +1,10 @@
 package file_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/keshon/bvc/internal/repo/store/block"
 	"github.com/keshon/bvc/internal/repo/store/file"
@@ -40,3 +42,106 @@ func TestRestoreFiles(t *testing.T) {
 		t.Errorf("unexpected file content %q", data)
 	}
 }
+
+func TestRestoreFiles_Metadata(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	blockData := []byte("#!/bin/sh\n")
+	if err := fc.FS.WriteFile(filepath.Join(fc.BlockCtx.BlocksDir(), "hash1.bin"), blockData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	script := filepath.Join(tmpDir, "tool.sh")
+	link := filepath.Join(tmpDir, "latest.sh")
+	dir := filepath.Join(tmpDir, "empty", "dir")
+	entries := []file.Entry{
+		{
+			Path:    script,
+			Blocks:  []block.BlockRef{{Hash: "hash1", Size: int64(len(blockData))}},
+			Mode:    0o755,
+			ModTime: mtime.UnixNano(),
+			Size:    int64(len(blockData)),
+		},
+		{Path: link, Mode: os.ModeSymlink | 0o777, Link: "tool.sh"},
+		{Path: dir, Mode: os.ModeDir | 0o755},
+	}
+
+	if err := fc.RestoreFilesToWorkingTree(entries, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fc.FS.Stat(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("expected mtime %v, got %v", mtime, info.ModTime())
+	}
+
+	if target, err := fc.FS.Readlink(link); err != nil || target != "tool.sh" {
+		t.Errorf("expected link to tool.sh, got %q (%v)", target, err)
+	}
+	if !fc.FS.IsDir(dir) {
+		t.Error("expected empty directory to be restored")
+	}
+
+	// entries built back from the restored tree carry the same metadata
+	built, err := fc.BuildEntry(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !built.IsSymlink() || !built.Equal(&entries[1]) {
+		t.Errorf("rebuilt link entry differs: %+v", built)
+	}
+}
+
+func TestRemoveFromWorkingTree(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	for _, p := range []string{"a/b/c.txt", "a/keep.txt"} {
+		abs := filepath.Join(tmpDir, p)
+		if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.FS.WriteFile(abs, []byte(p), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fc.RemoveFromWorkingTree([]string{"a/b/c.txt", "missing.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Exists(filepath.Join(tmpDir, "a/b/c.txt")) || fc.Exists(filepath.Join(tmpDir, "a/b")) {
+		t.Fatal("removed file or its emptied directory is still there")
+	}
+	if !fc.Exists(filepath.Join(tmpDir, "a/keep.txt")) {
+		t.Fatal("directory with other files was removed")
+	}
+}
+
+func TestMoveInWorkingTree(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	abs := filepath.Join(tmpDir, "a/b/c.txt")
+	if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.FS.WriteFile(abs, []byte("c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fc.MoveInWorkingTree("a/b/c.txt", "d/e/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fc.FS.ReadFile(filepath.Join(tmpDir, "d/e/c.txt"))
+	if err != nil || string(data) != "c" {
+		t.Fatalf("moved file = %q, %v", data, err)
+	}
+	if fc.Exists(filepath.Join(tmpDir, "a")) {
+		t.Fatal("emptied source directory is still there")
+	}
+}
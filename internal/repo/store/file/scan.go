@@ -1,37 +1,92 @@
 package file
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/config"
+)
+
+// How symbolic links are tracked, from $BVC_SYMLINKS.
+const (
+	SymlinksRecord = "record" // store the link itself (default)
+	SymlinksFollow = "follow" // store what the link points to
 )
 
+// maxLinkHops bounds how many links are followed, one after another or
+// nested, before a path is taken for a loop.
+const maxLinkHops = 40
+
+// specialMode covers the file types that have no content to store.
+const specialMode = os.ModeNamedPipe | os.ModeSocket | os.ModeDevice | os.ModeCharDevice | os.ModeIrregular
+
+// SymlinksMode returns how symbolic links are tracked.
+func SymlinksMode() string {
+	if os.Getenv(config.SymlinksEnv) == SymlinksFollow {
+		return SymlinksFollow
+	}
+	return SymlinksRecord
+}
+
 // ScanAllRepository returns slices of tracked, staged, and ignored files
 // using the FS abstraction. Fully compatible with MemoryFS or OS FS.
+// Empty directories are reported like files so they can be recorded.
 // - tracked: files not ignored and not internal
-// - staged: files already present in index.json
-// - ignored: files matched by .bvc-ignore or defaults
+// - staged: files with a staged change in the index
+// - ignored: files matched by .bvc-ignore files, the global excludes file or defaults
+//
+// Symbolic links are reported as files and recorded as links, unless
+// SymlinksMode is "follow": then links to directories are walked into, and
+// links that loop back to a directory above them are skipped with a warning.
+// FIFOs, sockets and devices are skipped with a warning.
 func (fc *FileContext) ScanAllRepository() (tracked []string, staged []string, ignored []string, err error) {
+	if fc.FS == nil {
+		return nil, nil, nil, fmt.Errorf("no filesystem attached")
+	}
 	exe, _ := os.Executable() // skip current binary
 	matcher := NewIgnore(fc.WorkingTreeDir, fc.FS)
+	follow := SymlinksMode() == SymlinksFollow
+	repoDir, _ := filepath.Abs(fc.RepoDir)
 
 	// Load staged entries (index)
 	indexEntries, _ := fc.LoadIndex()
 	indexSet := make(map[string]struct{}, len(indexEntries))
 	for _, e := range indexEntries {
+		if e.Deleted {
+			continue
+		}
 		indexSet[filepath.ToSlash(filepath.Clean(e.Path))] = struct{}{}
 	}
 
-	var walk func(path string) error
-	walk = func(path string) error {
-		entries, err := fc.FS.ReadDir(path)
+	classify := func(p, relPath string, isDir bool) {
+		if matcher.Ignored(relPath, isDir) {
+			ignored = append(ignored, p)
+		} else if _, ok := indexSet[relPath]; ok {
+			staged = append(staged, p)
+		} else {
+			tracked = append(tracked, p)
+		}
+	}
+
+	// path is where a directory appears in the working tree, real where it
+	// actually is once followed links are resolved
+	var walk func(path, real string, hops int) error
+	walk = func(path, real string, hops int) error {
+		entries, err := fc.FS.ReadDir(real)
 		if err != nil {
 			return err
 		}
 
 		for _, e := range entries {
 			p := filepath.Join(path, e.Name())
-			info, _ := e.Info()
+			childReal := filepath.Join(real, e.Name())
+			info, err := fc.FS.Lstat(childReal)
+			if err != nil {
+				continue // removed while scanning
+			}
 
 			// Skip internal repo directory completely
 			if info.IsDir() && filepath.Clean(p) == filepath.Clean(fc.RepoDir) {
@@ -45,15 +100,54 @@ func (fc *FileContext) ScanAllRepository() (tracked []string, staged []string, i
 			}
 			relPath = filepath.ToSlash(relPath)
 
+			if info.Mode()&specialMode != 0 {
+				if !matcher.Ignored(relPath, false) {
+					fmt.Fprintf(os.Stderr, "warning: skipping special file %s (%s)\n", relPath, fileKind(info.Mode()))
+				}
+				continue
+			}
+
+			isDir := info.IsDir()
+			if follow && info.Mode()&os.ModeSymlink != 0 {
+				target, err := fc.resolveLink(childReal)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: skipping symlink %s: %v\n", relPath, err)
+					continue
+				}
+				tinfo, err := fc.FS.Stat(target)
+				switch {
+				case err != nil:
+					// a dangling link is recorded as a link
+				case tinfo.Mode()&specialMode != 0:
+					fmt.Fprintf(os.Stderr, "warning: skipping symlink %s to special file (%s)\n", relPath, fileKind(tinfo.Mode()))
+					continue
+				case tinfo.IsDir():
+					if within(target, repoDir) {
+						continue // never walk into the repository
+					}
+					if within(real, target) || hops >= maxLinkHops {
+						fmt.Fprintf(os.Stderr, "warning: skipping symlink loop %s -> %s\n", relPath, target)
+						continue
+					}
+					isDir = true
+					childReal = target
+					hops++
+				}
+			}
+
 			// Skip ignored dirs entirely
-			if info.IsDir() && matcher.Match(relPath) {
+			if isDir && matcher.Ignored(relPath, true) {
 				ignored = append(ignored, p)
 				continue
 			}
 
-			// Recurse into directories
-			if info.IsDir() {
-				if err := walk(p); err != nil {
+			// Recurse into directories; empty ones are entries of their own
+			if isDir {
+				if children, err := fc.FS.ReadDir(childReal); err == nil && len(children) == 0 {
+					classify(p, relPath, true)
+					continue
+				}
+				if err := walk(p, childReal, hops); err != nil {
 					return err
 				}
 				continue
@@ -65,18 +159,12 @@ func (fc *FileContext) ScanAllRepository() (tracked []string, staged []string, i
 			}
 
 			// Decide where to put file
-			if matcher.Match(relPath) {
-				ignored = append(ignored, p)
-			} else if _, ok := indexSet[relPath]; ok {
-				staged = append(staged, p)
-			} else {
-				tracked = append(tracked, p)
-			}
+			classify(p, relPath, false)
 		}
 		return nil
 	}
 
-	if err := walk(fc.WorkingTreeDir); err != nil {
+	if err := walk(fc.WorkingTreeDir, fc.WorkingTreeDir, 0); err != nil {
 		return nil, nil, nil, err
 	}
 
@@ -87,3 +175,44 @@ func (fc *FileContext) ScanAllRepository() (tracked []string, staged []string, i
 
 	return tracked, staged, ignored, nil
 }
+
+// resolveLink follows a chain of symbolic links to the path it ends at.
+// Relative targets are resolved against the directory of the link.
+func (fc *FileContext) resolveLink(p string) (string, error) {
+	for i := 0; i < maxLinkHops; i++ {
+		info, err := fc.FS.Lstat(p)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return p, nil
+		}
+		target, err := fc.FS.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(p), target)
+		}
+		p = filepath.Clean(target)
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
+
+// within reports whether p is dir or lies below it.
+func within(p, dir string) bool {
+	p, dir = filepath.ToSlash(filepath.Clean(p)), filepath.ToSlash(filepath.Clean(dir))
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// fileKind names the type of a special file.
+func fileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "irregular file"
+}
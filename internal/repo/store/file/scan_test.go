@@ -2,7 +2,11 @@ package file_test
 
 import (
 	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/repo/store/file"
 )
 
 func TestScanFiles(t *testing.T) {
@@ -66,3 +70,47 @@ func TestScanNestedDirs(t *testing.T) {
 		t.Errorf("expected 2 tracked files, got %d", len(tracked))
 	}
 }
+
+// scanRel scans the working tree and returns the tracked paths relative to it.
+func scanRel(t *testing.T, fc *file.FileContext, root string) []string {
+	t.Helper()
+	tracked, _, _, err := fc.ScanAllRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, p := range tracked {
+		r, _ := filepath.Rel(root, p)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestScanSymlinks(t *testing.T) {
+	fc, root := newTestFC(t)
+	fc.FS.MkdirAll(filepath.Join(root, "real/sub"), 0o755)
+	fc.FS.WriteFile(filepath.Join(root, "real/sub/a.txt"), []byte("a"), 0o644)
+	fc.FS.Symlink("real", filepath.Join(root, "linkdir"))
+	fc.FS.Symlink("..", filepath.Join(root, "real/up")) // loops back to the root
+	fc.FS.Symlink("loop2", filepath.Join(root, "loop1"))
+	fc.FS.Symlink("loop1", filepath.Join(root, "loop2"))
+
+	// links are recorded as they are by default
+	got := scanRel(t, fc, root)
+	want := []string{"linkdir", "loop1", "loop2", "real/sub/a.txt", "real/up"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("record: tracked = %v, want %v", got, want)
+	}
+	e, err := fc.BuildEntry(filepath.Join(root, "linkdir"))
+	if err != nil || !e.IsSymlink() || e.Link != "real" {
+		t.Errorf("expected linkdir recorded as a link, got %+v (%v)", e, err)
+	}
+
+	// followed links are walked into; loops are skipped
+	t.Setenv(config.SymlinksEnv, file.SymlinksFollow)
+	got = scanRel(t, fc, root)
+	want = []string{"linkdir/sub/a.txt", "real/sub/a.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("follow: tracked = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package file_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func TestScanSkipsSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	osfs := fs.NewOSFS()
+	repoRoot := filepath.Join(root, ".bvc")
+	osfs.MkdirAll(filepath.Join(repoRoot, "blocks"), 0o755)
+	fc := file.NewFileContext(root, repoRoot, block.NewBlockContext(filepath.Join(repoRoot, "blocks"), osfs), osfs)
+
+	osfs.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644)
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe"), 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	osfs.Symlink("pipe", filepath.Join(root, "pipelink"))
+
+	if got := scanRel(t, fc, root); !reflect.DeepEqual(got, []string{"a.txt", "pipelink"}) {
+		t.Errorf("tracked = %v", got)
+	}
+
+	// building an entry for a FIFO must not read from it
+	done := make(chan file.Entry, 1)
+	go func() {
+		e, _ := fc.BuildEntry(filepath.Join(root, "pipe"))
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		if len(e.Blocks) != 0 {
+			t.Errorf("expected no blocks for a FIFO, got %v", e.Blocks)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("BuildEntry blocked on a FIFO")
+	}
+}
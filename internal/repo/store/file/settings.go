@@ -0,0 +1,96 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo/store/codec"
+)
+
+// settingsFile holds the repository settings, one "key = value" per line.
+const settingsFile = "config"
+
+// Known settings.
+const (
+	SettingPortablePaths = "portable-paths" // off, warn or refuse non-portable paths in add and commit
+)
+
+// settingValues lists the values each known setting accepts.
+var settingValues = map[string][]string{
+	SettingPortablePaths: {PortableOff, PortableWarn, PortableRefuse},
+}
+
+// Settings are the repository settings by key. Lines starting with "#" and
+// blank lines in the file are ignored.
+//
+//	portable-paths = refuse
+type Settings map[string]string
+
+// SettingKeys returns the known setting keys, sorted.
+func SettingKeys() []string {
+	keys := make([]string, 0, len(settingValues))
+	for k := range settingValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckSetting reports an unknown key, or a value the key does not accept.
+func CheckSetting(key, value string) error {
+	values, ok := settingValues[key]
+	if !ok {
+		return fmt.Errorf("unknown setting '%s' (known: %s)", key, strings.Join(SettingKeys(), ", "))
+	}
+	if !slices.Contains(values, value) {
+		return fmt.Errorf("invalid value '%s' for %s (one of: %s)", value, key, strings.Join(values, ", "))
+	}
+	return nil
+}
+
+// LoadSettings reads the repository settings. A missing file holds none.
+func (fc *FileContext) LoadSettings() (Settings, error) {
+	s := Settings{}
+	data, err := fc.FS.ReadFile(filepath.Join(fc.RepoDir, settingsFile))
+	if err != nil {
+		if fc.FS.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("settings line %d: expected key = value", n)
+		}
+		s[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return s, nil
+}
+
+// SaveSettings replaces the repository settings, sorted by key.
+func (fc *FileContext) SaveSettings(s Settings) error {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", k, s[k])
+	}
+	if err := codec.WriteFile(fc.FS, filepath.Join(fc.RepoDir, settingsFile), []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package file_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func newSettingsContext(t *testing.T) (*file.FileContext, fs.FS, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	mem := fs.NewMemoryFS()
+	repoDir := filepath.Join(tmpDir, ".bvc")
+	mem.MkdirAll(repoDir, 0o755)
+	return file.NewFileContext(tmpDir, repoDir, newMockBlock(), mem), mem, repoDir
+}
+
+func TestSettings_LoadSave(t *testing.T) {
+	fc, mem, repoDir := newSettingsContext(t)
+
+	s, err := fc.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 0 {
+		t.Fatalf("expected no settings without a file, got %v", s)
+	}
+
+	want := file.Settings{file.SettingPortablePaths: file.PortableRefuse}
+	if err := fc.SaveSettings(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fc.LoadSettings(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadSettings = %v, %v, want %v", got, err, want)
+	}
+
+	mem.WriteFile(filepath.Join(repoDir, "config"), []byte("# comment\n\n portable-paths=off \n"), 0o644)
+	if got, err := fc.LoadSettings(); err != nil || got[file.SettingPortablePaths] != file.PortableOff {
+		t.Fatalf("LoadSettings = %v, %v, want portable-paths = off", got, err)
+	}
+
+	mem.WriteFile(filepath.Join(repoDir, "config"), []byte("portable-paths\n"), 0o644)
+	if _, err := fc.LoadSettings(); err == nil {
+		t.Fatal("expected an error for a line without '='")
+	}
+}
+
+func TestCheckSetting(t *testing.T) {
+	if err := file.CheckSetting(file.SettingPortablePaths, file.PortableWarn); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := file.CheckSetting(file.SettingPortablePaths, "sometimes"); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+	if err := file.CheckSetting("no-such-key", "on"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestPortablePathsMode(t *testing.T) {
+	fc, _, _ := newSettingsContext(t)
+	t.Setenv(config.PortablePathsEnv, "")
+
+	mode := func() string {
+		t.Helper()
+		m, err := fc.PortablePathsMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if m := mode(); m != file.PortableWarn {
+		t.Errorf("default mode = %q, want %q", m, file.PortableWarn)
+	}
+	if err := fc.SaveSettings(file.Settings{file.SettingPortablePaths: file.PortableRefuse}); err != nil {
+		t.Fatal(err)
+	}
+	if m := mode(); m != file.PortableRefuse {
+		t.Errorf("mode from setting = %q, want %q", m, file.PortableRefuse)
+	}
+	t.Setenv(config.PortablePathsEnv, file.PortableOff)
+	if m := mode(); m != file.PortableOff {
+		t.Errorf("mode from environment = %q, want %q", m, file.PortableOff)
+	}
+	t.Setenv(config.PortablePathsEnv, "bogus")
+	if m := mode(); m != file.PortableRefuse {
+		t.Errorf("mode with an invalid environment value = %q, want %q", m, file.PortableRefuse)
+	}
+}
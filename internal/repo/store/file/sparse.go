@@ -0,0 +1,172 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo/store/codec"
+)
+
+// sparseFile lists the sparse checkout patterns, one per line.
+const sparseFile = "sparse-checkout"
+
+// Sparse selects which tracked paths are materialized in the working tree.
+// Patterns use .bvc-ignore syntax: a path is checked out when the last
+// pattern matching it or one of its parent directories is not a "!"
+// negation. Without patterns sparse checkout is disabled and every path is
+// checked out.
+//
+//	/*
+//	!/audio/
+//	/audio/previews/
+type Sparse struct {
+	rules []Rule
+}
+
+// NewSparse parses sparse checkout patterns.
+func NewSparse(patterns []string) *Sparse {
+	s := &Sparse{}
+	for i, p := range patterns {
+		if r, ok := parseRule(p, "", sparseFile, i+1); ok {
+			s.rules = append(s.rules, r)
+		}
+	}
+	return s
+}
+
+// Enabled reports whether any sparse checkout pattern is set.
+func (s *Sparse) Enabled() bool { return s != nil && len(s.rules) > 0 }
+
+// Patterns returns the patterns as written.
+func (s *Sparse) Patterns() []string {
+	if s == nil {
+		return nil
+	}
+	out := make([]string, len(s.rules))
+	for i, r := range s.rules {
+		out[i] = r.Pattern
+	}
+	return out
+}
+
+// Includes reports whether a repository-relative path is checked out.
+func (s *Sparse) Includes(rel string) bool {
+	if !s.Enabled() {
+		return true
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	included := false
+	for i := range s.rules {
+		if s.rules[i].matchesPath(rel) {
+			included = !s.rules[i].negate
+		}
+	}
+	return included
+}
+
+// LoadSparse reads the sparse checkout patterns. A missing file means sparse
+// checkout is disabled.
+func (fc *FileContext) LoadSparse() (*Sparse, error) {
+	data, err := fc.FS.ReadFile(filepath.Join(fc.RepoDir, sparseFile))
+	if err != nil {
+		if fc.FS.IsNotExist(err) {
+			return &Sparse{}, nil
+		}
+		return nil, fmt.Errorf("failed to read sparse checkout patterns: %w", err)
+	}
+	var patterns []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		patterns = append(patterns, sc.Text())
+	}
+	return NewSparse(patterns), nil
+}
+
+// SaveSparse replaces the sparse checkout patterns. No patterns disable
+// sparse checkout.
+func (fc *FileContext) SaveSparse(patterns []string) error {
+	path := filepath.Join(fc.RepoDir, sparseFile)
+	if len(patterns) == 0 {
+		if err := fc.FS.Remove(path); err != nil && !fc.FS.IsNotExist(err) {
+			return fmt.Errorf("failed to remove sparse checkout patterns: %w", err)
+		}
+		return nil
+	}
+	data := strings.Join(patterns, "\n") + "\n"
+	if err := codec.WriteFile(fc.FS, path, []byte(data)); err != nil {
+		return fmt.Errorf("failed to write sparse checkout patterns: %w", err)
+	}
+	return nil
+}
+
+// SparseResult reports what ApplySparse changed in the working tree.
+type SparseResult struct {
+	Restored int
+	Removed  int
+	Kept     []string // excluded paths left in place because they have local changes
+}
+
+// ApplySparse brings the working tree in line with the sparse checkout
+// patterns: entries of the given tree that are included but missing are
+// restored, and excluded ones are removed unless they differ from the entry.
+func (fc *FileContext) ApplySparse(entries []Entry) (SparseResult, error) {
+	var res SparseResult
+	sparse, err := fc.LoadSparse()
+	if err != nil {
+		return res, err
+	}
+
+	for _, e := range entries {
+		abs := filepath.Join(fc.WorkingTreeDir, e.Path)
+		_, err := fc.FS.Lstat(abs)
+		present := err == nil
+
+		switch included := sparse.Includes(e.Path); {
+		case included && !present:
+			at := e
+			at.Path = abs
+			if err := fc.restoreFile(at); err != nil {
+				return res, fmt.Errorf("restoring file %s: %w", e.Path, err)
+			}
+			res.Restored++
+
+		case !included && present:
+			if !e.IsDir() {
+				cur, err := fc.BuildEntry(abs)
+				if err != nil || !cur.Equal(&e) {
+					res.Kept = append(res.Kept, e.Path)
+					continue
+				}
+			}
+			if err := fc.FS.Remove(abs); err != nil {
+				if e.IsDir() {
+					continue // no longer empty
+				}
+				return res, fmt.Errorf("failed to remove %s: %w", e.Path, err)
+			}
+			fc.removeEmptyParents(abs)
+			res.Removed++
+		}
+	}
+	sort.Strings(res.Kept)
+	return res, nil
+}
+
+// removeEmptyParents removes the directories above path that became empty,
+// up to the working tree root.
+func (fc *FileContext) removeEmptyParents(path string) {
+	root := filepath.Clean(fc.WorkingTreeDir)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		children, err := fc.FS.ReadDir(dir)
+		if err != nil || len(children) > 0 {
+			return
+		}
+		if err := fc.FS.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package file_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+func TestSparse_Includes(t *testing.T) {
+	s := file.NewSparse([]string{"/*", "!/audio/", "/audio/previews/", "# comment"})
+	cases := map[string]bool{
+		"README":                 true,
+		"src/main.go":            true,
+		"audio/raw/take1.wav":    false,
+		"audio/previews/a.mp3":   true,
+		"audio/previews/x/b.ogg": true,
+	}
+	for p, want := range cases {
+		if got := s.Includes(p); got != want {
+			t.Errorf("Includes(%q) = %v, want %v", p, got, want)
+		}
+	}
+	if !reflect.DeepEqual(s.Patterns(), []string{"/*", "!/audio/", "/audio/previews/"}) {
+		t.Errorf("unexpected patterns %v", s.Patterns())
+	}
+
+	if off := file.NewSparse(nil); off.Enabled() || !off.Includes("audio/raw/take1.wav") {
+		t.Errorf("disabled sparse checkout should include everything")
+	}
+}
+
+func TestApplySparse(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+
+	files := map[string]string{"keep/a.txt": "a", "drop/b.txt": "b", "drop/c.txt": "c"}
+	var entries []file.Entry
+	for p, content := range files {
+		abs := filepath.Join(tmpDir, p)
+		if err := fc.FS.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.FS.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		e, err := fc.BuildEntry(abs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.BlockCtx.Write(abs, e.Blocks); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	// a local change keeps drop/c.txt in place
+	if err := fc.FS.WriteFile(filepath.Join(tmpDir, "drop/c.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fc.SaveSparse([]string{"/keep/"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := fc.ApplySparse(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 1 || res.Restored != 0 || !reflect.DeepEqual(res.Kept, []string{"drop/c.txt"}) {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if fc.Exists(filepath.Join(tmpDir, "drop/b.txt")) {
+		t.Errorf("drop/b.txt should be removed")
+	}
+
+	// status and add must not see drop/b.txt as deleted
+	sparse, err := fc.LoadSparse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparse.Includes("drop/b.txt") || !sparse.Includes("keep/a.txt") {
+		t.Errorf("unexpected patterns after reload: %v", sparse.Patterns())
+	}
+
+	if err := fc.SaveSparse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = fc.ApplySparse(entries); err != nil {
+		t.Fatal(err)
+	}
+	if res.Restored != 1 {
+		t.Errorf("expected drop/b.txt to be restored, got %+v", res)
+	}
+	if data, _ := fc.FS.ReadFile(filepath.Join(tmpDir, "drop/b.txt")); string(data) != "b" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
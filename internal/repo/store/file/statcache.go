@@ -0,0 +1,184 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/codec"
+	"github.com/keshon/bvc/internal/util"
+)
+
+// statCacheFile remembers the block lists of working tree files between runs,
+// keyed by what a stat call reports and the chunk policy the blocks were cut
+// with, so unchanged files are not re-chunked.
+const (
+	statCacheFile    = "statcache"
+	statCacheMagic   = "BVCS"
+	statCacheVersion = 2
+)
+
+// racyWindow is how close to the moment it was chunked a file may have been
+// modified and still be cached. A write landing within the filesystem's
+// timestamp granularity after chunking could leave size and mtime unchanged,
+// so such files are re-chunked next time instead.
+const racyWindow = 2 * time.Second
+
+// statRecord is a cached chunking result for one file.
+type statRecord struct {
+	Size     int64
+	ModTime  int64
+	Inode    uint64
+	Mode     os.FileMode
+	MinChunk int // chunk policy the blocks were cut with
+	MaxChunk int
+	Blocks   []block.BlockRef
+}
+
+// StatCache maps repository-relative paths to the blocks their content was
+// split into, valid as long as the file's stat data is unchanged.
+type StatCache struct {
+	mu      sync.Mutex
+	records map[string]statRecord
+	dirty   bool
+}
+
+// matches reports whether a record still describes the file behind info,
+// split with policy. Blocks cut with another chunk size, e.g. after a chunk=
+// attribute changed, would not match the blocks a fresh split stores.
+func (r *statRecord) matches(info os.FileInfo, policy block.Policy) bool {
+	return r.Size == info.Size() &&
+		r.ModTime == info.ModTime().UnixNano() &&
+		r.Mode == info.Mode() &&
+		r.Inode == inodeOf(info) &&
+		r.MinChunk == policy.MinChunk &&
+		r.MaxChunk == policy.MaxChunk
+}
+
+// lookup returns the cached blocks of path if its stat data and chunk policy
+// are unchanged.
+func (c *StatCache) lookup(path string, info os.FileInfo, policy block.Policy) ([]block.BlockRef, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.records[path]
+	if !ok || !r.matches(info, policy) {
+		return nil, false
+	}
+	return r.Blocks, true
+}
+
+// store records the blocks of path, chunked with policy at hashedAt. Racily
+// clean files are dropped from the cache rather than recorded.
+func (c *StatCache) store(path string, info os.FileInfo, policy block.Policy, blocks []block.BlockRef, hashedAt time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !info.ModTime().Before(hashedAt.Add(-racyWindow)) {
+		if _, ok := c.records[path]; ok {
+			delete(c.records, path)
+			c.dirty = true
+		}
+		return
+	}
+	c.records[path] = statRecord{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Inode:    inodeOf(info),
+		Mode:     info.Mode(),
+		MinChunk: policy.MinChunk,
+		MaxChunk: policy.MaxChunk,
+		Blocks:   blocks,
+	}
+	c.dirty = true
+}
+
+// encode serializes the cache records sorted by path.
+func (c *StatCache) encode() []byte {
+	w := codec.NewWriter(statCacheMagic, statCacheVersion)
+	paths := util.SortedKeys(c.records)
+	w.Uvarint(uint64(len(paths)))
+	for _, p := range paths {
+		r := c.records[p]
+		w.String(p)
+		w.Varint(r.Size)
+		w.Varint(r.ModTime)
+		w.Uvarint(r.Inode)
+		w.Uvarint(uint64(r.Mode))
+		w.Uvarint(uint64(r.MinChunk))
+		w.Uvarint(uint64(r.MaxChunk))
+		w.Blocks(r.Blocks)
+	}
+	return w.Bytes()
+}
+
+// decodeStatCache parses cache records written by encode.
+func decodeStatCache(data []byte) (map[string]statRecord, error) {
+	r, err := codec.Decode(data, statCacheMagic, statCacheVersion)
+	if err != nil {
+		return nil, err
+	}
+	n := r.Count()
+	records := make(map[string]statRecord, n)
+	for i := 0; i < n && r.Err() == nil; i++ {
+		p := r.String()
+		records[p] = statRecord{
+			Size:     r.Varint(),
+			ModTime:  r.Varint(),
+			Inode:    r.Uvarint(),
+			Mode:     os.FileMode(r.Uvarint()),
+			MinChunk: int(r.Uvarint()),
+			MaxChunk: int(r.Uvarint()),
+			Blocks:   r.Blocks(),
+		}
+	}
+	return records, r.Err()
+}
+
+// LoadStatCache reads the stat cache. A missing or unreadable cache is
+// treated as empty; it only ever saves work.
+func (fc *FileContext) LoadStatCache() *StatCache {
+	c := &StatCache{records: map[string]statRecord{}}
+	if fc.RepoDir == "" || fc.FS == nil {
+		return c
+	}
+	data, err := fc.FS.ReadFile(filepath.Join(fc.RepoDir, statCacheFile))
+	if err != nil {
+		return c
+	}
+	if records, err := decodeStatCache(data); err == nil {
+		c.records = records
+	}
+	return c
+}
+
+// SaveStatCache writes the stat cache if it changed, dropping records of
+// files that no longer exist.
+func (fc *FileContext) SaveStatCache(c *StatCache) error {
+	if c == nil || fc.RepoDir == "" || fc.FS == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.records {
+		if _, err := fc.FS.Lstat(filepath.Join(fc.WorkingTreeDir, p)); err != nil {
+			delete(c.records, p)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+	if err := codec.WriteFile(fc.FS, filepath.Join(fc.RepoDir, statCacheFile), c.encode()); err != nil {
+		return fmt.Errorf("write stat cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
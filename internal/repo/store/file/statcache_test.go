@@ -0,0 +1,91 @@
+package file_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// countingBlock counts how often file content is chunked.
+type countingBlock struct {
+	*mockBlock
+	splits atomic.Int32
+}
+
+func (b *countingBlock) SplitFileWith(path string, p block.Policy) ([]block.BlockRef, error) {
+	b.splits.Add(1)
+	return b.mockBlock.SplitFile(path)
+}
+
+func TestBuildEntries_StatCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	mem := fs.NewMemoryFS()
+	repoDir := filepath.Join(tmpDir, ".bvc")
+	mem.MkdirAll(repoDir, 0o755)
+	blocks := &countingBlock{mockBlock: newMockBlock()}
+	fc := file.NewFileContext(tmpDir, repoDir, blocks, mem)
+
+	old := time.Now().Add(-time.Hour)
+	stable := filepath.Join(tmpDir, "stable.txt")
+	fresh := filepath.Join(tmpDir, "fresh.txt")
+	mem.WriteFile(stable, []byte("stable"), 0o644)
+	mem.Chtimes(stable, old, old)
+	mem.WriteFile(fresh, []byte("fresh"), 0o644)
+
+	paths := []string{stable, fresh}
+	if _, err := fc.BuildEntries(paths, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := blocks.splits.Load(); n != 2 {
+		t.Fatalf("expected 2 splits on first build, got %d", n)
+	}
+
+	// the stable file is served from the cache; the racily clean one is not
+	if _, err := fc.BuildEntries(paths, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := blocks.splits.Load(); n != 3 {
+		t.Errorf("expected only the recently modified file to be re-chunked, got %d splits", n)
+	}
+
+	// a stat change invalidates the cached record
+	mem.WriteFile(stable, []byte("changed"), 0o644)
+	mem.Chtimes(stable, old, old.Add(time.Minute))
+	if _, err := fc.BuildEntries([]string{stable}, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := blocks.splits.Load(); n != 4 {
+		t.Errorf("expected changed file to be re-chunked, got %d splits", n)
+	}
+
+	// so does a new chunk size for the file
+	mem.WriteFile(filepath.Join(tmpDir, ".bvc-attributes"), []byte("*.txt chunk=64k\n"), 0o644)
+	fc = file.NewFileContext(tmpDir, repoDir, blocks, mem)
+	for range 2 {
+		if _, err := fc.BuildEntries([]string{stable}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := blocks.splits.Load(); n != 5 {
+		t.Errorf("expected file to be re-chunked once after its chunk policy changed, got %d splits", n)
+	}
+}
+
+func TestListEntries_DoesNotRead(t *testing.T) {
+	fc, tmpDir := newTestFC(t)
+	p := filepath.Join(tmpDir, "ignored.log")
+	fc.FS.WriteFile(p, []byte("0123456789"), 0o644)
+
+	entries := fc.ListEntries([]string{p})
+	if len(entries) != 1 || entries[0].Path != "ignored.log" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].Size != 10 || len(entries[0].Blocks) != 0 {
+		t.Errorf("expected size from stat and no blocks, got %+v", entries[0])
+	}
+}
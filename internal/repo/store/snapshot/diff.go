@@ -0,0 +1,40 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// DiffFilesets compares two filesets. It returns the entries of `to` that are
+// new or different from `from`, and the paths of `from` missing from `to`.
+func DiffFilesets(from, to *Fileset) (changed []file.Entry, removed []string) {
+	fromMap := map[string]file.Entry{}
+	if from != nil {
+		for _, f := range from.Files {
+			fromMap[filepath.Clean(f.Path)] = f
+		}
+	}
+	toMap := map[string]file.Entry{}
+	if to != nil {
+		for _, f := range to.Files {
+			toMap[filepath.Clean(f.Path)] = f
+		}
+	}
+
+	for p, t := range toMap {
+		if f, ok := fromMap[p]; !ok || !f.Equal(&t) {
+			changed = append(changed, t)
+		}
+	}
+	for p := range fromMap {
+		if _, ok := toMap[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	sort.Strings(removed)
+	return changed, removed
+}
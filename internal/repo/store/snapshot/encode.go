@@ -0,0 +1,201 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo/store/codec"
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// Snapshot objects are written in the binary format. Objects written before
+// it was introduced are JSON files with a ".json" suffix; they are still
+// read, and ConvertLegacy rewrites them.
+const (
+	treeMagic     = "BVCT"
+	manifestMagic = "BVCM"
+	filesetMagic  = "BVCF"
+	formatVersion = 1
+
+	legacySuffix = ".json"
+)
+
+var treeKinds = []string{KindFile, KindDir, KindLink}
+
+func (t *Tree) encode() []byte {
+	w := codec.NewWriter(treeMagic, formatVersion)
+	w.Uvarint(uint64(len(t.Entries)))
+	for _, e := range t.Entries {
+		kind := 0
+		for i, k := range treeKinds {
+			if k == e.Kind {
+				kind = i
+			}
+		}
+		w.String(e.Name)
+		w.Uvarint(uint64(kind))
+		w.Uvarint(uint64(e.Mode))
+		w.Varint(e.ModTime)
+		w.Varint(e.Size)
+		w.String(e.Link)
+		w.Hash(e.ID)
+	}
+	return w.Bytes()
+}
+
+func decodeTree(data []byte) (Tree, error) {
+	var t Tree
+	if !codec.IsEncoded(data, treeMagic) {
+		err := json.Unmarshal(data, &t)
+		return t, err
+	}
+	r, err := codec.Decode(data, treeMagic, formatVersion)
+	if err != nil {
+		return Tree{}, err
+	}
+	n := r.Count()
+	t.Entries = make([]TreeEntry, 0, n)
+	for i := 0; i < n && r.Err() == nil; i++ {
+		e := TreeEntry{Name: r.String()}
+		kind := r.Uvarint()
+		if kind >= uint64(len(treeKinds)) {
+			return Tree{}, fmt.Errorf("unknown entry kind %d", kind)
+		}
+		e.Kind = treeKinds[kind]
+		e.Mode = os.FileMode(r.Uvarint())
+		e.ModTime = r.Varint()
+		e.Size = r.Varint()
+		e.Link = r.String()
+		e.ID = r.Hash()
+		t.Entries = append(t.Entries, e)
+	}
+	return t, r.Err()
+}
+
+func (m *Manifest) encode() []byte {
+	w := codec.NewWriter(manifestMagic, formatVersion)
+	w.Varint(m.Size)
+	w.Blocks(m.Blocks)
+	return w.Bytes()
+}
+
+func decodeManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	if !codec.IsEncoded(data, manifestMagic) {
+		err := json.Unmarshal(data, &m)
+		return m, err
+	}
+	r, err := codec.Decode(data, manifestMagic, formatVersion)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m.Size = r.Varint()
+	m.Blocks = r.Blocks()
+	return m, r.Err()
+}
+
+func (fs *Fileset) encode() []byte {
+	w := codec.NewWriter(filesetMagic, formatVersion)
+	w.String(fs.ID)
+	file.WriteEntries(w, fs.Files)
+	return w.Bytes()
+}
+
+func decodeFileset(data []byte) (Fileset, error) {
+	var fs Fileset
+	if !codec.IsEncoded(data, filesetMagic) {
+		err := json.Unmarshal(data, &fs)
+		return fs, err
+	}
+	r, err := codec.Decode(data, filesetMagic, formatVersion)
+	if err != nil {
+		return Fileset{}, err
+	}
+	fs.ID = r.String()
+	if fs.Files, err = file.ReadEntries(r); err != nil {
+		return Fileset{}, err
+	}
+	return fs, nil
+}
+
+// readObject reads the object stored at path, falling back to its legacy
+// JSON file.
+func (sc *SnapshotContext) readObject(path string) ([]byte, error) {
+	data, err := sc.FS.ReadFile(path)
+	if err != nil && sc.FS.IsNotExist(err) {
+		return sc.FS.ReadFile(path + legacySuffix)
+	}
+	return data, err
+}
+
+// hasObject reports whether the object at path is stored in either format.
+func (sc *SnapshotContext) hasObject(path string) bool {
+	return sc.FS.Exists(path) || sc.FS.Exists(path+legacySuffix)
+}
+
+// ConvertLegacy rewrites every JSON snapshot object in the binary format and
+// removes the JSON file. With dryRun set nothing is written. It returns the
+// paths of the legacy files.
+func (sc *SnapshotContext) ConvertLegacy(dryRun bool) ([]string, error) {
+	if sc.FS == nil {
+		return nil, errNoFS
+	}
+	type kind struct {
+		dir     string
+		convert func([]byte) ([]byte, error)
+	}
+	kinds := []kind{
+		{sc.SnapshotDir, func(data []byte) ([]byte, error) {
+			fs, err := decodeFileset(data)
+			return fs.encode(), err
+		}},
+		{filepath.Join(sc.SnapshotDir, treesDir), func(data []byte) ([]byte, error) {
+			t, err := decodeTree(data)
+			return t.encode(), err
+		}},
+		{filepath.Join(sc.SnapshotDir, manifestsDir), func(data []byte) ([]byte, error) {
+			m, err := decodeManifest(data)
+			return m.encode(), err
+		}},
+	}
+
+	var converted []string
+	for _, k := range kinds {
+		entries, err := sc.FS.ReadDir(k.dir)
+		if err != nil {
+			if sc.FS.IsNotExist(err) {
+				continue
+			}
+			return converted, fmt.Errorf("failed to list %s: %w", k.dir, err)
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, legacySuffix) || strings.HasPrefix(name, "tmp-") {
+				continue
+			}
+			legacy := filepath.Join(k.dir, name)
+			converted = append(converted, legacy)
+			if dryRun {
+				continue
+			}
+			data, err := sc.FS.ReadFile(legacy)
+			if err != nil {
+				return converted, fmt.Errorf("failed to read %s: %w", legacy, err)
+			}
+			encoded, err := k.convert(data)
+			if err != nil {
+				return converted, fmt.Errorf("failed to decode %s: %w", legacy, err)
+			}
+			if err := codec.WriteFile(sc.FS, strings.TrimSuffix(legacy, legacySuffix), encoded); err != nil {
+				return converted, fmt.Errorf("failed to write %s: %w", legacy, err)
+			}
+			if err := sc.FS.Remove(legacy); err != nil {
+				return converted, fmt.Errorf("failed to remove %s: %w", legacy, err)
+			}
+		}
+	}
+	return converted, nil
+}
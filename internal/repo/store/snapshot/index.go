@@ -0,0 +1,47 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// ApplyIndex overlays staged index entries onto the parent fileset and returns
+// the complete tree the next commit records. A nil parent is an empty tree.
+func ApplyIndex(parent *Fileset, index []file.Entry) Fileset {
+	files := map[string]file.Entry{}
+	if parent != nil {
+		for _, f := range parent.Files {
+			files[filepath.Clean(f.Path)] = f
+		}
+	}
+	for _, e := range index {
+		p := filepath.Clean(e.Path)
+		if e.Deleted {
+			delete(files, p)
+			continue
+		}
+		files[p] = e
+	}
+
+	entries := make([]file.Entry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, f)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	return Fileset{ID: HashFileset(entries), Files: entries}
+}
+
+// IndexDelta returns the index entries that turn parent into next: added and
+// modified files plus deletion markers for removed paths. It is the inverse
+// of ApplyIndex.
+func IndexDelta(parent, next *Fileset) []file.Entry {
+	changed, removed := DiffFilesets(parent, next)
+	for _, p := range removed {
+		changed = append(changed, file.DeletedEntry(p))
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	return changed
+}
@@ -0,0 +1,63 @@
+package snapshot_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+func TestApplyIndex(t *testing.T) {
+	parent := fileset(entry("a.txt", "1"), entry("b.txt", "2"), entry("c.txt", "3"))
+	index := []file.Entry{
+		entry("b.txt", "9"),
+		entry("d.txt", "4"),
+		file.DeletedEntry("c.txt"),
+	}
+
+	next := snapshot.ApplyIndex(parent, index)
+
+	got := map[string]string{}
+	for _, f := range next.Files {
+		got[f.Path] = f.Blocks[0].Hash
+	}
+	want := map[string]string{"a.txt": "1", "b.txt": "9", "d.txt": "4"}
+	if len(got) != len(want) {
+		t.Fatalf("next tree = %v, want %v", got, want)
+	}
+	for p, h := range want {
+		if got[p] != h {
+			t.Errorf("%s: got %q, want %q", p, got[p], h)
+		}
+	}
+	if next.ID != snapshot.HashFileset(next.Files) {
+		t.Error("next tree ID does not match its files")
+	}
+
+	// an empty index reproduces the parent
+	if same := snapshot.ApplyIndex(parent, nil); same.ID != parent.ID {
+		t.Errorf("empty index changed the tree: %s != %s", same.ID, parent.ID)
+	}
+}
+
+func TestIndexDelta_RoundTrip(t *testing.T) {
+	parent := fileset(entry("a.txt", "1"), entry("b.txt", "2"), entry("c.txt", "3"))
+	next := fileset(entry("a.txt", "1"), entry("b.txt", "9"), entry("d.txt", "4"))
+
+	delta := snapshot.IndexDelta(parent, next)
+	if len(delta) != 3 {
+		t.Fatalf("delta = %v, want 3 entries", delta)
+	}
+	for _, e := range delta {
+		if e.Path == "c.txt" && !e.Deleted {
+			t.Error("expected c.txt to be staged for deletion")
+		}
+		if e.Path == "a.txt" {
+			t.Error("unchanged a.txt must not be staged")
+		}
+	}
+
+	if got := snapshot.ApplyIndex(parent, delta); got.ID != next.ID || len(got.Files) != len(next.Files) {
+		t.Errorf("ApplyIndex(IndexDelta) = %v, want %v", got.Files, next.Files)
+	}
+}
@@ -0,0 +1,133 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/keshon/bvc/internal/repo/store/file"
+)
+
+// MergeFilesets performs three-way merge of filesets.
+// Returns merged fileset and list of conflicting paths.
+func MergeFilesets(base, ours, theirs *Fileset) (Fileset, []string) {
+	return MergeFilesetsWith(base, ours, theirs, nil)
+}
+
+// MergeFilesetsWith merges like MergeFilesets, but resolves conflicting
+// changes to a path to "ours" or "theirs" when strategy returns that for it
+// (see the merge attribute). A nil strategy reports every conflict.
+func MergeFilesetsWith(base, ours, theirs *Fileset, strategy func(path string) string) (Fileset, []string) {
+	// returns merged fileset and list of conflict paths
+	conflicts := []string{}
+	mergedMap := map[string]file.Entry{}
+
+	// Create maps for quick lookup
+	baseMap := map[string]file.Entry{}
+	for _, f := range base.Files {
+		baseMap[filepath.Clean(f.Path)] = f
+	}
+	oursMap := map[string]file.Entry{}
+	for _, f := range ours.Files {
+		oursMap[filepath.Clean(f.Path)] = f
+	}
+	theirsMap := map[string]file.Entry{}
+	for _, f := range theirs.Files {
+		theirsMap[filepath.Clean(f.Path)] = f
+	}
+
+	// union of all paths
+	allPaths := map[string]bool{}
+	for p := range baseMap {
+		allPaths[p] = true
+	}
+	for p := range oursMap {
+		allPaths[p] = true
+	}
+	for p := range theirsMap {
+		allPaths[p] = true
+	}
+
+	for path := range allPaths {
+		var b *file.Entry
+		var o *file.Entry
+		var t *file.Entry
+
+		if v, ok := baseMap[path]; ok {
+			tmp := v
+			b = &tmp
+		}
+		if v, ok := oursMap[path]; ok {
+			o = &v
+		}
+		if v, ok := theirsMap[path]; ok {
+			t = &v
+		}
+
+		// Cases
+		switch {
+		// identical theirs and ours -> take either (or nothing if both nil)
+		case o.Equal(t):
+			if o != nil {
+				mergedMap[path] = *o
+			}
+			// if both nil -> deleted in both -> skip
+
+		// unchanged in ours (base == ours) -> take theirs (could be add, modify, or delete)
+		case b.Equal(o):
+			if t != nil {
+				mergedMap[path] = *t
+			} else {
+				// theirs deleted -> delete in merged
+				// i.e. do nothing (omit from mergedMap)
+			}
+
+		// unchanged in theirs (base == theirs) -> take ours
+		case b.Equal(t):
+			if o != nil {
+				mergedMap[path] = *o
+			} else {
+				// ours deleted -> deleted in merged
+			}
+
+		// conflict resolved by the path's merge strategy
+		case strategy != nil && strategy(path) == "ours":
+			if o != nil {
+				mergedMap[path] = *o
+			}
+		case strategy != nil && strategy(path) == "theirs":
+			if t != nil {
+				mergedMap[path] = *t
+			}
+
+		// conflict: both changed differently since base (or base nil and both changed differently)
+		default:
+			// Conflict resolution policy: keep ours, write theirs to .MERGE_THEIRS
+			if o != nil {
+				mergedMap[path] = *o
+			}
+			if t != nil {
+				// create duplicate path for their version
+				conflictPath := path + ".MERGE_THEIRS"
+				theirsCopy := *t
+				theirsCopy.Path = conflictPath
+				mergedMap[conflictPath] = theirsCopy
+				conflicts = append(conflicts, path)
+			}
+		}
+	}
+
+	// build Fileset struct
+	mergedFiles := make([]file.Entry, 0, len(mergedMap))
+	for _, f := range mergedMap {
+		mergedFiles = append(mergedFiles, f)
+	}
+	// deterministic order
+	sort.SliceStable(mergedFiles, func(i, j int) bool {
+		return filepath.Clean(mergedFiles[i].Path) < filepath.Clean(mergedFiles[j].Path)
+	})
+
+	sort.Strings(conflicts)
+
+	filesetID := HashFileset(mergedFiles)
+	return Fileset{ID: filesetID, Files: mergedFiles}, conflicts
+}
@@ -0,0 +1,117 @@
+package snapshot_test
+
+import (
+	"testing"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+func entry(path, hash string) file.Entry {
+	return file.Entry{Path: path, Blocks: []block.BlockRef{{Hash: hash, Size: 1}}}
+}
+
+func fileset(entries ...file.Entry) *snapshot.Fileset {
+	return &snapshot.Fileset{ID: snapshot.HashFileset(entries), Files: entries}
+}
+
+func TestMergeFilesets(t *testing.T) {
+	base := fileset(entry("keep.txt", "k"), entry("ours.txt", "o1"), entry("theirs.txt", "t1"), entry("both.txt", "b1"), entry("gone.txt", "g"))
+	ours := fileset(entry("keep.txt", "k"), entry("ours.txt", "o2"), entry("theirs.txt", "t1"), entry("both.txt", "b2"), entry("gone.txt", "g"))
+	theirs := fileset(entry("keep.txt", "k"), entry("ours.txt", "o1"), entry("theirs.txt", "t2"), entry("both.txt", "b3"), entry("new.txt", "n"))
+
+	merged, conflicts := snapshot.MergeFilesets(base, ours, theirs)
+
+	got := map[string]string{}
+	for _, f := range merged.Files {
+		got[f.Path] = f.Blocks[0].Hash
+	}
+	want := map[string]string{
+		"keep.txt":              "k",
+		"ours.txt":              "o2",
+		"theirs.txt":            "t2",
+		"both.txt":              "b2",
+		"both.txt.MERGE_THEIRS": "b3",
+		"new.txt":               "n",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("merged files = %v, want %v", got, want)
+	}
+	for p, h := range want {
+		if got[p] != h {
+			t.Errorf("%s: got %q, want %q", p, got[p], h)
+		}
+	}
+	if len(conflicts) != 1 || conflicts[0] != "both.txt" {
+		t.Errorf("conflicts = %v, want [both.txt]", conflicts)
+	}
+}
+
+func TestMergeFilesetsWithStrategy(t *testing.T) {
+	base := fileset(entry("gen/a.go", "a1"), entry("gen/b.go", "b1"), entry("src.go", "s1"))
+	ours := fileset(entry("gen/a.go", "a2"), entry("gen/b.go", "b2"), entry("src.go", "s2"))
+	theirs := fileset(entry("gen/a.go", "a3"), entry("gen/b.go", "b3"), entry("src.go", "s3"))
+
+	strategy := func(path string) string {
+		switch path {
+		case "gen/a.go":
+			return "ours"
+		case "gen/b.go":
+			return "theirs"
+		}
+		return ""
+	}
+	merged, conflicts := snapshot.MergeFilesetsWith(base, ours, theirs, strategy)
+
+	got := map[string]string{}
+	for _, f := range merged.Files {
+		got[f.Path] = f.Blocks[0].Hash
+	}
+	if got["gen/a.go"] != "a2" || got["gen/b.go"] != "b3" || got["src.go"] != "s2" {
+		t.Errorf("merged files = %v", got)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "src.go" {
+		t.Errorf("conflicts = %v, want [src.go]", conflicts)
+	}
+}
+
+// A cherry-pick merges with the picked commit's parent as base, so only the
+// picked commit's own changes reach the branch.
+func TestMergeFilesetsPickDelta(t *testing.T) {
+	parent := fileset(entry("a.txt", "a1"), entry("b.txt", "b1"), entry("c.txt", "c1"))
+	pick := fileset(entry("a.txt", "a2"), entry("b.txt", "b1"), entry("e.txt", "e1"))
+	branch := fileset(entry("a.txt", "a1"), entry("b.txt", "b0"), entry("c.txt", "c1"), entry("d.txt", "d1"))
+
+	merged, conflicts := snapshot.MergeFilesets(parent, branch, pick)
+
+	got := map[string]string{}
+	for _, f := range merged.Files {
+		got[f.Path] = f.Blocks[0].Hash
+	}
+	want := map[string]string{"a.txt": "a2", "b.txt": "b0", "d.txt": "d1", "e.txt": "e1"}
+	if len(got) != len(want) {
+		t.Fatalf("merged files = %v, want %v", got, want)
+	}
+	for p, h := range want {
+		if got[p] != h {
+			t.Errorf("%s: got %q, want %q", p, got[p], h)
+		}
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("conflicts = %v, want none", conflicts)
+	}
+}
+
+func TestDiffFilesets(t *testing.T) {
+	from := fileset(entry("a.txt", "1"), entry("b.txt", "2"), entry("c.txt", "3"))
+	to := fileset(entry("a.txt", "1"), entry("b.txt", "9"), entry("d.txt", "4"))
+
+	changed, removed := snapshot.DiffFilesets(from, to)
+	if len(changed) != 2 || changed[0].Path != "b.txt" || changed[1].Path != "d.txt" {
+		t.Errorf("changed = %v, want [b.txt d.txt]", changed)
+	}
+	if len(removed) != 1 || removed[0] != "c.txt" {
+		t.Errorf("removed = %v, want [c.txt]", removed)
+	}
+}
@@ -1,17 +1,25 @@
 package snapshot
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/keshon/bvc/internal/fs"
 	"github.com/keshon/bvc/internal/progress"
 	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/codec"
 	"github.com/keshon/bvc/internal/repo/store/file"
 	"github.com/keshon/bvc/internal/util"
 )
 
+// errNoFS is returned by the methods that read or write snapshot objects
+// when no filesystem is attached.
+var errNoFS = fmt.Errorf("snapshot filesystem not attached")
+
 // SnapshotContext handles higher-level operations (filesets, commits)
 type SnapshotContext struct {
 	SnapshotDir string
@@ -49,43 +57,43 @@ func (sc *SnapshotContext) BuildStagedFileset() (Fileset, error) {
 	return sc.buildFilesetFromPaths(stagedPaths, "staged")
 }
 
-// BuildIgnoredFileset builds a Fileset of ignored files.
+// BuildIgnoredFileset lists ignored files. Their content is never read.
 func (sc *SnapshotContext) BuildIgnoredFileset() (Fileset, error) {
 	_, _, ignoredPaths, err := sc.FileCtx.ScanAllRepository()
 	if err != nil {
 		return Fileset{}, fmt.Errorf("failed to list ignored files: %w", err)
 	}
-	return sc.buildFilesetFromPaths(ignoredPaths, "ignored")
+	return listFileset(sc.FileCtx.ListEntries(ignoredPaths)), nil
 }
 
-// BuildAllRepositoryFilesets builds working, staged and ignored filesets in parallel
-// and returns them.
+// BuildAllRepositoryFilesets scans the working tree once and returns the
+// tracked, staged and ignored filesets. Tracked and staged files are chunked
+// unless the stat cache still vouches for them; ignored files are only listed.
 func (sc *SnapshotContext) BuildAllRepositoryFilesets() (tracked Fileset, staged Fileset, ignored Fileset, err error) {
-	type task struct {
-		id  int
-		run func() (Fileset, error)
+	trackedPaths, stagedPaths, ignoredPaths, err := sc.FileCtx.ScanAllRepository()
+	if err != nil {
+		return Fileset{}, Fileset{}, Fileset{}, fmt.Errorf("failed to scan repository: %w", err)
 	}
 
-	results := make([]Fileset, 3)
-	tasks := []task{
-		{0, sc.BuildWorkingTreeFileset},
-		{1, sc.BuildStagedFileset},
-		{2, sc.BuildIgnoredFileset},
+	entries, err := sc.FileCtx.BuildEntries(append(append([]string{}, trackedPaths...), stagedPaths...), true)
+	if err != nil {
+		return Fileset{}, Fileset{}, Fileset{}, fmt.Errorf("failed to build entries: %w", err)
 	}
 
-	err = util.Parallel(tasks, len(tasks), func(t task) error {
-		fs, e := t.run()
-		if e != nil {
-			return e
+	stagedSet := make(map[string]bool, len(stagedPaths))
+	for _, p := range sc.FileCtx.ListEntries(stagedPaths) {
+		stagedSet[p.Path] = true
+	}
+	var trackedEntries, stagedEntries []file.Entry
+	for _, e := range entries {
+		if stagedSet[e.Path] {
+			stagedEntries = append(stagedEntries, e)
+		} else {
+			trackedEntries = append(trackedEntries, e)
 		}
-		results[t.id] = fs
-		return nil
-	})
-	if err != nil {
-		return Fileset{}, Fileset{}, Fileset{}, err
 	}
 
-	return results[0], results[1], results[2], nil
+	return listFileset(trackedEntries), listFileset(stagedEntries), listFileset(sc.FileCtx.ListEntries(ignoredPaths)), nil
 }
 
 // buildFilesetFromPaths is a small helper to avoid duplication.
@@ -98,13 +106,19 @@ func (sc *SnapshotContext) buildFilesetFromPaths(paths []string, label string) (
 	if err != nil {
 		return Fileset{}, fmt.Errorf("failed to build %s entries: %w", label, err)
 	}
+	return listFileset(entries), nil
+}
 
+// listFileset sorts entries by path and wraps them in a Fileset.
+func listFileset(entries []file.Entry) Fileset {
+	if len(entries) == 0 {
+		return Fileset{Files: nil}
+	}
 	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
-
 	return Fileset{
 		ID:    HashFileset(entries),
 		Files: entries,
-	}, nil
+	}
 }
 
 // BuildFilesetFromEntries builds a Fileset from staged entries and stores their blocks.
@@ -130,29 +144,60 @@ func (sc *SnapshotContext) WriteAndSave(fs *Fileset) error {
 	if fs.ID == "" {
 		return fmt.Errorf("invalid fileset: missing ID")
 	}
+	if sc.FS == nil {
+		return errNoFS
+	}
 	if len(fs.Files) == 0 {
 		return fmt.Errorf("invalid fileset: no files")
 	}
-	if err := sc.writeFiles(fs); err != nil {
+	if err := sc.writeFiles(fs.Files); err != nil {
 		return fmt.Errorf("failed to store files: %w", err)
 	}
 	return sc.Save(*fs)
 }
 
+// WriteChangesAndSave stores the blocks of the changed entries only and saves
+// the Fileset metadata. Unchanged entries are expected to be stored already
+// (e.g. inherited from a parent commit). The fileset may be empty.
+func (sc *SnapshotContext) WriteChangesAndSave(fs *Fileset, changed []file.Entry) error {
+	if fs.ID == "" {
+		return fmt.Errorf("invalid fileset: missing ID")
+	}
+	if len(changed) > 0 {
+		if err := sc.writeFiles(changed); err != nil {
+			return fmt.Errorf("failed to store files: %w", err)
+		}
+	}
+	return sc.Save(*fs)
+}
+
 // writeFiles stores each file’s blocks to disk with progress display.
-func (sc *SnapshotContext) writeFiles(fs *Fileset) error {
-	if sc.BlockCtx != nil {
-		_ = sc.BlockCtx.CleanupTemp()
+func (sc *SnapshotContext) writeFiles(files []file.Entry) (err error) {
+	if sc.BlockCtx == nil || sc.FileCtx == nil {
+		return fmt.Errorf("store managers not attached")
 	}
+	_ = sc.BlockCtx.CleanupTemp()
 
-	bar := progress.NewProgress(len(fs.Files), "Storing files ")
+	bar := progress.NewProgress(len(files), "Storing files ")
 	defer bar.Finish()
 
-	return util.Parallel(fs.Files, util.WorkerCount(), func(f file.Entry) error {
-		if sc.BlockCtx == nil || sc.FileCtx == nil {
-			return fmt.Errorf("store managers not attached")
+	// files are read by the Parallel workers, blocks written by the pipeline's
+	pl := sc.BlockCtx.NewPipeline(util.WorkerCount(), 0)
+	defer func() {
+		if cerr := pl.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("error storing blocks: %w", cerr)
+		}
+	}()
+
+	// blocks staged by add are stored already; others are read from the
+	// working tree and must still match
+	return util.Parallel(files, util.WorkerCount(), func(f file.Entry) error {
+		compress := sc.FileCtx.Attributes().For(f.Path).Policy().Compress
+		err := pl.WriteBlocks(filepath.Join(sc.FileCtx.WorkingTreeDir, f.Path), f.Blocks, compress)
+		if errors.Is(err, block.ErrContentChanged) || errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s changed since it was staged and its content is not stored; run 'bvc add' again", f.Path)
 		}
-		if err := sc.BlockCtx.Write(f.Path, f.Blocks); err != nil {
+		if err != nil {
 			return fmt.Errorf("error storing file %s: %w", f.Path, err)
 		}
 		bar.Increment()
@@ -160,43 +205,180 @@ func (sc *SnapshotContext) writeFiles(fs *Fileset) error {
 	})
 }
 
-// Save persists a Fileset JSON to disk.
+// StageFiles stores the blocks of entries about to be staged, reading each
+// file once, and returns the entries with the blocks as stored. A file edited
+// since it was scanned is staged as it was read, so the index only ever
+// refers to stored content.
+func (sc *SnapshotContext) StageFiles(entries []file.Entry) (_ []file.Entry, err error) {
+	if sc.BlockCtx == nil || sc.FileCtx == nil {
+		return nil, fmt.Errorf("store managers not attached")
+	}
+	_ = sc.BlockCtx.CleanupTemp()
+
+	var todo []int
+	for i, e := range entries {
+		if e.Deleted || !(e.Mode.IsRegular() || e.Mode == 0) || sc.hasBlocks(e) {
+			continue // nothing to store, or stored already
+		}
+		todo = append(todo, i)
+	}
+	staged := append([]file.Entry(nil), entries...)
+	if len(todo) == 0 {
+		return staged, nil
+	}
+
+	bar := progress.NewProgress(len(todo), "Storing files ")
+	defer bar.Finish()
+
+	pl := sc.BlockCtx.NewPipeline(util.WorkerCount(), 0)
+	defer func() {
+		if cerr := pl.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("error storing blocks: %w", cerr)
+		}
+	}()
+
+	err = util.Parallel(todo, util.WorkerCount(), func(i int) error {
+		e := &staged[i]
+		policy := sc.FileCtx.Attributes().For(e.Path).Policy()
+		blocks, err := pl.StoreFile(filepath.Join(sc.FileCtx.WorkingTreeDir, e.Path), policy)
+		if err != nil {
+			return fmt.Errorf("error storing file %s: %w", e.Path, err)
+		}
+		e.Blocks, e.Size = blocks, 0
+		for _, b := range blocks {
+			e.Size += b.Size
+		}
+		bar.Increment()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return staged, nil
+}
+
+// hasBlocks reports whether every block of an entry is stored.
+func (sc *SnapshotContext) hasBlocks(e file.Entry) bool {
+	for _, b := range e.Blocks {
+		if !sc.BlockCtx.Has(b.Hash) {
+			return false
+		}
+	}
+	return true
+}
+
+// Save persists a Fileset. Content-addressed filesets (ID == HashFileset of
+// their files) are stored as tree and manifest objects, sharing unchanged
+// subtrees and file contents with earlier snapshots. Filesets under any other
+// ID are stored as a single flat fileset object.
 func (sc *SnapshotContext) Save(fs Fileset) error {
 	if fs.ID == "" {
 		return fmt.Errorf("invalid fileset: missing ID")
 	}
+	if sc.FS == nil {
+		return errNoFS
+	}
 
 	if err := sc.FS.MkdirAll(sc.SnapshotDir, 0o755); err != nil {
 		return fmt.Errorf("create snapshots dir: %w", err)
 	}
 
-	path := filepath.Join(sc.SnapshotDir, fs.ID+".json")
-	return util.WriteJSON(path, fs)
+	if objs := buildTree(fs.Files); objs.root == fs.ID {
+		return sc.saveTree(objs)
+	}
+
+	return codec.WriteFile(sc.FS, filepath.Join(sc.SnapshotDir, fs.ID), fs.encode())
 }
 
-// Load retrieves a Fileset by its ID from disk.
+// Load retrieves a Fileset by its ID from disk, from either a flat fileset
+// object or a root tree.
 func (sc *SnapshotContext) Load(filesetID string) (Fileset, error) {
-	path := filepath.Join(sc.SnapshotDir, filesetID+".json")
-	var fs Fileset
-	if err := util.ReadJSON(path, &fs); err != nil {
+	if sc.FS == nil {
+		return Fileset{}, errNoFS
+	}
+	if filesetID != "" && sc.isTree(filesetID) {
+		files, err := sc.loadTreeFiles(filesetID)
+		if err != nil {
+			return Fileset{}, fmt.Errorf("failed to read fileset %q: %w", filesetID, err)
+		}
+		return Fileset{ID: filesetID, Files: files}, nil
+	}
+
+	data, err := sc.readObject(filepath.Join(sc.SnapshotDir, filesetID))
+	if err != nil {
+		return Fileset{}, fmt.Errorf("failed to read fileset %q: %w", filesetID, err)
+	}
+	fs, err := decodeFileset(data)
+	if err != nil {
 		return Fileset{}, fmt.Errorf("failed to read fileset %q: %w", filesetID, err)
 	}
 	return fs, nil
 }
 
-// List retrieves all filesets from disk.
+// List retrieves all filesets from disk: flat fileset objects and every tree
+// that is not a subtree of another tree.
 func (sc *SnapshotContext) List() ([]Fileset, error) {
-	files, err := filepath.Glob(filepath.Join(sc.SnapshotDir, "*.json"))
+	if sc.FS == nil {
+		return nil, errNoFS
+	}
+	var filesets []Fileset
+	flat, err := sc.objectIDs(sc.SnapshotDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list filesets: %w", err)
 	}
-	var filesets []Fileset
-	for _, f := range files {
-		var fs Fileset
-		if err := util.ReadJSON(f, &fs); err != nil {
-			return nil, fmt.Errorf("failed to read fileset %q: %w", f, err)
+	for _, id := range flat {
+		fs, err := sc.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		filesets = append(filesets, fs)
+	}
+
+	treeIDs, err := sc.objectIDs(filepath.Join(sc.SnapshotDir, treesDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trees: %w", err)
+	}
+	subtrees := map[string]bool{}
+	for _, id := range treeIDs {
+		t, err := sc.LoadTree(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range t.Entries {
+			if e.Kind == KindDir {
+				subtrees[e.ID] = true
+			}
+		}
+	}
+	for _, id := range treeIDs {
+		if subtrees[id] {
+			continue
+		}
+		fs, err := sc.Load(id)
+		if err != nil {
+			return nil, err
 		}
 		filesets = append(filesets, fs)
 	}
 	return filesets, nil
 }
+
+// objectIDs lists the IDs of the objects stored in dir in either format.
+func (sc *SnapshotContext) objectIDs(dir string) ([]string, error) {
+	entries, err := sc.FS.ReadDir(dir)
+	if err != nil {
+		if sc.FS.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, "tmp-") {
+			continue
+		}
+		seen[strings.TrimSuffix(name, legacySuffix)] = true
+	}
+	return util.SortedKeys(seen), nil
+}
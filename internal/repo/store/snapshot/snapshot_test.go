@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/keshon/bvc/internal/fs"
 	"github.com/keshon/bvc/internal/repo/store/block"
 	"github.com/keshon/bvc/internal/repo/store/file"
 	"github.com/keshon/bvc/internal/repo/store/snapshot"
@@ -200,4 +201,58 @@ func TestSnapshotContext_Errors(t *testing.T) {
 	if err == nil {
 		t.Error("expected error from writeFiles with nil managers")
 	}
+
+	// 8. Save without a filesystem
+	err = sm.Save(snapshot.Fileset{ID: "fake", Files: []file.Entry{{Path: "a"}}})
+	if err == nil {
+		t.Error("expected error from Save with no filesystem")
+	}
+}
+
+// --- Test StageFiles: content is stored at add time, not re-read at commit --- //
+func TestStageFiles(t *testing.T) {
+	root := makeTempDir(t)
+	osfs := fs.NewOSFS()
+	bm := block.NewBlockContext(filepath.Join(root, "blocks"), osfs)
+	fm := file.NewFileContext(filepath.Join(root, "work"), filepath.Join(root, "work", ".bvc"), bm, osfs)
+	sm := &snapshot.SnapshotContext{SnapshotDir: filepath.Join(root, "snapshots"), FileCtx: fm, BlockCtx: bm, FS: osfs}
+
+	path := filepath.Join(fm.WorkingTreeDir, "a.txt")
+	if err := os.MkdirAll(fm.WorkingTreeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("staged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fm.BuildEntry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged, err := sm.StageFiles([]file.Entry{e})
+	if err != nil {
+		t.Fatalf("StageFiles failed: %v", err)
+	}
+
+	// an edit after staging must not reach the commit
+	if err := os.WriteFile(path, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs1 := &snapshot.Fileset{ID: snapshot.HashFileset(staged), Files: staged}
+	if err := sm.WriteAndSave(fs1); err != nil {
+		t.Fatalf("WriteAndSave failed: %v", err)
+	}
+	data, err := bm.Read(staged[0].Blocks[0].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "staged" {
+		t.Fatalf("stored %q, want the staged content", data)
+	}
+
+	// entries never staged are verified against the working tree
+	unstaged := file.Entry{Path: "a.txt", Blocks: []block.BlockRef{{Hash: "deadbeef", Size: 6}}}
+	fs2 := &snapshot.Fileset{ID: "x", Files: []file.Entry{unstaged}}
+	if err := sm.WriteAndSave(fs2); err == nil {
+		t.Fatal("expected an error for content changed since it was staged")
+	}
 }
@@ -0,0 +1,311 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/codec"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/util"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Snapshots are stored as content-addressed objects:
+//
+//	snapshots/trees/<id>      one object per directory
+//	snapshots/manifests/<id>  one object per distinct file content
+//
+// A fileset ID is the ID of its root tree. Identical subtrees and file
+// contents hash to the same object and are shared between commits.
+const (
+	treesDir     = "trees"
+	manifestsDir = "manifests"
+)
+
+// Entry kinds in a tree.
+const (
+	KindFile = "file"
+	KindDir  = "dir"
+	KindLink = "link"
+)
+
+// Tree is a directory object. Entries are sorted by name.
+type Tree struct {
+	Entries []TreeEntry `json:"entries"`
+}
+
+// TreeEntry names a child of a tree. ID refers to a manifest for files and
+// to a subtree for directories; links carry their target inline. ModTime is
+// stored but left out of the tree ID, so touching a file does not make a new
+// tree; a tree already stored keeps the times it was first written with.
+type TreeEntry struct {
+	Name    string      `json:"name"`
+	Kind    string      `json:"kind"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	ModTime int64       `json:"mtime,omitempty"`
+	Size    int64       `json:"size,omitempty"`
+	Link    string      `json:"link,omitempty"`
+	ID      string      `json:"id,omitempty"`
+}
+
+// Manifest lists the blocks of a file's content.
+type Manifest struct {
+	Size   int64            `json:"size"`
+	Blocks []block.BlockRef `json:"blocks"`
+}
+
+// ID returns the content hash of a manifest.
+func (m *Manifest) ID() string {
+	var sb strings.Builder
+	sb.WriteString("manifest\n")
+	for _, b := range m.Blocks {
+		fmt.Fprintf(&sb, "%s %d %d\n", b.Hash, b.Size, b.Offset)
+	}
+	return fmt.Sprintf("%x", xxh3.Hash128([]byte(sb.String())).Bytes())
+}
+
+// ID returns the content hash of a tree: names, kinds, modes, sizes, link
+// targets and child IDs. Modification times are not part of it.
+func (t *Tree) ID() string {
+	var sb strings.Builder
+	sb.WriteString("tree\n")
+	for _, e := range t.Entries {
+		fmt.Fprintf(&sb, "%s %o %d %q %q %s\n", e.Kind, uint32(e.Mode), e.Size, e.Name, e.Link, e.ID)
+	}
+	return fmt.Sprintf("%x", xxh3.Hash128([]byte(sb.String())).Bytes())
+}
+
+// treeObjects is the set of objects that make up one fileset.
+type treeObjects struct {
+	root      string
+	trees     map[string]Tree
+	manifests map[string]Manifest
+}
+
+// dirNode is a directory under construction.
+type dirNode struct {
+	dirs  map[string]*dirNode
+	files map[string]file.Entry
+	self  *file.Entry // set for directories recorded as entries (empty dirs)
+}
+
+func newDirNode() *dirNode {
+	return &dirNode{dirs: map[string]*dirNode{}, files: map[string]file.Entry{}}
+}
+
+// buildTree turns flat entries into tree and manifest objects.
+// Deletion markers are index-only and are not part of a tree.
+func buildTree(entries []file.Entry) treeObjects {
+	root := newDirNode()
+	for _, e := range entries {
+		if e.Deleted {
+			continue
+		}
+		parts := splitPath(e.Path)
+		if len(parts) == 0 {
+			continue
+		}
+		node := root
+		for _, name := range parts[:len(parts)-1] {
+			child, ok := node.dirs[name]
+			if !ok {
+				child = newDirNode()
+				node.dirs[name] = child
+			}
+			node = child
+		}
+		name := parts[len(parts)-1]
+		if e.IsDir() {
+			child, ok := node.dirs[name]
+			if !ok {
+				child = newDirNode()
+				node.dirs[name] = child
+			}
+			entry := e
+			child.self = &entry
+			continue
+		}
+		node.files[name] = e
+	}
+
+	objs := treeObjects{trees: map[string]Tree{}, manifests: map[string]Manifest{}}
+	objs.root = objs.add(root)
+	return objs
+}
+
+// add stores a directory node and its children and returns the tree ID.
+func (o *treeObjects) add(node *dirNode) string {
+	var tree Tree
+	for name, child := range node.dirs {
+		te := TreeEntry{Name: name, Kind: KindDir, ID: o.add(child)}
+		if child.self != nil {
+			te.Mode, te.ModTime = child.self.Mode, child.self.ModTime
+		}
+		tree.Entries = append(tree.Entries, te)
+	}
+	for name, e := range node.files {
+		te := TreeEntry{Name: name, Kind: KindFile, Mode: e.Mode, ModTime: e.ModTime, Size: e.Size}
+		if e.IsSymlink() {
+			te.Kind, te.Link = KindLink, e.Link
+		} else {
+			m := Manifest{Size: e.Size, Blocks: e.Blocks}
+			if m.Size == 0 {
+				for _, b := range e.Blocks {
+					m.Size += b.Size
+				}
+			}
+			te.ID = m.ID()
+			o.manifests[te.ID] = m
+		}
+		tree.Entries = append(tree.Entries, te)
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return tree.Entries[i].Name < tree.Entries[j].Name })
+
+	id := tree.ID()
+	o.trees[id] = tree
+	return id
+}
+
+// splitPath breaks a relative slash path into its components.
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(p)), "/")
+	if p == "" || p == "." {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func (sc *SnapshotContext) treePath(id string) string {
+	return filepath.Join(sc.SnapshotDir, treesDir, id)
+}
+
+func (sc *SnapshotContext) manifestPath(id string) string {
+	return filepath.Join(sc.SnapshotDir, manifestsDir, id)
+}
+
+// saveTree writes the objects of a fileset; objects already stored are skipped.
+func (sc *SnapshotContext) saveTree(objs treeObjects) error {
+	for _, dir := range []string{treesDir, manifestsDir} {
+		if err := sc.FS.MkdirAll(filepath.Join(sc.SnapshotDir, dir), 0o755); err != nil {
+			return fmt.Errorf("create %s dir: %w", dir, err)
+		}
+	}
+	for _, id := range util.SortedKeys(objs.manifests) {
+		if sc.hasObject(sc.manifestPath(id)) {
+			continue
+		}
+		m := objs.manifests[id]
+		if err := codec.WriteFile(sc.FS, sc.manifestPath(id), m.encode()); err != nil {
+			return fmt.Errorf("write manifest %s: %w", id, err)
+		}
+	}
+	for _, id := range util.SortedKeys(objs.trees) {
+		if sc.hasObject(sc.treePath(id)) {
+			continue
+		}
+		t := objs.trees[id]
+		if err := codec.WriteFile(sc.FS, sc.treePath(id), t.encode()); err != nil {
+			return fmt.Errorf("write tree %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// LoadTree reads a tree object.
+func (sc *SnapshotContext) LoadTree(id string) (Tree, error) {
+	data, err := sc.readObject(sc.treePath(id))
+	if err != nil {
+		return Tree{}, fmt.Errorf("failed to read tree %q: %w", id, err)
+	}
+	t, err := decodeTree(data)
+	if err != nil {
+		return Tree{}, fmt.Errorf("failed to read tree %q: %w", id, err)
+	}
+	return t, nil
+}
+
+// LoadManifest reads a file manifest object.
+func (sc *SnapshotContext) LoadManifest(id string) (Manifest, error) {
+	data, err := sc.readObject(sc.manifestPath(id))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest %q: %w", id, err)
+	}
+	m, err := decodeManifest(data)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest %q: %w", id, err)
+	}
+	return m, nil
+}
+
+// loadTreeFiles flattens a stored tree back into entries sorted by path.
+func (sc *SnapshotContext) loadTreeFiles(rootID string) ([]file.Entry, error) {
+	manifests := map[string]Manifest{}
+	var files []file.Entry
+
+	var walk func(t Tree, prefix string) error
+	walk = func(t Tree, prefix string) error {
+		for _, te := range t.Entries {
+			p := te.Name
+			if prefix != "" {
+				p = prefix + "/" + te.Name
+			}
+			switch te.Kind {
+			case KindDir:
+				sub, err := sc.LoadTree(te.ID)
+				if err != nil {
+					return err
+				}
+				if len(sub.Entries) == 0 {
+					mode := te.Mode
+					if mode == 0 {
+						mode = os.ModeDir | 0o755
+					}
+					files = append(files, file.Entry{Path: p, Mode: mode, ModTime: te.ModTime})
+					continue
+				}
+				if err := walk(sub, p); err != nil {
+					return err
+				}
+			case KindLink:
+				files = append(files, file.Entry{Path: p, Mode: te.Mode, ModTime: te.ModTime, Link: te.Link})
+			default:
+				m, ok := manifests[te.ID]
+				if !ok {
+					var err error
+					if m, err = sc.LoadManifest(te.ID); err != nil {
+						return err
+					}
+					manifests[te.ID] = m
+				}
+				files = append(files, file.Entry{
+					Path:    p,
+					Blocks:  m.Blocks,
+					Mode:    te.Mode,
+					ModTime: te.ModTime,
+					Size:    te.Size,
+				})
+			}
+		}
+		return nil
+	}
+
+	root, err := sc.LoadTree(rootID)
+	if err != nil {
+		return nil, err
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// isTree reports whether id names a stored tree object.
+func (sc *SnapshotContext) isTree(id string) bool {
+	return sc.hasObject(sc.treePath(id))
+}
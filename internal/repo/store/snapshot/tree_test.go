@@ -0,0 +1,86 @@
+package snapshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keshon/bvc/internal/fs"
+	"github.com/keshon/bvc/internal/repo/store/block"
+	"github.com/keshon/bvc/internal/repo/store/file"
+	"github.com/keshon/bvc/internal/repo/store/snapshot"
+)
+
+func TestHashFileset_PathAware(t *testing.T) {
+	a := []file.Entry{entry("a.txt", "h1")}
+	b := []file.Entry{entry("b.txt", "h1")}
+	if snapshot.HashFileset(a) == snapshot.HashFileset(b) {
+		t.Error("renamed file must change the fileset ID")
+	}
+
+	exec := entry("a.txt", "h1")
+	exec.Mode = 0o755
+	if snapshot.HashFileset(a) == snapshot.HashFileset([]file.Entry{exec}) {
+		t.Error("mode change must change the fileset ID")
+	}
+
+	touched := entry("a.txt", "h1")
+	touched.ModTime = 42
+	if snapshot.HashFileset(a) != snapshot.HashFileset([]file.Entry{touched}) {
+		t.Error("mtime change must not change the fileset ID")
+	}
+}
+
+func TestSaveLoad_Tree(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	sc := snapshot.NewSnapshotContext(dir, nil, nil, fs.NewOSFS())
+
+	shared := file.Entry{Path: "lib/util.go", Blocks: []block.BlockRef{{Hash: "h1", Size: 3}}, Mode: 0o644, ModTime: 42, Size: 3}
+	entries := []file.Entry{
+		{Path: "README", Blocks: []block.BlockRef{{Hash: "h2", Size: 5}}, Mode: 0o644, Size: 5},
+		{Path: "bin/run", Mode: os.ModeSymlink | 0o777, Link: "../lib/util.go"},
+		{Path: "empty", Mode: os.ModeDir | 0o755},
+		shared,
+	}
+	fs1 := snapshot.Fileset{ID: snapshot.HashFileset(entries), Files: entries}
+	if err := sc.Save(fs1); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := sc.Load(fs1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Files) != len(entries) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(entries), len(loaded.Files), loaded.Files)
+	}
+	for i, e := range loaded.Files {
+		want := entries[i]
+		if e.Path != want.Path || e.Mode != want.Mode || e.Link != want.Link || !e.Equal(&want) {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want, e)
+		}
+	}
+	if loaded.Files[3].ModTime != 42 {
+		t.Errorf("expected mtime to survive, got %d", loaded.Files[3].ModTime)
+	}
+
+	// a second snapshot changing only README shares the lib subtree
+	entries2 := append([]file.Entry{{Path: "README", Blocks: []block.BlockRef{{Hash: "h3", Size: 5}}, Size: 5}}, entries[1:]...)
+	fs2 := snapshot.Fileset{ID: snapshot.HashFileset(entries2), Files: entries2}
+	if err := sc.Save(fs2); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := sc.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("expected 2 root filesets, got %d", len(list))
+	}
+
+	manifests, _ := os.ReadDir(filepath.Join(dir, "manifests"))
+	if len(manifests) != 3 {
+		t.Errorf("expected 3 distinct manifests, got %d", len(manifests))
+	}
+}
@@ -1,32 +1,12 @@
 package snapshot
 
 import (
-	"fmt"
-	"path/filepath"
-	"sort"
-
 	"github.com/keshon/bvc/internal/repo/store/file"
-
-	"github.com/zeebo/xxh3"
 )
 
-// HashFileset generates a stable hash for a given fileset’s contents.
+// HashFileset returns the fileset ID for the given entries: the ID of the
+// root tree they form. It depends on paths, metadata and content, but not on
+// entry order.
 func HashFileset(entries []file.Entry) string {
-	paths := make([]string, 0, len(entries))
-	index := make(map[string]file.Entry, len(entries))
-	for _, f := range entries {
-		clean := filepath.Clean(f.Path)
-		paths = append(paths, clean)
-		index[clean] = f
-	}
-	sort.Strings(paths)
-
-	data := make([]byte, 0, len(paths)*64)
-	for _, p := range paths {
-		for _, b := range index[p].Blocks {
-			data = append(data, []byte(b.Hash+"\n")...)
-		}
-	}
-
-	return fmt.Sprintf("%x", xxh3.Hash128(data).Bytes())
+	return buildTree(entries).root
 }
@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 
 	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/fs"
 	"github.com/keshon/bvc/internal/repo/meta"
 	"github.com/keshon/bvc/internal/repo/store/snapshot"
 	"github.com/keshon/bvc/internal/util"
@@ -19,6 +20,8 @@ func CountBlocks(m MetaInterface, cfg *config.RepoConfig, onlyLatestCommit bool)
 
 	hashes := map[string]struct{}{}
 
+	snapshots := snapshot.NewSnapshotContext(cfg.SnapshotsDir(), nil, nil, fs.NewOSFS())
+
 	for _, b := range branches {
 		var commitIDs []string
 		if !onlyLatestCommit {
@@ -43,9 +46,8 @@ func CountBlocks(m MetaInterface, cfg *config.RepoConfig, onlyLatestCommit bool)
 				continue
 			}
 
-			filesetPath := filepath.Join(cfg.SnapshotsDir(), commit.FilesetID+".json")
-			var fs snapshot.Fileset
-			if err := util.ReadJSON(filesetPath, &fs); err != nil {
+			fs, err := snapshots.Load(commit.FilesetID)
+			if err != nil {
 				continue
 			}
 
@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 
 	"github.com/keshon/bvc/internal/config"
+	"github.com/keshon/bvc/internal/fs"
 	"github.com/keshon/bvc/internal/repo/meta"
 
 	"github.com/keshon/bvc/internal/repo/store/snapshot"
@@ -20,6 +21,8 @@ func ListAllBlocks(m MetaInterface, cfg *config.RepoConfig, onlyLatestCommit boo
 
 	blocks := make(map[string]*BlockInfo)
 
+	snapshots := snapshot.NewSnapshotContext(cfg.SnapshotsDir(), nil, nil, fs.NewOSFS())
+
 	for _, b := range branches {
 		var commitIDs []string
 		if !onlyLatestCommit {
@@ -45,9 +48,8 @@ func ListAllBlocks(m MetaInterface, cfg *config.RepoConfig, onlyLatestCommit boo
 				continue
 			}
 
-			filesetPath := filepath.Join(cfg.SnapshotsDir(), commit.FilesetID+".json")
-			var fs snapshot.Fileset
-			if err := util.ReadJSON(filesetPath, &fs); err != nil {
+			fs, err := snapshots.Load(commit.FilesetID)
+			if err != nil {
 				continue
 			}
 
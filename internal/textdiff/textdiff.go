@@ -0,0 +1,282 @@
+// Package textdiff computes line diffs of text files and renders them as
+// unified diffs.
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEditDistance bounds the work spent looking for a minimal diff. Files
+// further apart than this are diffed as one replaced region past the common
+// start and end, which is still a correct, if longer, diff.
+const maxEditDistance = 2000
+
+// sniffLen is how much of a file IsText looks at.
+const sniffLen = 8000
+
+// IsText reports whether data looks like text: no NUL bytes and valid UTF-8
+// in its first few kilobytes.
+func IsText(data []byte) bool {
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+		// the sample may end inside a multi-byte rune
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	return bytes.IndexByte(head, 0) < 0 && utf8.Valid(head)
+}
+
+// Op is the kind of an edit.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Edit is one line of a diff. A and B are the line's index in the old and
+// new text; only the side the line belongs to is meaningful.
+type Edit struct {
+	Op   Op
+	A, B int
+}
+
+// Lines splits text into lines, each keeping its trailing newline.
+func Lines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// Diff returns the edit script turning a into b.
+func Diff(a, b []string) []Edit {
+	// the common start and end are kept out of the search
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var edits []Edit
+	for i := 0; i < pre; i++ {
+		edits = append(edits, Edit{Equal, i, i})
+	}
+	edits = append(edits, myers(a[pre:len(a)-suf], b[pre:len(b)-suf], pre)...)
+	for i := 0; i < suf; i++ {
+		edits = append(edits, Edit{Equal, len(a) - suf + i, len(b) - suf + i})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script with Myers' O(ND) algorithm. Line
+// indexes are shifted by off.
+func myers(a, b []string, off int) []Edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD > maxEditDistance {
+		maxD = maxEditDistance
+	}
+
+	// trace[d] holds the furthest x on each diagonal k after d edits,
+	// indexed by k+d
+	var trace [][]int
+	v := []int{0, 0} // v for d = -1, so that d = 0 starts at x = 0
+	found := -1
+	for d := 0; d <= maxD && found < 0; d++ {
+		next := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && get(v, k-1, d-1) < get(v, k+1, d-1)) {
+				x = get(v, k+1, d-1) // down: insert
+			} else {
+				x = get(v, k-1, d-1) + 1 // right: delete
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			next[k+d] = x
+			if x >= n && y >= m {
+				found = d
+			}
+		}
+		trace = append(trace, next)
+		v = next
+	}
+	if found < 0 {
+		// too far apart: replace everything
+		var edits []Edit
+		for i := range a {
+			edits = append(edits, Edit{Delete, off + i, off})
+		}
+		for j := range b {
+			edits = append(edits, Edit{Insert, off + n, off + j})
+		}
+		return edits
+	}
+
+	// walk back from the end: each edit is preceded by a run of equal lines
+	var rev []Edit
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		insert := k == -d || (k != d && get(prev, k-1, d-1) < get(prev, k+1, d-1))
+		var sx int // where the edit lands
+		if insert {
+			sx = get(prev, k+1, d-1)
+		} else {
+			sx = get(prev, k-1, d-1) + 1
+		}
+		for x > sx {
+			x--
+			y--
+			rev = append(rev, Edit{Equal, off + x, off + y})
+		}
+		if insert {
+			y--
+			rev = append(rev, Edit{Insert, off + x, off + y})
+		} else {
+			x--
+			rev = append(rev, Edit{Delete, off + x, off + y})
+		}
+	}
+	for x > 0 {
+		x--
+		y--
+		rev = append(rev, Edit{Equal, off + x, off + y})
+	}
+
+	edits := make([]Edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
+
+// get reads diagonal k of a trace row for d edits; d = -1 is the start.
+func get(v []int, k, d int) int {
+	if d < 0 {
+		return 0
+	}
+	return v[k+d]
+}
+
+// Stat counts the inserted and deleted lines of an edit script.
+func Stat(edits []Edit) (inserted, deleted int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// WriteUnified writes the hunks of an edit script in unified format, with
+// the given number of context lines. The file header is left to the caller.
+// colorize, if not nil, wraps each line for display.
+func WriteUnified(w io.Writer, a, b []string, edits []Edit, context int, colorize func(op byte, line string) string) error {
+	if colorize == nil {
+		colorize = func(_ byte, line string) string { return line }
+	}
+	for _, h := range hunks(edits, context) {
+		aStart, bStart := edits[h[0]].A, edits[h[0]].B
+		aLen, bLen := 0, 0
+		for _, e := range edits[h[0]:h[1]] {
+			if e.Op != Insert {
+				aLen++
+			}
+			if e.Op != Delete {
+				bLen++
+			}
+		}
+		header := fmt.Sprintf("@@ -%s +%s @@", span(aStart, aLen), span(bStart, bLen))
+		if _, err := fmt.Fprintln(w, colorize('@', header)); err != nil {
+			return err
+		}
+		for _, e := range edits[h[0]:h[1]] {
+			var text string
+			if e.Op == Insert {
+				text = b[e.B]
+			} else {
+				text = a[e.A]
+			}
+			out := string(e.Op) + strings.TrimSuffix(text, "\n")
+			if _, err := fmt.Fprintln(w, colorize(byte(e.Op), out)); err != nil {
+				return err
+			}
+			if !strings.HasSuffix(text, "\n") {
+				if _, err := fmt.Fprintln(w, `\ No newline at end of file`); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hunks groups the changes of an edit script, with their context, into
+// [start, end) ranges of edits.
+func hunks(edits []Edit, context int) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(i-context, 0)
+		end := i
+		// extend while the next change is close enough to share context
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+		out = append(out, [2]int{start, end})
+		i = end
+	}
+	return out
+}
+
+// span formats the line range of a hunk side, 1-based as diff does.
+func span(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
@@ -0,0 +1,94 @@
+package textdiff_test
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/keshon/bvc/internal/textdiff"
+)
+
+// apply rebuilds both sides from an edit script.
+func apply(t *testing.T, a, b []string, edits []textdiff.Edit) {
+	t.Helper()
+	var gotA, gotB []string
+	for _, e := range edits {
+		switch e.Op {
+		case textdiff.Equal:
+			if a[e.A] != b[e.B] {
+				t.Fatalf("equal edit pairs %q with %q", a[e.A], b[e.B])
+			}
+			gotA, gotB = append(gotA, a[e.A]), append(gotB, b[e.B])
+		case textdiff.Delete:
+			gotA = append(gotA, a[e.A])
+		case textdiff.Insert:
+			gotB = append(gotB, b[e.B])
+		}
+	}
+	if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+		t.Fatalf("edit script does not rebuild the inputs")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := textdiff.Lines([]byte("a\nb\nc\nd\ne\nf\n"))
+	b := textdiff.Lines([]byte("a\nc\nd\nx\ne\nf\ng"))
+	edits := textdiff.Diff(a, b)
+	apply(t, a, b, edits)
+	if ins, del := textdiff.Stat(edits); ins != 2 || del != 1 {
+		t.Fatalf("got +%d -%d, want +2 -1", ins, del)
+	}
+
+	var out bytes.Buffer
+	if err := textdiff.WriteUnified(&out, a, b, edits, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "@@ -1,6 +1,7 @@\n a\n-b\n c\n d\n+x\n e\n f\n+g\n\\ No newline at end of file\n"
+	if out.String() != want {
+		t.Fatalf("unified diff:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := textdiff.WriteUnified(&out, a, b, edits, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "@@ -"); n != 3 {
+		t.Fatalf("expected 3 hunks without context, got %d:\n%s", n, out.String())
+	}
+}
+
+func TestDiffRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a\n", "b\n", "c\n", "d\n"}
+	for i := 0; i < 200; i++ {
+		a := make([]string, rng.Intn(30))
+		b := make([]string, rng.Intn(30))
+		for j := range a {
+			a[j] = words[rng.Intn(len(words))]
+		}
+		for j := range b {
+			b[j] = words[rng.Intn(len(words))]
+		}
+		apply(t, a, b, textdiff.Diff(a, b))
+	}
+}
+
+func TestIsText(t *testing.T) {
+	cases := map[string]bool{
+		"hello\n":                  true,
+		"caf\u00e9":                true,
+		"\x89PNG\r\n\x1a\n\x00":    false,
+		string([]byte{0xff, 0xfe}): false,
+	}
+	for in, want := range cases {
+		if got := textdiff.IsText([]byte(in)); got != want {
+			t.Errorf("IsText(%q) = %v, want %v", in, got, want)
+		}
+	}
+	// a rune cut by the sample boundary does not make a file binary
+	long := strings.Repeat("x", 7999) + "\u00e9\n"
+	if !textdiff.IsText([]byte(long)) {
+		t.Errorf("text with a rune across the sample boundary reported binary")
+	}
+}